@@ -2,11 +2,42 @@ package cache
 
 import (
 	"context"
+	"net/netip"
 
 	"github.com/cloud66-oss/geo/utils"
 )
 
+// Cache tiers reported in metrics
+const (
+	memoryTier = "memory"
+	diskTier   = "disk"
+	redisTier  = "redis"
+)
+
 type CacheProvider interface {
 	Fetch(ctx context.Context, provider string, address string) (*utils.IPInfo, error)
 	Add(ctx context.Context, provider string, ipInfo *utils.IPInfo) error
+	// Purge removes the cached entries of provider and address and returns
+	// how many were removed. An empty provider or address matches any, and
+	// a network matches every address in it
+	Purge(ctx context.Context, provider string, address string) (int, error)
+}
+
+// addressMatcher returns a function telling if the address of an entry is
+// matched by the address given to Purge
+func addressMatcher(address string) func(string) bool {
+	if address == "" {
+		return func(string) bool { return true }
+	}
+
+	network, err := netip.ParsePrefix(address)
+	if err != nil {
+		return func(entryAddress string) bool { return entryAddress == address }
+	}
+	network = network.Masked()
+
+	return func(entryAddress string) bool {
+		addr, err := netip.ParseAddr(entryAddress)
+		return err == nil && network.Contains(addr.Unmap())
+	}
 }
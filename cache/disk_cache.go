@@ -0,0 +1,466 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// entriesBucket holds a bucket per provider with the entries by address
+	entriesBucket = []byte("entries")
+	// orderBucket indexes the entries by the time they were added, oldest
+	// first, for eviction
+	orderBucket = []byte("order")
+)
+
+// DiskCache is a cache kept in a bbolt database so it survives restarts
+type DiskCache struct {
+	// lock is held for writing while the database is compacted
+	lock       sync.RWMutex
+	db         *bolt.DB
+	path       string
+	maxEntries int
+	entries    int
+	interval   time.Duration
+	stop       chan struct{}
+	now        func() time.Time
+}
+
+type diskEntry struct {
+	Added     int64         `json:"added"`
+	ExpiresAt int64         `json:"expires_at,omitempty"`
+	Version   string        `json:"version,omitempty"`
+	Info      *utils.IPInfo `json:"info"`
+}
+
+func NewDiskCache(ctx context.Context) (*DiskCache, error) {
+	path := viper.GetString("cache.disk.path")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	dc := &DiskCache{
+		path:       path,
+		maxEntries: viper.GetInt("cache.disk.max_entries"),
+		interval:   viper.GetDuration("cache.disk.compact_interval"),
+		stop:       make(chan struct{}),
+		now:        time.Now,
+	}
+
+	if err := dc.open(); err != nil {
+		return nil, err
+	}
+
+	if dc.interval > 0 {
+		go dc.compactEvery(dc.interval, dc.stop)
+	}
+
+	return dc, nil
+}
+
+func (dc *DiskCache) open() error {
+	db, err := bolt.Open(dc.path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+
+	entries := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(entriesBucket); err != nil {
+			return err
+		}
+
+		order, err := tx.CreateBucketIfNotExists(orderBucket)
+		if err != nil {
+			return err
+		}
+		entries = order.Stats().KeyN
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	dc.db = db
+	dc.entries = entries
+	utils.CacheSize.WithLabelValues(diskTier).Set(float64(entries))
+
+	return nil
+}
+
+func (dc *DiskCache) Fetch(ctx context.Context, provider string, address string) (*utils.IPInfo, error) {
+	ipInfo, _, err := dc.fetchExpiring(ctx, provider, address)
+	return ipInfo, err
+}
+
+// fetchExpiring returns the entry of address with its expiry time
+func (dc *DiskCache) fetchExpiring(ctx context.Context, provider string, address string) (*utils.IPInfo, time.Time, error) {
+	dc.lock.RLock()
+	defer dc.lock.RUnlock()
+
+	var entry *diskEntry
+	err := dc.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(entriesBucket).Bucket([]byte(provider))
+		if bucket == nil {
+			return nil
+		}
+
+		value := bucket.Get([]byte(address))
+		if value == nil {
+			return nil
+		}
+
+		entry = &diskEntry{}
+		return json.Unmarshal(value, entry)
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	// expired and stale entries are removed when the cache is compacted
+	if entry == nil || dc.stale(provider, entry, dc.now().UnixNano(), nil) {
+		utils.CacheMisses.WithLabelValues(diskTier).Inc()
+		return nil, time.Time{}, nil
+	}
+
+	var expires time.Time
+	if entry.ExpiresAt != 0 {
+		expires = time.Unix(0, entry.ExpiresAt)
+	}
+
+	utils.CacheHits.WithLabelValues(diskTier).Inc()
+	return entry.Info, expires, nil
+}
+
+func (dc *DiskCache) Add(ctx context.Context, provider string, ipInfo *utils.IPInfo) error {
+	dc.lock.RLock()
+	defer dc.lock.RUnlock()
+
+	now := dc.now()
+	entry := &diskEntry{
+		Added:   now.UnixNano(),
+		Info:    ipInfo,
+		Version: utils.DatabaseVersion(provider),
+	}
+	if expires := expiresAt(provider, ipInfo, now); !expires.IsZero() {
+		entry.ExpiresAt = expires.UnixNano()
+	}
+
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// bbolt allows a single writer at a time, so entries can be updated
+	// without further locking
+	return dc.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(entriesBucket).CreateBucketIfNotExists([]byte(provider))
+		if err != nil {
+			return err
+		}
+		order := tx.Bucket(orderBucket)
+
+		added := 1
+		if previous := bucket.Get([]byte(ipInfo.Address)); previous != nil {
+			var previousEntry diskEntry
+			if err := json.Unmarshal(previous, &previousEntry); err == nil {
+				if err := order.Delete(orderKey(previousEntry.Added, provider, ipInfo.Address)); err != nil {
+					return err
+				}
+				added = 0
+			}
+		}
+
+		if err := bucket.Put([]byte(ipInfo.Address), value); err != nil {
+			return err
+		}
+		if err := order.Put(orderKey(entry.Added, provider, ipInfo.Address), nil); err != nil {
+			return err
+		}
+
+		evicted, err := dc.evict(tx, dc.entries+added)
+		if err != nil {
+			return err
+		}
+
+		dc.entries += added - evicted
+		utils.CacheSize.WithLabelValues(diskTier).Set(float64(dc.entries))
+
+		return nil
+	})
+}
+
+// evict removes the oldest entries until no more than maxEntries are left
+func (dc *DiskCache) evict(tx *bolt.Tx, entries int) (int, error) {
+	if dc.maxEntries <= 0 || entries <= dc.maxEntries {
+		return 0, nil
+	}
+
+	evicted := 0
+	cursor := tx.Bucket(orderBucket).Cursor()
+	for key, _ := cursor.First(); key != nil && entries-evicted > dc.maxEntries; key, _ = cursor.First() {
+		if err := dc.deleteEntry(tx, key); err != nil {
+			return evicted, err
+		}
+		if err := cursor.Delete(); err != nil {
+			return evicted, err
+		}
+
+		evicted++
+		utils.CacheEvictions.WithLabelValues(diskTier).Inc()
+	}
+
+	return evicted, nil
+}
+
+// deleteEntry removes the entry the order key points to
+func (dc *DiskCache) deleteEntry(tx *bolt.Tx, key []byte) error {
+	_, provider, address := parseOrderKey(key)
+	bucket := tx.Bucket(entriesBucket).Bucket([]byte(provider))
+	if bucket == nil {
+		return nil
+	}
+
+	return bucket.Delete([]byte(address))
+}
+
+func (dc *DiskCache) Purge(ctx context.Context, provider string, address string) (int, error) {
+	dc.lock.RLock()
+	defer dc.lock.RUnlock()
+
+	matches := addressMatcher(address)
+	purged := 0
+	err := dc.db.Update(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(orderBucket).Cursor()
+		for key, _ := cursor.First(); key != nil; {
+			_, keyProvider, keyAddress := parseOrderKey(key)
+			if (provider != "" && keyProvider != provider) || !matches(keyAddress) {
+				key, _ = cursor.Next()
+				continue
+			}
+
+			if err := dc.deleteEntry(tx, key); err != nil {
+				return err
+			}
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+			purged++
+
+			// the deleted key is gone, so seeking it lands on the next one
+			key, _ = cursor.Seek(key)
+		}
+
+		dc.entries -= purged
+		utils.CacheSize.WithLabelValues(diskTier).Set(float64(dc.entries))
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+// stale tells if entry of provider has expired or was added before the
+// provider's databases were refreshed. versions memoizes the database versions
+// of providers and can be nil
+func (dc *DiskCache) stale(provider string, entry *diskEntry, now int64, versions map[string]string) bool {
+	if entry.ExpiresAt != 0 && now >= entry.ExpiresAt {
+		return true
+	}
+
+	version, ok := versions[provider]
+	if !ok {
+		version = utils.DatabaseVersion(provider)
+		if versions != nil {
+			versions[provider] = version
+		}
+	}
+
+	return entry.Version != version
+}
+
+// Compact removes the expired and stale entries and rewrites the database file to
+// give the space of deleted entries back to the file system
+func (dc *DiskCache) Compact(ctx context.Context) error {
+	dc.lock.Lock()
+	defer dc.lock.Unlock()
+
+	expired := 0
+	now := dc.now().UnixNano()
+	versions := map[string]string{}
+	err := dc.db.Update(func(tx *bolt.Tx) error {
+		entries := tx.Bucket(entriesBucket)
+		cursor := tx.Bucket(orderBucket).Cursor()
+		for key, _ := cursor.First(); key != nil; {
+			_, provider, address := parseOrderKey(key)
+
+			var entry diskEntry
+			bucket := entries.Bucket([]byte(provider))
+			if bucket != nil {
+				if value := bucket.Get([]byte(address)); value != nil {
+					if err := json.Unmarshal(value, &entry); err != nil {
+						return err
+					}
+				}
+			}
+
+			if bucket != nil && entry.Info != nil && !dc.stale(provider, &entry, now, versions) {
+				key, _ = cursor.Next()
+				continue
+			}
+
+			if bucket != nil {
+				if err := bucket.Delete([]byte(address)); err != nil {
+					return err
+				}
+			}
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+			expired++
+
+			key, _ = cursor.Seek(key)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	tmpPath := dc.path + ".compact"
+	dst, err := bolt.Open(tmpPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+
+	err = bolt.Compact(dst, dc.db, 64*1024)
+	dst.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	log.Debug().Int("expired", expired).Msg("compacted the disk cache")
+
+	return dc.swap(tmpPath)
+}
+
+// swap replaces the database with the compacted copy at tmpPath. The original
+// is kept aside until the copy opens, and reopened when it doesn't
+func (dc *DiskCache) swap(tmpPath string) error {
+	oldPath := dc.path + ".old"
+	if err := dc.db.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(dc.path, oldPath); err != nil {
+		os.Remove(tmpPath)
+		return errors.Join(err, dc.open())
+	}
+
+	err := os.Rename(tmpPath, dc.path)
+	if err == nil {
+		if err = dc.open(); err == nil {
+			os.Remove(oldPath)
+			return nil
+		}
+	}
+
+	// keep going with the uncompacted database
+	log.Error().Err(err).Msg("failed to replace the disk cache with its compacted copy")
+	os.Remove(tmpPath)
+	if err := os.Rename(oldPath, dc.path); err != nil {
+		return err
+	}
+
+	return dc.open()
+}
+
+func (dc *DiskCache) compactEvery(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := dc.Compact(context.Background()); err != nil {
+				log.Error().Err(err).Msg("failed to compact the disk cache")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Close stops compaction and closes the database
+func (dc *DiskCache) Close() error {
+	close(dc.stop)
+
+	dc.lock.Lock()
+	defer dc.lock.Unlock()
+
+	return dc.db.Close()
+}
+
+// Reopen opens the database again after Close and resumes compaction
+func (dc *DiskCache) Reopen() error {
+	dc.lock.Lock()
+	defer dc.lock.Unlock()
+
+	if err := dc.open(); err != nil {
+		return err
+	}
+
+	dc.stop = make(chan struct{})
+	if dc.interval > 0 {
+		go dc.compactEvery(dc.interval, dc.stop)
+	}
+
+	return nil
+}
+
+// orderKey is the key of an entry in the order bucket. It starts with the
+// time it was added so the bucket is sorted oldest first
+func orderKey(added int64, provider string, address string) []byte {
+	key := make([]byte, 8, 8+len(provider)+1+len(address))
+	binary.BigEndian.PutUint64(key, uint64(added))
+	key = append(key, provider...)
+	key = append(key, 0)
+	key = append(key, address...)
+
+	return key
+}
+
+func parseOrderKey(key []byte) (int64, string, string) {
+	if len(key) < 8 {
+		return 0, "", ""
+	}
+
+	added := int64(binary.BigEndian.Uint64(key[:8]))
+	rest := key[8:]
+	for i, b := range rest {
+		if b == 0 {
+			return added, string(rest[:i]), string(rest[i+1:])
+		}
+	}
+
+	return added, string(rest), ""
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type diskCacheTestSuite struct {
+	suite.Suite
+	now   time.Time
+	cache *DiskCache
+}
+
+func (suite *diskCacheTestSuite) SetupTest() {
+	viper.Set("cache.disk.path", filepath.Join(suite.T().TempDir(), "cache.db"))
+	viper.Set("cache.disk.max_entries", 3)
+	viper.Set("cache.disk.compact_interval", 0)
+	viper.Set("cache.ttl.default", 0)
+	viper.Set("cache.ttl.ipstack", "1h")
+
+	suite.now = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	suite.cache = suite.open()
+}
+
+func (suite *diskCacheTestSuite) TearDownTest() {
+	suite.cache.Close()
+}
+
+func (suite *diskCacheTestSuite) open() *DiskCache {
+	cache, err := NewDiskCache(context.Background())
+	suite.Require().NoError(err)
+	cache.now = func() time.Time { return suite.now }
+
+	return cache
+}
+
+func (suite *diskCacheTestSuite) add(provider string, address string) {
+	suite.Require().NoError(suite.cache.Add(context.Background(), provider, &utils.IPInfo{Address: address, Source: provider}))
+	suite.now = suite.now.Add(time.Second)
+}
+
+func (suite *diskCacheTestSuite) fetch(provider string, address string) *utils.IPInfo {
+	info, err := suite.cache.Fetch(context.Background(), provider, address)
+	suite.Require().NoError(err)
+
+	return info
+}
+
+func (suite *diskCacheTestSuite) TestFetch() {
+	suite.add("maxmind", "1.1.1.1")
+
+	info := suite.fetch("maxmind", "1.1.1.1")
+	if suite.NotNil(info) {
+		suite.Equal("1.1.1.1", info.Address)
+	}
+
+	suite.Nil(suite.fetch("dbip", "1.1.1.1"))
+	suite.Nil(suite.fetch("maxmind", "2.2.2.2"))
+}
+
+func (suite *diskCacheTestSuite) TestSurvivesRestart() {
+	suite.add("ipstack", "1.1.1.1")
+	suite.Require().NoError(suite.cache.Close())
+
+	suite.cache = suite.open()
+	suite.NotNil(suite.fetch("ipstack", "1.1.1.1"))
+}
+
+func (suite *diskCacheTestSuite) TestTTL() {
+	suite.add("ipstack", "1.1.1.1")
+	suite.add("maxmind", "1.1.1.1")
+
+	suite.now = suite.now.Add(2 * time.Hour)
+	suite.Nil(suite.fetch("ipstack", "1.1.1.1"))
+	suite.NotNil(suite.fetch("maxmind", "1.1.1.1"))
+}
+
+func (suite *diskCacheTestSuite) TestEviction() {
+	suite.add("maxmind", "1.1.1.1")
+	suite.add("maxmind", "2.2.2.2")
+	suite.add("maxmind", "3.3.3.3")
+
+	// updating an entry doesn't grow the cache but makes it the newest
+	suite.add("maxmind", "1.1.1.1")
+	suite.add("maxmind", "4.4.4.4")
+
+	suite.Nil(suite.fetch("maxmind", "2.2.2.2"))
+	suite.NotNil(suite.fetch("maxmind", "1.1.1.1"))
+	suite.NotNil(suite.fetch("maxmind", "3.3.3.3"))
+	suite.NotNil(suite.fetch("maxmind", "4.4.4.4"))
+}
+
+func (suite *diskCacheTestSuite) TestPurge() {
+	suite.add("maxmind", "1.1.1.1")
+	suite.add("maxmind", "2.2.2.2")
+	suite.add("dbip", "1.1.1.1")
+
+	purged, err := suite.cache.Purge(context.Background(), "", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.Equal(2, purged)
+	suite.Nil(suite.fetch("maxmind", "1.1.1.1"))
+	suite.Nil(suite.fetch("dbip", "1.1.1.1"))
+	suite.NotNil(suite.fetch("maxmind", "2.2.2.2"))
+
+	purged, err = suite.cache.Purge(context.Background(), "maxmind", "")
+	suite.Require().NoError(err)
+	suite.Equal(1, purged)
+	suite.Nil(suite.fetch("maxmind", "2.2.2.2"))
+
+	suite.add("maxmind", "3.3.3.3")
+	suite.add("maxmind", "3.3.4.4")
+	purged, err = suite.cache.Purge(context.Background(), "", "3.3.3.0/24")
+	suite.Require().NoError(err)
+	suite.Equal(1, purged)
+	suite.NotNil(suite.fetch("maxmind", "3.3.4.4"))
+}
+
+func (suite *diskCacheTestSuite) TestCompact() {
+	suite.add("ipstack", "1.1.1.1")
+	suite.add("maxmind", "2.2.2.2")
+
+	suite.now = suite.now.Add(2 * time.Hour)
+	suite.Require().NoError(suite.cache.Compact(context.Background()))
+
+	suite.Equal(1, suite.cache.entries)
+	suite.NotNil(suite.fetch("maxmind", "2.2.2.2"))
+}
+
+func (suite *diskCacheTestSuite) TestCompactedCopyFailure() {
+	suite.add("maxmind", "2.2.2.2")
+
+	// a copy that doesn't open leaves the original in place
+	tmpPath := suite.cache.path + ".compact"
+	suite.Require().NoError(os.WriteFile(tmpPath, []byte("not a database"), 0600))
+	suite.NoError(suite.cache.swap(tmpPath))
+
+	suite.NotNil(suite.fetch("maxmind", "2.2.2.2"))
+	suite.NoFileExists(tmpPath)
+	suite.NoFileExists(suite.cache.path + ".old")
+}
+
+func (suite *diskCacheTestSuite) TestDatabaseRefresh() {
+	utils.RecordDatabaseLoad("disktest", "city", 1)
+	suite.add("disktest", "1.1.1.1")
+	suite.add("maxmind", "2.2.2.2")
+	suite.NotNil(suite.fetch("disktest", "1.1.1.1"))
+
+	utils.RecordDatabaseLoad("disktest", "city", 2)
+	suite.Nil(suite.fetch("disktest", "1.1.1.1"))
+
+	suite.Require().NoError(suite.cache.Compact(context.Background()))
+	suite.Equal(1, suite.cache.entries)
+}
+
+func TestDiskCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(diskCacheTestSuite))
+}
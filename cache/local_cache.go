@@ -2,6 +2,8 @@ package cache
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/cloud66-oss/geo/utils"
 	lru "github.com/hashicorp/golang-lru"
@@ -10,30 +12,98 @@ import (
 
 type LocalCache struct {
 	cache *lru.ARCCache
+	size  int
+	now   func() time.Time
+}
+
+// localEntry is a cached lookup with the database version it was answered
+// from, so entries of a refreshed database are no longer returned
+type localEntry struct {
+	info      *utils.IPInfo
+	version   string
+	expiresAt time.Time
 }
 
 func NewLocalCache(ctx context.Context) (*LocalCache, error) {
-	cache, err := lru.NewARC(viper.GetInt("cache.size"))
+	size := viper.GetInt("cache.size")
+	cache, err := lru.NewARC(size)
 	if err != nil {
 		return nil, err
 	}
 
 	return &LocalCache{
 		cache: cache,
+		size:  size,
+		now:   time.Now,
 	}, nil
 }
 
 func (lc *LocalCache) Fetch(ctx context.Context, provider string, address string) (*utils.IPInfo, error) {
-	value, ok := lc.cache.Get(provider + "--" + address)
+	key := provider + "--" + address
+	value, ok := lc.cache.Get(key)
 	if ok {
-		return value.(*utils.IPInfo), nil
+		entry := value.(*localEntry)
+		if entry.version == utils.DatabaseVersion(provider) && (entry.expiresAt.IsZero() || lc.now().Before(entry.expiresAt)) {
+			utils.CacheHits.WithLabelValues(memoryTier).Inc()
+			return entry.info, nil
+		}
+
+		lc.cache.Remove(key)
+		utils.CacheSize.WithLabelValues(memoryTier).Set(float64(lc.cache.Len()))
 	}
 
+	utils.CacheMisses.WithLabelValues(memoryTier).Inc()
 	return nil, nil
 }
 
 func (lc *LocalCache) Add(ctx context.Context, provider string, ipInfo *utils.IPInfo) error {
-	lc.cache.Add(provider+"--"+ipInfo.Address, ipInfo)
+	return lc.addUntil(ctx, provider, ipInfo, time.Time{})
+}
+
+// addUntil adds ipInfo, keeping it until until at the latest
+func (lc *LocalCache) addUntil(ctx context.Context, provider string, ipInfo *utils.IPInfo, until time.Time) error {
+	key := provider + "--" + ipInfo.Address
+
+	// ARC has no eviction callback, but a new key going into a full cache
+	// always pushes another one out
+	if !lc.cache.Contains(key) && lc.cache.Len() >= lc.size {
+		utils.CacheEvictions.WithLabelValues(memoryTier).Inc()
+	}
+
+	lc.cache.Add(key, &localEntry{
+		info:      ipInfo,
+		version:   utils.DatabaseVersion(provider),
+		expiresAt: earliest(expiresAt(provider, ipInfo, lc.now()), until),
+	})
+	utils.CacheSize.WithLabelValues(memoryTier).Set(float64(lc.cache.Len()))
 
 	return nil
 }
+
+func (lc *LocalCache) Purge(ctx context.Context, provider string, address string) (int, error) {
+	if provider == "" && address == "" {
+		purged := lc.cache.Len()
+		lc.cache.Purge()
+		utils.CacheSize.WithLabelValues(memoryTier).Set(0)
+
+		return purged, nil
+	}
+
+	matches := addressMatcher(address)
+	purged := 0
+	for _, key := range lc.cache.Keys() {
+		keyProvider, keyAddress, _ := strings.Cut(key.(string), "--")
+		if provider != "" && keyProvider != provider {
+			continue
+		}
+		if !matches(keyAddress) {
+			continue
+		}
+
+		lc.cache.Remove(key)
+		purged++
+	}
+	utils.CacheSize.WithLabelValues(memoryTier).Set(float64(lc.cache.Len()))
+
+	return purged, nil
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type localCacheTestSuite struct {
+	suite.Suite
+	now   time.Time
+	cache *LocalCache
+}
+
+func (suite *localCacheTestSuite) SetupTest() {
+	viper.Set("cache.size", 16)
+	viper.Set("cache.ttl.default", 0)
+	viper.Set("cache.ttl.ipstack", "1h")
+
+	var err error
+	suite.cache, err = NewLocalCache(context.Background())
+	suite.Require().NoError(err)
+
+	suite.now = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	suite.cache.now = func() time.Time { return suite.now }
+}
+
+func (suite *localCacheTestSuite) add(provider string, ipInfo *utils.IPInfo) {
+	suite.Require().NoError(suite.cache.Add(context.Background(), provider, ipInfo))
+}
+
+func (suite *localCacheTestSuite) fetch(provider string, address string) *utils.IPInfo {
+	info, err := suite.cache.Fetch(context.Background(), provider, address)
+	suite.Require().NoError(err)
+
+	return info
+}
+
+func (suite *localCacheTestSuite) TestTTL() {
+	suite.add("ipstack", &utils.IPInfo{Address: "1.1.1.1", Source: "ipstack"})
+	suite.add("maxmind", &utils.IPInfo{Address: "1.1.1.1", Source: "maxmind"})
+	// cascade answers from ipstack are kept as long as ipstack's
+	suite.add("cascade", &utils.IPInfo{Address: "1.1.1.1", Source: "ipstack"})
+
+	suite.now = suite.now.Add(2 * time.Hour)
+	suite.Nil(suite.fetch("ipstack", "1.1.1.1"))
+	suite.Nil(suite.fetch("cascade", "1.1.1.1"))
+	suite.NotNil(suite.fetch("maxmind", "1.1.1.1"))
+}
+
+func (suite *localCacheTestSuite) TestRemoteTTL() {
+	viper.Set("cache.ttl.remote", "30m")
+	defer viper.Set("cache.ttl.remote", nil)
+	viper.Set("cache.ttl.ipstack", nil)
+	defer viper.Set("cache.ttl.ipstack", "1h")
+
+	suite.add("ipstack", &utils.IPInfo{Address: "1.1.1.1", Source: "ipstack"})
+	suite.add("maxmind", &utils.IPInfo{Address: "1.1.1.1", Source: "maxmind"})
+
+	suite.now = suite.now.Add(time.Hour)
+	suite.Nil(suite.fetch("ipstack", "1.1.1.1"))
+	suite.NotNil(suite.fetch("maxmind", "1.1.1.1"))
+}
+
+func (suite *localCacheTestSuite) TestDatabaseRefresh() {
+	utils.RecordDatabaseLoad("localtest", "city", 1)
+	suite.add("localtest", &utils.IPInfo{Address: "1.1.1.1"})
+	suite.NotNil(suite.fetch("localtest", "1.1.1.1"))
+
+	utils.RecordDatabaseLoad("localtest", "city", 2)
+	suite.Nil(suite.fetch("localtest", "1.1.1.1"))
+}
+
+func (suite *localCacheTestSuite) TestCascadeFollowsMembers() {
+	utils.SetProviderMembers("localcascade", []string{"localmember"})
+	utils.RecordDatabaseLoad("localmember", "city", 1)
+	suite.add("localcascade", &utils.IPInfo{Address: "1.1.1.1"})
+	suite.NotNil(suite.fetch("localcascade", "1.1.1.1"))
+
+	utils.RecordDatabaseLoad("localmember", "city", 2)
+	suite.Nil(suite.fetch("localcascade", "1.1.1.1"))
+}
+
+func TestLocalCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(localCacheTestSuite))
+}
@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"github.com/spf13/viper"
+)
+
+// PrefixCache is an in-memory cache keyed by the network an answer holds for
+// rather than by address, so a single entry answers every address of the
+// network. Entries are looked up by longest prefix match and evicted least
+// recently used first
+type PrefixCache struct {
+	lock    sync.Mutex
+	entries *simplelru.LRU
+	// networks indexes the cached networks of each provider
+	networks map[string]*utils.PrefixTable[struct{}]
+	ipv4Bits int
+	ipv6Bits int
+	now      func() time.Time
+}
+
+type prefixKey struct {
+	provider string
+	network  netip.Prefix
+}
+
+func NewPrefixCache(ctx context.Context) (*PrefixCache, error) {
+	pc := &PrefixCache{
+		networks: map[string]*utils.PrefixTable[struct{}]{},
+		ipv4Bits: viper.GetInt("cache.prefix.ipv4"),
+		ipv6Bits: viper.GetInt("cache.prefix.ipv6"),
+		now:      time.Now,
+	}
+
+	entries, err := simplelru.NewLRU(viper.GetInt("cache.size"), pc.removed)
+	if err != nil {
+		return nil, err
+	}
+	pc.entries = entries
+
+	return pc, nil
+}
+
+// removed keeps the network index in line with the entries. It is called
+// with the lock held whenever an entry leaves the cache
+func (pc *PrefixCache) removed(key interface{}, _ interface{}) {
+	k := key.(prefixKey)
+	if networks, ok := pc.networks[k.provider]; ok {
+		networks.Delete(k.network)
+		if networks.Len() == 0 {
+			delete(pc.networks, k.provider)
+		}
+	}
+}
+
+func (pc *PrefixCache) Fetch(ctx context.Context, provider string, address string) (*utils.IPInfo, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		utils.CacheMisses.WithLabelValues(memoryTier).Inc()
+		return nil, nil
+	}
+
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	version := utils.DatabaseVersion(provider)
+	now := pc.now()
+	for {
+		networks, ok := pc.networks[provider]
+		if !ok {
+			break
+		}
+		network, _, ok := networks.Lookup(addr)
+		if !ok {
+			break
+		}
+
+		key := prefixKey{provider: provider, network: network}
+		value, _ := pc.entries.Get(key)
+		entry := value.(*localEntry)
+		if entry.version == version && (entry.expiresAt.IsZero() || now.Before(entry.expiresAt)) {
+			utils.CacheHits.WithLabelValues(memoryTier).Inc()
+
+			info := *entry.info
+			info.Address = address
+			return &info, nil
+		}
+
+		// a wider network may still be fresh
+		pc.entries.Remove(key)
+		utils.CacheSize.WithLabelValues(memoryTier).Set(float64(pc.entries.Len()))
+	}
+
+	utils.CacheMisses.WithLabelValues(memoryTier).Inc()
+	return nil, nil
+}
+
+// network returns the network ipInfo holds for. Answers without one are
+// assumed to hold for cache.prefix.ipv4 or cache.prefix.ipv6 bits around the
+// address
+func (pc *PrefixCache) network(ipInfo *utils.IPInfo) (netip.Prefix, bool) {
+	addr, err := netip.ParseAddr(ipInfo.Address)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap().WithZone("")
+
+	if network, err := netip.ParsePrefix(ipInfo.Network); err == nil && network.Contains(addr) {
+		return network.Masked(), true
+	}
+
+	bits := pc.ipv6Bits
+	if addr.Is4() {
+		bits = pc.ipv4Bits
+	}
+	if bits <= 0 || bits > addr.BitLen() {
+		bits = addr.BitLen()
+	}
+
+	network, err := addr.Prefix(bits)
+	return network, err == nil
+}
+
+func (pc *PrefixCache) Add(ctx context.Context, provider string, ipInfo *utils.IPInfo) error {
+	return pc.addUntil(ctx, provider, ipInfo, time.Time{})
+}
+
+// addUntil adds ipInfo, keeping it until until at the latest
+func (pc *PrefixCache) addUntil(ctx context.Context, provider string, ipInfo *utils.IPInfo, until time.Time) error {
+	network, ok := pc.network(ipInfo)
+	if !ok {
+		return nil
+	}
+
+	entry := &localEntry{
+		info:      ipInfo,
+		version:   utils.DatabaseVersion(provider),
+		expiresAt: earliest(expiresAt(provider, ipInfo, pc.now()), until),
+	}
+
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	if pc.entries.Add(prefixKey{provider: provider, network: network}, entry) {
+		utils.CacheEvictions.WithLabelValues(memoryTier).Inc()
+	}
+
+	networks, ok := pc.networks[provider]
+	if !ok {
+		networks = utils.NewPrefixTable[struct{}]()
+		pc.networks[provider] = networks
+	}
+	networks.Insert(network, struct{}{})
+	utils.CacheSize.WithLabelValues(memoryTier).Set(float64(pc.entries.Len()))
+
+	return nil
+}
+
+// Purge removes the entries of provider whose network contains address, or
+// overlaps it when address is a network
+func (pc *PrefixCache) Purge(ctx context.Context, provider string, address string) (int, error) {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	if provider == "" && address == "" {
+		purged := pc.entries.Len()
+		pc.entries.Purge()
+		utils.CacheSize.WithLabelValues(memoryTier).Set(0)
+
+		return purged, nil
+	}
+
+	var purge netip.Prefix
+	if address != "" {
+		var err error
+		purge, err = netip.ParsePrefix(address)
+		if err != nil {
+			addr, err := netip.ParseAddr(address)
+			if err != nil {
+				return 0, nil
+			}
+			addr = addr.Unmap().WithZone("")
+			purge = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		purge = purge.Masked()
+	}
+
+	var keys []prefixKey
+	for networksProvider, networks := range pc.networks {
+		if provider != "" && networksProvider != provider {
+			continue
+		}
+
+		networks.Range(func(network netip.Prefix, _ struct{}) bool {
+			if !purge.IsValid() || network.Overlaps(purge) {
+				keys = append(keys, prefixKey{provider: networksProvider, network: network})
+			}
+			return true
+		})
+	}
+
+	for _, key := range keys {
+		pc.entries.Remove(key)
+	}
+	utils.CacheSize.WithLabelValues(memoryTier).Set(float64(pc.entries.Len()))
+
+	return len(keys), nil
+}
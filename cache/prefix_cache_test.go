@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type prefixCacheTestSuite struct {
+	suite.Suite
+	now   time.Time
+	cache *PrefixCache
+}
+
+func (suite *prefixCacheTestSuite) SetupTest() {
+	viper.Set("cache.size", 3)
+	viper.Set("cache.prefix.ipv4", 32)
+	viper.Set("cache.prefix.ipv6", 128)
+	viper.Set("cache.ttl.default", 0)
+	viper.Set("cache.ttl.ipstack", "1h")
+
+	suite.cache = suite.open()
+}
+
+func (suite *prefixCacheTestSuite) open() *PrefixCache {
+	cache, err := NewPrefixCache(context.Background())
+	suite.Require().NoError(err)
+
+	suite.now = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return suite.now }
+
+	return cache
+}
+
+func (suite *prefixCacheTestSuite) add(provider string, address string, network string) {
+	suite.Require().NoError(suite.cache.Add(context.Background(), provider, &utils.IPInfo{Address: address, Network: network, Source: provider}))
+}
+
+func (suite *prefixCacheTestSuite) fetch(provider string, address string) *utils.IPInfo {
+	info, err := suite.cache.Fetch(context.Background(), provider, address)
+	suite.Require().NoError(err)
+
+	return info
+}
+
+func (suite *prefixCacheTestSuite) TestNeighboursHit() {
+	suite.add("maxmind", "81.2.69.160", "81.2.69.0/24")
+
+	info := suite.fetch("maxmind", "81.2.69.7")
+	if suite.NotNil(info) {
+		suite.Equal("81.2.69.7", info.Address)
+		suite.Equal("81.2.69.0/24", info.Network)
+	}
+
+	suite.Nil(suite.fetch("maxmind", "81.2.70.1"))
+	suite.Nil(suite.fetch("dbip", "81.2.69.7"))
+}
+
+func (suite *prefixCacheTestSuite) TestLongestMatch() {
+	suite.add("maxmind", "10.0.0.1", "10.0.0.0/8")
+	suite.add("maxmind", "10.1.0.1", "10.1.0.0/16")
+
+	suite.Equal("10.1.0.0/16", suite.fetch("maxmind", "10.1.2.3").Network)
+	suite.Equal("10.0.0.0/8", suite.fetch("maxmind", "10.2.0.1").Network)
+}
+
+func (suite *prefixCacheTestSuite) TestAnswersWithoutNetwork() {
+	suite.add("ipstack", "1.1.1.1", "")
+	suite.NotNil(suite.fetch("ipstack", "1.1.1.1"))
+	suite.Nil(suite.fetch("ipstack", "1.1.1.2"))
+
+	// remote answers can be shared across a network
+	viper.Set("cache.prefix.ipv4", 24)
+	suite.cache = suite.open()
+	suite.add("ipstack", "1.1.1.1", "")
+	suite.NotNil(suite.fetch("ipstack", "1.1.1.2"))
+
+	// networks that don't contain the address are ignored
+	suite.add("maxmind", "2.2.2.2", "3.3.3.0/24")
+	suite.Nil(suite.fetch("maxmind", "3.3.3.3"))
+}
+
+func (suite *prefixCacheTestSuite) TestMappedAddresses() {
+	suite.add("maxmind", "81.2.69.160", "81.2.69.0/24")
+	suite.NotNil(suite.fetch("maxmind", "::ffff:81.2.69.7"))
+}
+
+func (suite *prefixCacheTestSuite) TestExpiredFallsBackToWider() {
+	suite.add("ipstack", "10.1.0.1", "10.1.0.0/16")
+	suite.now = suite.now.Add(2 * time.Hour)
+	suite.add("ipstack", "10.0.0.1", "10.0.0.0/8")
+
+	suite.Equal("10.0.0.0/8", suite.fetch("ipstack", "10.1.2.3").Network)
+	suite.Equal(1, suite.cache.entries.Len())
+}
+
+func (suite *prefixCacheTestSuite) TestDatabaseRefresh() {
+	utils.RecordDatabaseLoad("prefixtest", "city", 1)
+	suite.add("prefixtest", "81.2.69.160", "81.2.69.0/24")
+	suite.NotNil(suite.fetch("prefixtest", "81.2.69.7"))
+
+	utils.RecordDatabaseLoad("prefixtest", "city", 2)
+	suite.Nil(suite.fetch("prefixtest", "81.2.69.7"))
+}
+
+func (suite *prefixCacheTestSuite) TestEviction() {
+	suite.add("maxmind", "1.1.1.1", "1.1.1.0/24")
+	suite.add("maxmind", "2.2.2.2", "2.2.2.0/24")
+	suite.add("maxmind", "3.3.3.3", "3.3.3.0/24")
+	suite.NotNil(suite.fetch("maxmind", "1.1.1.9"))
+	suite.add("maxmind", "4.4.4.4", "4.4.4.0/24")
+
+	suite.Nil(suite.fetch("maxmind", "2.2.2.9"))
+	suite.NotNil(suite.fetch("maxmind", "1.1.1.9"))
+	suite.Equal(3, suite.cache.networks["maxmind"].Len())
+}
+
+func (suite *prefixCacheTestSuite) TestPurge() {
+	ctx := context.Background()
+	suite.add("maxmind", "10.0.0.1", "10.0.0.0/8")
+	suite.add("maxmind", "10.1.0.1", "10.1.0.0/16")
+	suite.add("dbip", "10.1.0.1", "10.1.0.0/24")
+
+	purged, err := suite.cache.Purge(ctx, "maxmind", "10.1.2.3")
+	suite.Require().NoError(err)
+	suite.Equal(2, purged)
+	suite.Nil(suite.fetch("maxmind", "10.1.2.3"))
+	suite.NotNil(suite.fetch("dbip", "10.1.0.9"))
+
+	purged, err = suite.cache.Purge(ctx, "", "")
+	suite.Require().NoError(err)
+	suite.Equal(1, purged)
+	suite.Empty(suite.cache.networks)
+}
+
+func (suite *prefixCacheTestSuite) TestPurgeNetwork() {
+	ctx := context.Background()
+	suite.add("maxmind", "10.0.0.1", "10.0.0.0/8")
+	suite.add("maxmind", "10.1.0.1", "10.1.0.0/24")
+	suite.add("maxmind", "10.2.0.1", "10.2.0.0/24")
+
+	// networks overlapping the purged one go, wider or narrower
+	purged, err := suite.cache.Purge(ctx, "maxmind", "10.1.0.0/16")
+	suite.Require().NoError(err)
+	suite.Equal(2, purged)
+	suite.Nil(suite.fetch("maxmind", "10.1.0.1"))
+	suite.NotNil(suite.fetch("maxmind", "10.2.0.1"))
+}
+
+func TestPrefixCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(prefixCacheTestSuite))
+}
@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// RedisCache is a cache shared by all replicas through Redis. Keys are
+// namespaced by provider and database version, so entries from a previous
+// build of a database are never returned. Every entry expires, with entries
+// that have no TTL of their own kept for cache.redis.max_ttl, so the keys of
+// previous builds don't stay in Redis forever
+type RedisCache struct {
+	client *redis.Client
+	prefix string
+	maxTTL time.Duration
+}
+
+func NewRedisCache(ctx context.Context) (*RedisCache, error) {
+	maxTTL := viper.GetDuration("cache.redis.max_ttl")
+	if maxTTL <= 0 {
+		return nil, errors.New("cache.redis.max_ttl must be positive")
+	}
+
+	options := &redis.Options{
+		Addr:     viper.GetString("cache.redis.address"),
+		Password: viper.GetString("cache.redis.password"),
+		DB:       viper.GetInt("cache.redis.db"),
+	}
+	if url := viper.GetString("cache.redis.url"); url != "" {
+		var err error
+		options, err = redis.ParseURL(url)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client := redis.NewClient(options)
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisCache{
+		client: client,
+		prefix: viper.GetString("cache.redis.prefix"),
+		maxTTL: maxTTL,
+	}, nil
+}
+
+// key is <prefix>:<provider>:<database version>:<address>
+func (rc *RedisCache) key(provider string, address string) string {
+	return rc.prefix + ":" + provider + ":" + utils.DatabaseVersion(provider) + ":" + address
+}
+
+func (rc *RedisCache) Fetch(ctx context.Context, provider string, address string) (*utils.IPInfo, error) {
+	ipInfo, _, err := rc.fetchExpiring(ctx, provider, address)
+	return ipInfo, err
+}
+
+// fetchExpiring returns the entry of address with its expiry time, read
+// along with it
+func (rc *RedisCache) fetchExpiring(ctx context.Context, provider string, address string) (*utils.IPInfo, time.Time, error) {
+	key := rc.key(provider, address)
+	pipe := rc.client.Pipeline()
+	get := pipe.Get(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	now := time.Now()
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, time.Time{}, err
+	}
+
+	value, err := get.Bytes()
+	if errors.Is(err, redis.Nil) {
+		utils.CacheMisses.WithLabelValues(redisTier).Inc()
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var ipInfo utils.IPInfo
+	if err := json.Unmarshal(value, &ipInfo); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var expires time.Time
+	if remaining := ttl.Val(); remaining > 0 {
+		expires = now.Add(remaining)
+	}
+
+	utils.CacheHits.WithLabelValues(redisTier).Inc()
+	return &ipInfo, expires, nil
+}
+
+func (rc *RedisCache) Add(ctx context.Context, provider string, ipInfo *utils.IPInfo) error {
+	value, err := json.Marshal(ipInfo)
+	if err != nil {
+		return err
+	}
+
+	ttl := ttlFor(provider, ipInfo)
+	if ttl <= 0 {
+		ttl = rc.maxTTL
+	}
+
+	return rc.client.Set(ctx, rc.key(provider, ipInfo.Address), value, ttl).Err()
+}
+
+// Purge removes the matching entries of every database version
+func (rc *RedisCache) Purge(ctx context.Context, provider string, address string) (int, error) {
+	pattern := rc.prefix + ":*"
+	if provider != "" {
+		pattern = rc.prefix + ":" + provider + ":*"
+	}
+
+	matches := addressMatcher(address)
+	purged := 0
+	iter := rc.client.Scan(ctx, 0, pattern, 1000).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		key := iter.Val()
+
+		// addresses can contain colons, so match them exactly rather than
+		// through the pattern
+		parts := strings.SplitN(strings.TrimPrefix(key, rc.prefix+":"), ":", 3)
+		if len(parts) != 3 || !matches(parts[2]) {
+			continue
+		}
+
+		keys = append(keys, key)
+		if len(keys) == 1000 {
+			deleted, err := rc.client.Del(ctx, keys...).Result()
+			if err != nil {
+				return purged, err
+			}
+			purged += int(deleted)
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return purged, err
+	}
+
+	if len(keys) > 0 {
+		deleted, err := rc.client.Del(ctx, keys...).Result()
+		if err != nil {
+			return purged, err
+		}
+		purged += int(deleted)
+	}
+
+	return purged, nil
+}
+
+func (rc *RedisCache) Close() error {
+	return rc.client.Close()
+}
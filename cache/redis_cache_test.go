@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type redisCacheTestSuite struct {
+	suite.Suite
+	server *miniredis.Miniredis
+	cache  *RedisCache
+}
+
+func (suite *redisCacheTestSuite) SetupTest() {
+	suite.server = miniredis.RunT(suite.T())
+	viper.Set("cache.redis.url", "")
+	viper.Set("cache.redis.address", suite.server.Addr())
+	viper.Set("cache.redis.prefix", "geo")
+	viper.Set("cache.ttl.default", 0)
+	viper.Set("cache.ttl.ipstack", "1h")
+	viper.Set("cache.redis.max_ttl", "24h")
+
+	var err error
+	suite.cache, err = NewRedisCache(context.Background())
+	suite.Require().NoError(err)
+}
+
+func (suite *redisCacheTestSuite) TearDownTest() {
+	suite.cache.Close()
+}
+
+func (suite *redisCacheTestSuite) add(provider string, address string) {
+	suite.Require().NoError(suite.cache.Add(context.Background(), provider, &utils.IPInfo{Address: address, Source: provider}))
+}
+
+func (suite *redisCacheTestSuite) fetch(provider string, address string) *utils.IPInfo {
+	info, err := suite.cache.Fetch(context.Background(), provider, address)
+	suite.Require().NoError(err)
+
+	return info
+}
+
+func (suite *redisCacheTestSuite) TestFetch() {
+	suite.add("maxmind", "1.1.1.1")
+
+	info := suite.fetch("maxmind", "1.1.1.1")
+	if suite.NotNil(info) {
+		suite.Equal("1.1.1.1", info.Address)
+		suite.Equal("maxmind", info.Source)
+	}
+
+	suite.Nil(suite.fetch("dbip", "1.1.1.1"))
+	suite.Nil(suite.fetch("maxmind", "2.2.2.2"))
+}
+
+func (suite *redisCacheTestSuite) TestTTL() {
+	suite.add("ipstack", "1.1.1.1")
+	suite.add("maxmind", "1.1.1.1")
+
+	suite.server.FastForward(2 * time.Hour)
+	suite.Nil(suite.fetch("ipstack", "1.1.1.1"))
+	suite.NotNil(suite.fetch("maxmind", "1.1.1.1"))
+
+	// entries without a TTL still expire, so old database versions go away
+	suite.server.FastForward(23 * time.Hour)
+	suite.Nil(suite.fetch("maxmind", "1.1.1.1"))
+}
+
+func (suite *redisCacheTestSuite) TestMaxTTLRequired() {
+	viper.Set("cache.redis.max_ttl", 0)
+	_, err := NewRedisCache(context.Background())
+	suite.Error(err)
+}
+
+func (suite *redisCacheTestSuite) TestDatabaseVersion() {
+	utils.RecordDatabaseLoad("redistest", "city", 1)
+	suite.add("redistest", "1.1.1.1")
+	suite.NotNil(suite.fetch("redistest", "1.1.1.1"))
+
+	// a new build of the database makes the old entries unreachable
+	utils.RecordDatabaseLoad("redistest", "city", 2)
+	suite.Nil(suite.fetch("redistest", "1.1.1.1"))
+}
+
+func (suite *redisCacheTestSuite) TestPurge() {
+	ctx := context.Background()
+	suite.add("maxmind", "1.1.1.1")
+	suite.add("maxmind", "2001:db8::1")
+	suite.add("dbip", "1.1.1.1")
+
+	purged, err := suite.cache.Purge(ctx, "maxmind", "2001:db8::1")
+	suite.Require().NoError(err)
+	suite.Equal(1, purged)
+	suite.Nil(suite.fetch("maxmind", "2001:db8::1"))
+
+	purged, err = suite.cache.Purge(ctx, "", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.Equal(2, purged)
+	suite.Nil(suite.fetch("dbip", "1.1.1.1"))
+}
+
+func (suite *redisCacheTestSuite) TestTiered() {
+	ctx := context.Background()
+	viper.Set("cache.size", 16)
+	l1, err := NewLocalCache(ctx)
+	suite.Require().NoError(err)
+	tiered, err := NewTieredCache(ctx, l1, suite.cache)
+	suite.Require().NoError(err)
+
+	// another replica filled the shared cache
+	suite.add("maxmind", "1.1.1.1")
+
+	info, err := tiered.Fetch(ctx, "maxmind", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.NotNil(info)
+
+	info, err = l1.Fetch(ctx, "maxmind", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.NotNil(info)
+
+	// promoted entries expire with the Redis key
+	suite.add("ipstack", "1.1.1.1")
+	suite.server.FastForward(50 * time.Minute)
+	info, err = tiered.Fetch(ctx, "ipstack", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.NotNil(info)
+
+	l1.now = func() time.Time { return time.Now().Add(11 * time.Minute) }
+	info, err = l1.Fetch(ctx, "ipstack", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.Nil(info)
+}
+
+func TestRedisCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(redisCacheTestSuite))
+}
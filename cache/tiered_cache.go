@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/cloud66-oss/geo/utils"
+)
+
+// expiringCache is a second tier telling when the entries it returns expire,
+// so the first tier doesn't keep them any longer. The zero time is never
+type expiringCache interface {
+	fetchExpiring(ctx context.Context, provider string, address string) (*utils.IPInfo, time.Time, error)
+}
+
+// promotingCache is a first tier that can keep an entry until an expiry
+// time at the latest
+type promotingCache interface {
+	addUntil(ctx context.Context, provider string, ipInfo *utils.IPInfo, until time.Time) error
+}
+
+// TieredCache puts a fast first tier, usually the in-memory cache, in front
+// of a slower but larger or shared second tier
+type TieredCache struct {
+	l1 CacheProvider
+	l2 CacheProvider
+}
+
+func NewTieredCache(ctx context.Context, l1 CacheProvider, l2 CacheProvider) (*TieredCache, error) {
+	return &TieredCache{
+		l1: l1,
+		l2: l2,
+	}, nil
+}
+
+func (tc *TieredCache) Fetch(ctx context.Context, provider string, address string) (*utils.IPInfo, error) {
+	ipInfo, err := tc.l1.Fetch(ctx, provider, address)
+	if err != nil || ipInfo != nil {
+		return ipInfo, err
+	}
+
+	var until time.Time
+	if l2, ok := tc.l2.(expiringCache); ok {
+		ipInfo, until, err = l2.fetchExpiring(ctx, provider, address)
+	} else {
+		ipInfo, err = tc.l2.Fetch(ctx, provider, address)
+	}
+	if err != nil || ipInfo == nil {
+		return ipInfo, err
+	}
+
+	// promote second tier hits so the next fetch doesn't leave memory, no
+	// longer than the second tier keeps them. The hit is good either way
+	if l1, ok := tc.l1.(promotingCache); ok {
+		err = l1.addUntil(ctx, provider, ipInfo, until)
+	} else {
+		err = tc.l1.Add(ctx, provider, ipInfo)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("provider", provider).Str("address", address).Msg("failed to promote cache entry")
+	}
+
+	return ipInfo, nil
+}
+
+// Add adds the entry to both tiers. The first tier keeps it even when the
+// second tier fails, whose error is then returned
+func (tc *TieredCache) Add(ctx context.Context, provider string, ipInfo *utils.IPInfo) error {
+	if err := tc.l1.Add(ctx, provider, ipInfo); err != nil {
+		return err
+	}
+
+	return tc.l2.Add(ctx, provider, ipInfo)
+}
+
+func (tc *TieredCache) Purge(ctx context.Context, provider string, address string) (int, error) {
+	if _, err := tc.l1.Purge(ctx, provider, address); err != nil {
+		return 0, err
+	}
+
+	// the second tier has every entry of the first
+	return tc.l2.Purge(ctx, provider, address)
+}
+
+// Close closes the tiers that need it
+func (tc *TieredCache) Close() error {
+	for _, tier := range []CacheProvider{tc.l1, tc.l2} {
+		if closer, ok := tier.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Reopen reopens the tiers closed by Close that can be
+func (tc *TieredCache) Reopen() error {
+	for _, tier := range []CacheProvider{tc.l1, tc.l2} {
+		if reopener, ok := tier.(interface{ Reopen() error }); ok {
+			if err := reopener.Reopen(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
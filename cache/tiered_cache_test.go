@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type tieredCacheTestSuite struct {
+	suite.Suite
+	l1    *LocalCache
+	l2    *DiskCache
+	cache *TieredCache
+}
+
+func (suite *tieredCacheTestSuite) SetupTest() {
+	ctx := context.Background()
+	viper.Set("cache.size", 16)
+	viper.Set("cache.disk.path", filepath.Join(suite.T().TempDir(), "cache.db"))
+	viper.Set("cache.disk.max_entries", 0)
+	viper.Set("cache.disk.compact_interval", 0)
+
+	var err error
+	suite.l1, err = NewLocalCache(ctx)
+	suite.Require().NoError(err)
+	suite.l2, err = NewDiskCache(ctx)
+	suite.Require().NoError(err)
+	suite.cache, err = NewTieredCache(ctx, suite.l1, suite.l2)
+	suite.Require().NoError(err)
+}
+
+func (suite *tieredCacheTestSuite) TearDownTest() {
+	suite.cache.Close()
+}
+
+func (suite *tieredCacheTestSuite) TestPromotesSecondTierHits() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.l2.Add(ctx, "ipstack", &utils.IPInfo{Address: "1.1.1.1"}))
+
+	info, err := suite.cache.Fetch(ctx, "ipstack", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.NotNil(info)
+
+	info, err = suite.l1.Fetch(ctx, "ipstack", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.NotNil(info)
+}
+
+func (suite *tieredCacheTestSuite) TestPromotionKeepsExpiry() {
+	ctx := context.Background()
+	viper.Set("cache.ttl.ipstack", "1h")
+	defer viper.Set("cache.ttl.ipstack", nil)
+
+	added := time.Now()
+	suite.l2.now = func() time.Time { return added }
+	suite.Require().NoError(suite.l2.Add(ctx, "ipstack", &utils.IPInfo{Address: "1.1.1.1"}))
+
+	// promoted a minute before it expires in the second tier
+	suite.l2.now = func() time.Time { return added.Add(59 * time.Minute) }
+	suite.l1.now = suite.l2.now
+	info, err := suite.cache.Fetch(ctx, "ipstack", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.NotNil(info)
+
+	suite.l1.now = func() time.Time { return added.Add(61 * time.Minute) }
+	info, err = suite.l1.Fetch(ctx, "ipstack", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.Nil(info)
+}
+
+// failingCache misses every fetch and fails every add
+type failingCache struct{}
+
+func (failingCache) Fetch(ctx context.Context, provider string, address string) (*utils.IPInfo, error) {
+	return nil, nil
+}
+
+func (failingCache) Add(ctx context.Context, provider string, ipInfo *utils.IPInfo) error {
+	return errors.New("full")
+}
+
+func (failingCache) Purge(ctx context.Context, provider string, address string) (int, error) {
+	return 0, nil
+}
+
+func (suite *tieredCacheTestSuite) TestPromotionFailure() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.l2.Add(ctx, "ipstack", &utils.IPInfo{Address: "1.1.1.1"}))
+
+	cache, err := NewTieredCache(ctx, failingCache{}, suite.l2)
+	suite.Require().NoError(err)
+
+	// the second tier hit is still returned
+	info, err := cache.Fetch(ctx, "ipstack", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.NotNil(info)
+}
+
+func (suite *tieredCacheTestSuite) TestSecondTierAddFailure() {
+	ctx := context.Background()
+	cache, err := NewTieredCache(ctx, suite.l1, failingCache{})
+	suite.Require().NoError(err)
+
+	suite.Error(cache.Add(ctx, "ipstack", &utils.IPInfo{Address: "1.1.1.1"}))
+
+	// the first tier still serves the entry
+	info, err := cache.Fetch(ctx, "ipstack", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.NotNil(info)
+}
+
+func (suite *tieredCacheTestSuite) TestAddAndPurgeBothTiers() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.cache.Add(ctx, "ipstack", &utils.IPInfo{Address: "1.1.1.1"}))
+
+	for _, tier := range []CacheProvider{suite.l1, suite.l2} {
+		info, err := tier.Fetch(ctx, "ipstack", "1.1.1.1")
+		suite.Require().NoError(err)
+		suite.NotNil(info)
+	}
+
+	purged, err := suite.cache.Purge(ctx, "ipstack", "")
+	suite.Require().NoError(err)
+	suite.Equal(1, purged)
+
+	info, err := suite.cache.Fetch(ctx, "ipstack", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.Nil(info)
+}
+
+func TestTieredCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(tieredCacheTestSuite))
+}
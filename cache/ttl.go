@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"strings"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/spf13/viper"
+)
+
+// remoteProviders answer from a remote API rather than a local database, so
+// their entries don't follow database refreshes and use cache.ttl.remote
+var remoteProviders = map[string]bool{
+	"ipstack": true,
+}
+
+// ttlFor returns how long the entries of provider are kept. It reads
+// cache.ttl.<provider>, then the TTL of the provider that answered (for
+// cascade), then cache.ttl.remote for remote answers and finally
+// cache.ttl.default. 0 keeps them until they are evicted
+func ttlFor(provider string, ipInfo *utils.IPInfo) time.Duration {
+	if viper.IsSet("cache.ttl." + provider) {
+		return viper.GetDuration("cache.ttl." + provider)
+	}
+
+	source := ""
+	if ipInfo != nil {
+		source = strings.ToLower(ipInfo.Source)
+	}
+	if source != "" && source != provider && viper.IsSet("cache.ttl."+source) {
+		return viper.GetDuration("cache.ttl." + source)
+	}
+
+	if (remoteProviders[provider] || remoteProviders[source]) && viper.IsSet("cache.ttl.remote") {
+		return viper.GetDuration("cache.ttl.remote")
+	}
+
+	return viper.GetDuration("cache.ttl.default")
+}
+
+// expiresAt returns when an entry of provider added at now expires, or the
+// zero time if it doesn't
+func expiresAt(provider string, ipInfo *utils.IPInfo, now time.Time) time.Time {
+	ttl := ttlFor(provider, ipInfo)
+	if ttl <= 0 {
+		return time.Time{}
+	}
+
+	return now.Add(ttl)
+}
+
+// earliest returns the earliest of two expiry times, the zero time being
+// never
+func earliest(a time.Time, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+
+	return a
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cloud66-oss/geo/provider"
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// secretSetting matches the configuration keys redacted from /admin/status
+var secretSetting = regexp.MustCompile(`(?i)(key|token|secret|password|dsn|accounts)`)
+
+var startedAt = time.Now()
+
+type adminRefreshResponse struct {
+	Providers map[string]string `json:"providers"`
+}
+
+type adminPurgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+type adminStatusResponse struct {
+	Version   string                          `json:"version"`
+	StartedAt time.Time                       `json:"started_at"`
+	Uptime    string                          `json:"uptime"`
+	Default   string                          `json:"default"`
+	Providers map[string]*adminProviderStatus `json:"providers"`
+	Config    map[string]interface{}          `json:"config"`
+}
+
+type adminProviderStatus struct {
+	Databases []*provider.DatabaseInfo `json:"databases,omitempty"`
+}
+
+// registerAdminRoutes adds the admin routes to e
+func registerAdminRoutes(e *echo.Echo) error {
+	if viper.GetString("admin.token") == "" {
+		return errors.New("admin.token is required when the admin API is enabled")
+	}
+
+	g := e.Group("/admin", adminAuth)
+	g.POST("/refresh", postAdminRefresh)
+	g.POST("/refresh/:provider", postAdminRefresh)
+	g.DELETE("/cache", deleteAdminCache)
+	g.GET("/status", getAdminStatus)
+	g.GET("/overrides/*", getAdminOverride)
+	g.PUT("/overrides/*", putAdminOverride)
+	g.DELETE("/overrides/*", deleteAdminOverride)
+
+	return nil
+}
+
+// startAdminServer serves the admin API on its own port in the background
+func startAdminServer(_ context.Context) (*echo.Echo, error) {
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.Use(middleware.RequestID())
+	e.Use(utils.ZeroLogger(&log.Logger))
+	e.Use(utils.Tracing())
+
+	if err := registerAdminRoutes(e); err != nil {
+		return nil, err
+	}
+
+	address := fmt.Sprintf("%s:%d", viper.GetString("admin.binding"), viper.GetInt("admin.port"))
+	log.Info().Str("address", address).Msg("starting the admin server")
+	go func() {
+		if err := e.Start(address); err != nil {
+			if err != http.ErrServerClosed {
+				log.Error().Err(err).Msg("failed to start the admin server")
+			}
+		}
+	}()
+
+	return e, nil
+}
+
+// adminAuth checks the bearer token against admin.token
+func adminAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(viper.GetString("admin.token"))) != 1 {
+			return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+				Error: "invalid admin token",
+			})
+		}
+
+		return next(c)
+	}
+}
+
+// postAdminRefresh refreshes the requested provider, or all enabled providers
+// when none is given
+func postAdminRefresh(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	names := getEnabledProviderNames()
+	if name := c.Param("provider"); name != "" {
+		if !slices.Contains(providerNames, name) {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+				Error: (&utils.UnknownProviderError{}).Error(),
+			})
+		}
+
+		if !isProviderEnabled(name) {
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{
+				Error: "provider is not enabled",
+			})
+		}
+
+		names = []string{name}
+	}
+
+	response := &adminRefreshResponse{
+		Providers: map[string]string{},
+	}
+	status := http.StatusOK
+	for _, name := range names {
+		// the cascade refreshes its members, which are refreshed on their own
+		if name == "cascade" && len(names) > 1 {
+			continue
+		}
+
+		log.Info().Str("provider", name).Msg("refreshing provider from the admin API")
+		if err := refreshProvider(ctx, name); err != nil {
+			log.Error().Err(err).Str("provider", name).Msg("failed to refresh provider")
+			response.Providers[name] = err.Error()
+			status = http.StatusInternalServerError
+			continue
+		}
+
+		response.Providers[name] = "ok"
+	}
+
+	return c.JSON(status, response)
+}
+
+// deleteAdminCache purges the cache, optionally only the entries of the
+// provider and address query parameters
+func deleteAdminCache(c echo.Context) error {
+	if !viper.GetBool("cache.enabled") {
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{
+			Error: "cache is not enabled",
+		})
+	}
+
+	// entries are cached under the normalized address
+	address := c.QueryParam("address")
+	if _, err := netip.ParsePrefix(address); address != "" && err != nil {
+		normalized, err := utils.NormalizeAddress(address)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+				Error: "invalid address " + address,
+			})
+		}
+		address = normalized
+	}
+
+	ctx := c.Request().Context()
+	cp, release := acquireCache(ctx)
+	purged, err := cp.Purge(ctx, c.QueryParam("provider"), address)
+	release()
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("provider", c.QueryParam("provider")).Str("address", address).Int("purged", purged).Msg("purged cache from the admin API")
+
+	return c.JSON(http.StatusOK, &adminPurgeResponse{
+		Purged: purged,
+	})
+}
+
+func getAdminStatus(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	response := &adminStatusResponse{
+		Version:   utils.Version,
+		StartedAt: startedAt.UTC(),
+		Uptime:    time.Since(startedAt).Truncate(time.Second).String(),
+		Default:   viper.GetString("default"),
+		Providers: map[string]*adminProviderStatus{},
+		Config:    redactSettings(viper.AllSettings()),
+	}
+
+	for _, name := range getEnabledProviderNames() {
+		ipProvider, err := getRequestedProvider(ctx, name)
+		if err != nil {
+			continue
+		}
+
+		status := &adminProviderStatus{}
+		if reporter, ok := ipProvider.(provider.HealthReporter); ok {
+			status.Databases = reporter.Health(ctx).Databases
+		}
+
+		response.Providers[name] = status
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// redactSettings returns a copy of settings with the values of secret keys
+// replaced, and the credentials of URLs removed
+func redactSettings(settings map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		if secretSetting.MatchString(key) {
+			if value != nil && value != "" {
+				value = utils.Redacted
+			}
+			result[key] = value
+			continue
+		}
+
+		result[key] = redactSetting(value)
+	}
+
+	return result
+}
+
+// redactSetting redacts value, walking into the maps and lists of settings
+// such as the lists of databases and feeds
+func redactSetting(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return redactSettings(value)
+	case map[string]string:
+		nested := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			nested[key] = item
+		}
+		return redactSettings(nested)
+	case []interface{}:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = redactSetting(item)
+		}
+		return items
+	case []map[string]interface{}:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = redactSettings(item)
+		}
+		return items
+	case []map[string]string:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = redactSetting(item)
+		}
+		return items
+	case string:
+		return utils.RedactURL(value)
+	default:
+		return value
+	}
+}
@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cloud66-oss/geo/provider"
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/labstack/echo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// adminUserHeader names the person behind an admin request in the audit
+// trail, as the admin token is shared
+const adminUserHeader = "X-Admin-User"
+
+// Override edits recorded in the audit trail
+const (
+	overridePut    = "put"
+	overrideDelete = "delete"
+)
+
+type adminOverrideResponse struct {
+	Network string `json:"network"`
+	// Source is file or store
+	Source   string             `json:"source"`
+	Override *provider.Override `json:"override"`
+	Purged   int                `json:"purged,omitempty"`
+}
+
+// overrideAuditEntry is a line of the overrides audit trail
+type overrideAuditEntry struct {
+	Time    time.Time          `json:"time"`
+	User    string             `json:"user"`
+	Action  string             `json:"action"`
+	Network string             `json:"network"`
+	Old     *provider.Override `json:"old"`
+	New     *provider.Override `json:"new"`
+}
+
+// overrideAuditLock keeps the lines of concurrent edits apart
+var overrideAuditLock sync.Mutex
+
+// adminOverrides returns the overrides provider and the network of the
+// request, or writes the error response and returns nil. Edits are refused
+// with a shared cache, see sharedCache
+func adminOverrides(c echo.Context, edit bool) (*provider.OverridesProvider, netip.Prefix, error) {
+	overrides := getOverridesProvider(c.Request().Context())
+	if overrides == nil {
+		return nil, netip.Prefix{}, c.JSON(http.StatusNotFound, utils.ErrorResponse{
+			Error: "overrides provider is not enabled",
+		})
+	}
+
+	if edit && sharedCache() {
+		return nil, netip.Prefix{}, c.JSON(http.StatusConflict, utils.ErrorResponse{
+			Error: "overrides can't be edited with a shared cache. Edit providers.overrides.file on every replica instead",
+		})
+	}
+
+	// the network can be given as is or with its slash escaped
+	raw, err := url.PathUnescape(c.Param("*"))
+	if err == nil {
+		var network netip.Prefix
+		network, err = provider.ParseOverrideNetwork(raw)
+		if err == nil {
+			return overrides, network, nil
+		}
+	}
+
+	return nil, netip.Prefix{}, c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+		Error: err.Error(),
+	})
+}
+
+func getAdminOverride(c echo.Context) error {
+	overrides, network, err := adminOverrides(c, false)
+	if overrides == nil {
+		return err
+	}
+
+	override, stored := overrides.Get(network)
+	if override == nil {
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{
+			Error: "no override for " + network.String(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, newAdminOverrideResponse(network, override, stored))
+}
+
+// putAdminOverride saves the override of the body for the network of the
+// request
+func putAdminOverride(c echo.Context) error {
+	overrides, network, err := adminOverrides(c, true)
+	if overrides == nil {
+		return err
+	}
+
+	override := &provider.Override{}
+	decoder := json.NewDecoder(c.Request().Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(override); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			Error: "invalid override: " + err.Error(),
+		})
+	}
+	override.Network = network.String()
+	if _, err := override.Prefix(); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	ctx := c.Request().Context()
+	previous, err := overrides.Put(ctx, override)
+	if err != nil {
+		return err
+	}
+
+	response := newAdminOverrideResponse(network, override, true)
+	response.Purged = applyOverrideEdit(c, overridePut, network, previous, override)
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// deleteAdminOverride removes the override of the network of the request
+// from the store
+func deleteAdminOverride(c echo.Context) error {
+	overrides, network, err := adminOverrides(c, true)
+	if overrides == nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	previous, err := overrides.Delete(ctx, network)
+	if err != nil {
+		return err
+	}
+	if previous == nil {
+		message := "no stored override for " + network.String()
+		if override, _ := overrides.Get(network); override != nil {
+			message += ", it is defined in the overrides file"
+		}
+
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{
+			Error: message,
+		})
+	}
+
+	response := newAdminOverrideResponse(network, previous, true)
+	response.Purged = applyOverrideEdit(c, overrideDelete, network, previous, nil)
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// sharedCache is whether the cache is shared between replicas. The overrides
+// store and audit trail are local files, so an edit would only reach the
+// replica serving it while its purge would reach them all
+func sharedCache() bool {
+	return viper.GetBool("cache.enabled") && viper.GetString("cache.type") == "redis"
+}
+
+func newAdminOverrideResponse(network netip.Prefix, override *provider.Override, stored bool) *adminOverrideResponse {
+	source := "file"
+	if stored {
+		source = "store"
+	}
+
+	return &adminOverrideResponse{
+		Network:  network.String(),
+		Source:   source,
+		Override: override,
+	}
+}
+
+// applyOverrideEdit records an edit in the audit trail and purges the cached
+// answers it changes. It returns how many were purged
+func applyOverrideEdit(c echo.Context, action string, network netip.Prefix, previous *provider.Override, override *provider.Override) int {
+	user := c.Request().Header.Get(adminUserHeader)
+	if user == "" {
+		user = c.RealIP()
+	}
+
+	entry := &overrideAuditEntry{
+		Time:    time.Now().UTC(),
+		User:    user,
+		Action:  action,
+		Network: network.String(),
+		Old:     previous,
+		New:     override,
+	}
+
+	// the entry is logged in full so the trail survives a failed write
+	log.Info().Str("user", user).Str("action", action).Str("network", entry.Network).Interface("old", previous).Interface("new", override).Msg("override edited from the admin API")
+	if err := writeOverrideAudit(entry); err != nil {
+		log.Error().Err(err).Msg("failed to write the overrides audit trail")
+	}
+
+	return purgeOverrideNetwork(c.Request().Context(), network)
+}
+
+// writeOverrideAudit appends entry to providers.overrides.audit_file as a
+// JSON line
+func writeOverrideAudit(entry *overrideAuditEntry) error {
+	path := viper.GetString("providers.overrides.audit_file")
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	overrideAuditLock.Lock()
+	defer overrideAuditLock.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+
+	return err
+}
+
+// purgeOverrideNetwork removes the cached answers of the providers using the
+// overrides within network. Patched answers are never cached
+func purgeOverrideNetwork(ctx context.Context, network netip.Prefix) int {
+	if !viper.GetBool("cache.enabled") {
+		return 0
+	}
+
+	providers := []string{"overrides"}
+	if viper.GetBool("providers.cascade.enabled") && slices.Contains(viper.GetStringSlice("providers.cascade.providers"), "overrides") {
+		providers = append(providers, "cascade")
+	}
+
+	cp, release := acquireCache(ctx)
+	defer release()
+
+	purged := 0
+	for _, name := range providers {
+		count, err := cp.Purge(ctx, name, network.String())
+		if err != nil {
+			log.Error().Err(err).Str("provider", name).Str("network", network.String()).Msg("failed to purge overridden network from the cache")
+			continue
+		}
+		purged += count
+	}
+
+	return purged
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloud66-oss/geo/provider"
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/labstack/echo"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type adminOverridesTestSuite struct {
+	suite.Suite
+	cache     *mockCacheProvider
+	overrides *provider.OverridesProvider
+	auditFile string
+	e         *echo.Echo
+}
+
+func (suite *adminOverridesTestSuite) SetupTest() {
+	ctx := context.Background()
+	utils.Container.Clear(ctx)
+
+	dir := suite.T().TempDir()
+	file := filepath.Join(dir, "overrides.yml")
+	suite.Require().NoError(os.WriteFile(file, []byte("overrides:\n  - network: 10.0.0.0/8\n    country: GB\n"), 0600))
+	suite.auditFile = filepath.Join(dir, "audit.log")
+
+	viper.Set("cache.enabled", true)
+	viper.Set("admin.token", "admin-token")
+	viper.Set("providers.overrides.enabled", true)
+	viper.Set("providers.overrides.file", file)
+	viper.Set("providers.overrides.store", filepath.Join(dir, "store.json"))
+	viper.Set("providers.overrides.audit_file", suite.auditFile)
+	viper.Set("providers.overrides.watch", false)
+	viper.Set("providers.cascade.enabled", true)
+	viper.Set("providers.cascade.providers", []string{"overrides", "maxmind"})
+
+	var err error
+	suite.overrides, err = provider.NewOverridesProvider(ctx)
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.overrides.Start(ctx))
+
+	suite.cache = &mockCacheProvider{}
+	utils.Container.Assign(ctx, utils.OverridesProvider, suite.overrides)
+	utils.Container.Assign(ctx, utils.Cache, suite.cache)
+
+	suite.e = echo.New()
+	suite.Require().NoError(registerAdminRoutes(suite.e))
+}
+
+func (suite *adminOverridesTestSuite) TearDownTest() {
+	viper.Set("providers.overrides.enabled", false)
+	viper.Set("providers.cascade.enabled", false)
+	viper.Set("providers.cascade.providers", []string{"maxmind", "ipstack"})
+}
+
+func (suite *adminOverridesTestSuite) request(method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderAuthorization, "Bearer admin-token")
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(adminUserHeader, "support@example.com")
+	rec := httptest.NewRecorder()
+	suite.e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func (suite *adminOverridesTestSuite) response(rec *httptest.ResponseRecorder) *adminOverrideResponse {
+	var body adminOverrideResponse
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+
+	return &body
+}
+
+func (suite *adminOverridesTestSuite) audit() []*overrideAuditEntry {
+	f, err := os.Open(suite.auditFile)
+	suite.Require().NoError(err)
+	defer f.Close()
+
+	var entries []*overrideAuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := &overrideAuditEntry{}
+		suite.Require().NoError(json.Unmarshal(scanner.Bytes(), entry))
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func (suite *adminOverridesTestSuite) TestGet() {
+	rec := suite.request(http.MethodGet, "/admin/overrides/10.0.0.0/8", "")
+	suite.EqualValues(http.StatusOK, rec.Code)
+	body := suite.response(rec)
+	suite.Equal("file", body.Source)
+	suite.Equal("GB", body.Override.Country)
+
+	// escaped and unmasked networks name the same override
+	rec = suite.request(http.MethodGet, "/admin/overrides/10.1.2.3%2F8", "")
+	suite.EqualValues(http.StatusOK, rec.Code)
+
+	rec = suite.request(http.MethodGet, "/admin/overrides/10.0.0.0/16", "")
+	suite.EqualValues(http.StatusNotFound, rec.Code)
+
+	rec = suite.request(http.MethodGet, "/admin/overrides/nope", "")
+	suite.EqualValues(http.StatusBadRequest, rec.Code)
+}
+
+func (suite *adminOverridesTestSuite) TestPut() {
+	suite.cache.On("Purge", mock.Anything, "overrides", "10.1.0.0/16").Return(2, nil)
+	suite.cache.On("Purge", mock.Anything, "cascade", "10.1.0.0/16").Return(1, nil)
+
+	rec := suite.request(http.MethodPut, "/admin/overrides/10.1.0.0/16", `{"country": "ie", "city": "Dublin"}`)
+	suite.EqualValues(http.StatusOK, rec.Code)
+	body := suite.response(rec)
+	suite.Equal("store", body.Source)
+	suite.Equal("IE", body.Override.Country)
+	suite.Equal(3, body.Purged)
+	suite.cache.AssertExpectations(suite.T())
+
+	// lookups see the change straight away
+	info, err := suite.overrides.Lookup(context.Background(), "10.1.2.3", false)
+	suite.Require().NoError(err)
+	suite.Equal("Dublin", info.City.Names["en"])
+
+	entries := suite.audit()
+	suite.Require().Len(entries, 1)
+	suite.Equal("support@example.com", entries[0].User)
+	suite.Equal(overridePut, entries[0].Action)
+	suite.Equal("10.1.0.0/16", entries[0].Network)
+	suite.Nil(entries[0].Old)
+	suite.Equal("Dublin", entries[0].New.City)
+
+	for _, invalid := range []string{`{"country": "Ireland"}`, `{"citty": "Dublin"}`, `not json`} {
+		rec = suite.request(http.MethodPut, "/admin/overrides/10.1.0.0/16", invalid)
+		suite.EqualValues(http.StatusBadRequest, rec.Code, invalid)
+	}
+	suite.Len(suite.audit(), 1)
+}
+
+func (suite *adminOverridesTestSuite) TestDelete() {
+	suite.cache.On("Purge", mock.Anything, mock.Anything, "10.0.0.0/8").Return(0, nil)
+
+	rec := suite.request(http.MethodDelete, "/admin/overrides/10.0.0.0/8", "")
+	suite.EqualValues(http.StatusNotFound, rec.Code)
+
+	rec = suite.request(http.MethodPut, "/admin/overrides/10.0.0.0/8", `{"country": "FR"}`)
+	suite.EqualValues(http.StatusOK, rec.Code)
+
+	rec = suite.request(http.MethodDelete, "/admin/overrides/10.0.0.0/8", "")
+	suite.EqualValues(http.StatusOK, rec.Code)
+	suite.Equal("FR", suite.response(rec).Override.Country)
+
+	// the override of the file is back
+	override, stored := suite.overrides.Get(netip.MustParsePrefix("10.0.0.0/8"))
+	suite.False(stored)
+	suite.Equal("GB", override.Country)
+
+	entries := suite.audit()
+	suite.Require().Len(entries, 2)
+	suite.Equal("GB", entries[0].Old.Country)
+	suite.Equal(overrideDelete, entries[1].Action)
+	suite.Equal("FR", entries[1].Old.Country)
+	suite.Nil(entries[1].New)
+}
+
+func (suite *adminOverridesTestSuite) TestSharedCache() {
+	viper.Set("cache.type", "redis")
+	defer viper.Set("cache.type", "memory")
+
+	rec := suite.request(http.MethodPut, "/admin/overrides/10.1.0.0/16", `{"country": "IE"}`)
+	suite.EqualValues(http.StatusConflict, rec.Code)
+	rec = suite.request(http.MethodDelete, "/admin/overrides/10.0.0.0/8", "")
+	suite.EqualValues(http.StatusConflict, rec.Code)
+
+	override, _ := suite.overrides.Get(netip.MustParsePrefix("10.1.0.0/16"))
+	suite.Nil(override)
+	suite.NoFileExists(suite.auditFile)
+	suite.cache.AssertNotCalled(suite.T(), "Purge", mock.Anything, mock.Anything, mock.Anything)
+
+	// lookups of the overrides still work
+	rec = suite.request(http.MethodGet, "/admin/overrides/10.0.0.0/8", "")
+	suite.EqualValues(http.StatusOK, rec.Code)
+}
+
+func (suite *adminOverridesTestSuite) TestNotEnabled() {
+	viper.Set("providers.overrides.enabled", false)
+
+	rec := suite.request(http.MethodGet, "/admin/overrides/10.0.0.0/8", "")
+	suite.EqualValues(http.StatusNotFound, rec.Code)
+}
+
+func TestAdminOverridesTestSuite(t *testing.T) {
+	suite.Run(t, new(adminOverridesTestSuite))
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/labstack/echo"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type adminTestSuite struct {
+	suite.Suite
+	provider *mockProvider
+	cache    *mockCacheProvider
+	e        *echo.Echo
+}
+
+func (suite *adminTestSuite) SetupTest() {
+	ctx := context.Background()
+	utils.Container.Clear(ctx)
+	viper.Set("default", "maxmind")
+	viper.Set("cache.enabled", true)
+	viper.Set("providers.maxmind.enabled", true)
+	viper.Set("providers.maxmind.license_key", "secret-license")
+	viper.Set("admin.token", "admin-token")
+
+	suite.provider = &mockProvider{}
+	suite.cache = &mockCacheProvider{}
+	utils.Container.Assign(ctx, utils.MaxMindProvider, suite.provider)
+	utils.Container.Assign(ctx, utils.Cache, suite.cache)
+
+	suite.e = echo.New()
+	suite.Require().NoError(registerAdminRoutes(suite.e))
+}
+
+func (suite *adminTestSuite) TearDownTest() {
+	viper.Set("providers.maxmind.license_key", "")
+	viper.Set("cache.redis.url", "")
+	viper.Set("providers.ip2location.download.url", "")
+	viper.Set("providers.mmdb.databases", nil)
+}
+
+func (suite *adminTestSuite) request(method string, path string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	suite.e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func (suite *adminTestSuite) TestRequiresToken() {
+	viper.Set("admin.token", "")
+	suite.Error(registerAdminRoutes(echo.New()))
+}
+
+func (suite *adminTestSuite) TestUnauthorized() {
+	for _, token := range []string{"", "wrong"} {
+		rec := suite.request(http.MethodGet, "/admin/status", token)
+		suite.EqualValues(http.StatusUnauthorized, rec.Code)
+	}
+
+	// the token has to come with its scheme
+	req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+	req.Header.Set(echo.HeaderAuthorization, "admin-token")
+	rec := httptest.NewRecorder()
+	suite.e.ServeHTTP(rec, req)
+	suite.EqualValues(http.StatusUnauthorized, rec.Code)
+}
+
+func (suite *adminTestSuite) TestRefreshProvider() {
+	suite.provider.On("Refresh", mock.Anything).Return(nil)
+
+	rec := suite.request(http.MethodPost, "/admin/refresh/maxmind", "admin-token")
+	suite.EqualValues(http.StatusOK, rec.Code)
+	suite.provider.AssertNumberOfCalls(suite.T(), "Refresh", 1)
+
+	var body adminRefreshResponse
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+	suite.Equal(map[string]string{"maxmind": "ok"}, body.Providers)
+	suite.NotNil(lastRefresh("maxmind"))
+}
+
+func (suite *adminTestSuite) TestRefreshAll() {
+	suite.provider.On("Refresh", mock.Anything).Return(errors.New("download failed"))
+
+	rec := suite.request(http.MethodPost, "/admin/refresh", "admin-token")
+	suite.EqualValues(http.StatusInternalServerError, rec.Code)
+
+	var body adminRefreshResponse
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+	suite.Equal(map[string]string{"maxmind": "download failed"}, body.Providers)
+}
+
+func (suite *adminTestSuite) TestRefreshUnknownProvider() {
+	rec := suite.request(http.MethodPost, "/admin/refresh/unknown", "admin-token")
+	suite.EqualValues(http.StatusBadRequest, rec.Code)
+
+	rec = suite.request(http.MethodPost, "/admin/refresh/globio", "admin-token")
+	suite.EqualValues(http.StatusNotFound, rec.Code)
+}
+
+func (suite *adminTestSuite) TestPurgeCache() {
+	suite.cache.On("Purge", mock.Anything, "maxmind", "1.1.1.1").Return(1, nil)
+
+	rec := suite.request(http.MethodDelete, "/admin/cache?provider=maxmind&address=1.1.1.1", "admin-token")
+	suite.EqualValues(http.StatusOK, rec.Code)
+	suite.JSONEq(`{"purged":1}`, rec.Body.String())
+
+	// addresses are purged in the form they're cached in
+	suite.cache.On("Purge", mock.Anything, "", "2001:db8::1").Return(1, nil).Once()
+	suite.cache.On("Purge", mock.Anything, "", "8.8.8.8").Return(1, nil).Once()
+	suite.cache.On("Purge", mock.Anything, "", "8.8.8.0/24").Return(2, nil).Once()
+	for _, address := range []string{"2001:DB8::1", "::ffff:8.8.8.8", "8.8.8.0/24"} {
+		rec = suite.request(http.MethodDelete, "/admin/cache?address="+url.QueryEscape(address), "admin-token")
+		suite.EqualValues(http.StatusOK, rec.Code, address)
+	}
+	suite.cache.AssertExpectations(suite.T())
+
+	rec = suite.request(http.MethodDelete, "/admin/cache?address=nope", "admin-token")
+	suite.EqualValues(http.StatusBadRequest, rec.Code)
+}
+
+func (suite *adminTestSuite) TestStatus() {
+	rec := suite.request(http.MethodGet, "/admin/status", "admin-token")
+	suite.EqualValues(http.StatusOK, rec.Code)
+	suite.NotContains(rec.Body.String(), "secret-license")
+	suite.NotContains(rec.Body.String(), "admin-token")
+
+	var body adminStatusResponse
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+	suite.Equal(utils.Version, body.Version)
+	suite.Equal("maxmind", body.Default)
+	suite.Contains(body.Providers, "maxmind")
+	suite.Equal(utils.Redacted, body.Config["admin"].(map[string]interface{})["token"])
+}
+
+func (suite *adminTestSuite) TestStatusURLs() {
+	viper.Set("cache.redis.url", "redis://:redis-password@redis:6379/0")
+	viper.Set("providers.ip2location.download.url", "https://www.ip2location.com/download/?token=ip2-token&file=DB11LITEBINIPV6")
+	viper.Set("providers.mmdb.databases", []interface{}{
+		map[string]interface{}{"name": "ipinfo", "url": "https://ipinfo.io/data/free/country_asn.mmdb?token=ipinfo-token"},
+	})
+
+	rec := suite.request(http.MethodGet, "/admin/status", "admin-token")
+	suite.EqualValues(http.StatusOK, rec.Code)
+	suite.NotContains(rec.Body.String(), "redis-password")
+	suite.NotContains(rec.Body.String(), "ip2-token")
+	suite.NotContains(rec.Body.String(), "ipinfo-token")
+
+	var body adminStatusResponse
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+	redis := body.Config["cache"].(map[string]interface{})["redis"].(map[string]interface{})
+	suite.Equal("redis://REDACTED@redis:6379/0", redis["url"])
+	databases := body.Config["providers"].(map[string]interface{})["mmdb"].(map[string]interface{})["databases"].([]interface{})
+	suite.Equal("https://ipinfo.io/data/free/country_asn.mmdb?token=REDACTED", databases[0].(map[string]interface{})["url"])
+}
+
+func TestAdminTestSuite(t *testing.T) {
+	suite.Run(t, new(adminTestSuite))
+}
@@ -0,0 +1,352 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/fsnotify/fsnotify"
+	"github.com/labstack/echo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"golang.org/x/time/rate"
+)
+
+const apiKeyContextKey = "api_key"
+
+// API key request outcomes reported in metrics
+const (
+	apiKeyAllowed       = "allowed"
+	apiKeyUnauthorized  = "unauthorized"
+	apiKeyRateLimited   = "rate_limited"
+	apiKeyQuotaExceeded = "quota_exceeded"
+	apiKeyForbidden     = "forbidden"
+)
+
+// apiKey is a key allowed to use the HTTP API, as configured under auth.keys
+// or in auth.keys_file
+type apiKey struct {
+	Name string `mapstructure:"name"`
+	Key  string `mapstructure:"key"`
+	// RPS is the number of requests per second allowed. 0 is unlimited
+	RPS   float64 `mapstructure:"rps"`
+	Burst int     `mapstructure:"burst"`
+	// DailyQuota is the number of requests allowed per UTC day. 0 is unlimited
+	DailyQuota int `mapstructure:"daily_quota"`
+	// Providers the key can use. Empty allows all
+	Providers []string `mapstructure:"providers"`
+	// Fields of the lookup result returned to the key. Empty returns all
+	Fields []string `mapstructure:"fields"`
+}
+
+// apiKeyUsage tracks the rate and quota of a key. It survives reloads of the
+// keys as long as the key keeps its name
+type apiKeyUsage struct {
+	sync.Mutex
+	limiter *rate.Limiter
+	day     string
+	used    int
+}
+
+type apiKeyEntry struct {
+	key   *apiKey
+	usage *apiKeyUsage
+}
+
+type apiKeyStore struct {
+	sync.RWMutex
+	bySecret map[string]*apiKeyEntry
+	byName   map[string]*apiKeyEntry
+	now      func() time.Time
+}
+
+var apiKeys = &apiKeyStore{
+	bySecret: map[string]*apiKeyEntry{},
+	byName:   map[string]*apiKeyEntry{},
+	now:      time.Now,
+}
+
+var watchApiKeysFileOnce sync.Once
+
+// configureApiKeys loads the API keys from the configuration and the keys
+// file, and starts watching the keys file for changes
+func configureApiKeys(ctx context.Context) error {
+	keys, err := readApiKeys()
+	if err != nil {
+		return err
+	}
+
+	apiKeys.load(keys)
+	log.Info().Int("keys", len(keys)).Msg("loaded API keys")
+
+	if file := viper.GetString("auth.keys_file"); file != "" {
+		watchApiKeysFileOnce.Do(func() {
+			watchApiKeysFile(file)
+		})
+	}
+
+	return nil
+}
+
+func readApiKeys() ([]*apiKey, error) {
+	var keys []*apiKey
+	if err := viper.UnmarshalKey("auth.keys", &keys); err != nil {
+		return nil, err
+	}
+
+	if file := viper.GetString("auth.keys_file"); file != "" {
+		v := viper.New()
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, err
+		}
+
+		var fileKeys []*apiKey
+		if err := v.UnmarshalKey("keys", &fileKeys); err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	names := map[string]bool{}
+	secrets := map[string]bool{}
+	for _, key := range keys {
+		if key.Name == "" || key.Key == "" {
+			return nil, errors.New("API keys need a name and a key")
+		}
+		if names[key.Name] || secrets[key.Key] {
+			return nil, fmt.Errorf("API key %s is defined more than once", key.Name)
+		}
+
+		names[key.Name] = true
+		secrets[key.Key] = true
+	}
+
+	return keys, nil
+}
+
+func watchApiKeysFile(file string) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		log.Error().Err(err).Str("file", file).Msg("failed to watch API keys file")
+		return
+	}
+
+	v.OnConfigChange(func(e fsnotify.Event) {
+		log.Info().Str("file", e.Name).Msg("reloading API keys")
+		if err := configureApiKeys(context.Background()); err != nil {
+			log.Error().Err(err).Msg("failed to reload API keys, keeping the previous ones")
+		}
+	})
+	v.WatchConfig()
+}
+
+// load replaces the keys in the store
+func (s *apiKeyStore) load(keys []*apiKey) {
+	s.Lock()
+	defer s.Unlock()
+
+	bySecret := make(map[string]*apiKeyEntry, len(keys))
+	byName := make(map[string]*apiKeyEntry, len(keys))
+	for _, key := range keys {
+		limit := rate.Inf
+		if key.RPS > 0 {
+			limit = rate.Limit(key.RPS)
+		}
+		burst := key.Burst
+		if burst <= 0 {
+			burst = int(math.Max(1, math.Ceil(key.RPS)))
+		}
+
+		usage := &apiKeyUsage{}
+		if previous, ok := s.byName[key.Name]; ok {
+			usage = previous.usage
+		}
+
+		usage.Lock()
+		if usage.limiter == nil {
+			usage.limiter = rate.NewLimiter(limit, burst)
+		} else {
+			usage.limiter.SetLimit(limit)
+			usage.limiter.SetBurst(burst)
+		}
+		usage.Unlock()
+
+		entry := &apiKeyEntry{key: key, usage: usage}
+		bySecret[key.Key] = entry
+		byName[key.Name] = entry
+	}
+
+	s.bySecret = bySecret
+	s.byName = byName
+}
+
+func (s *apiKeyStore) get(secret string) *apiKeyEntry {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.bySecret[secret]
+}
+
+// allow counts a request against the rate and daily quota of entry. When the
+// request is refused, it returns the outcome and how long to wait
+func (s *apiKeyStore) allow(entry *apiKeyEntry) (string, time.Duration) {
+	now := s.now().UTC()
+	usage := entry.usage
+
+	usage.Lock()
+	defer usage.Unlock()
+
+	day := now.Format(time.DateOnly)
+	if usage.day != day {
+		usage.day = day
+		usage.used = 0
+	}
+
+	if entry.key.DailyQuota > 0 && usage.used >= entry.key.DailyQuota {
+		tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		return apiKeyQuotaExceeded, tomorrow.Sub(now)
+	}
+
+	reservation := usage.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return apiKeyRateLimited, delay
+	}
+
+	usage.used++
+
+	return apiKeyAllowed, 0
+}
+
+// admit checks a request with secret for provider. Requests are only counted
+// against the rate and daily quota of the key once it is known to be allowed
+// to use provider. It returns the key and the outcome, with how long to wait
+// when the request is refused
+func (s *apiKeyStore) admit(secret string, provider string) (*apiKey, string, time.Duration) {
+	entry := s.get(secret)
+	if secret == "" || entry == nil {
+		utils.APIKeyRequests.WithLabelValues("", apiKeyUnauthorized).Inc()
+		return nil, apiKeyUnauthorized, 0
+	}
+
+	outcome, wait := apiKeyForbidden, time.Duration(0)
+	if len(entry.key.Providers) == 0 || slices.Contains(entry.key.Providers, provider) {
+		outcome, wait = s.allow(entry)
+	}
+	utils.APIKeyRequests.WithLabelValues(entry.key.Name, outcome).Inc()
+
+	return entry.key, outcome, wait
+}
+
+// apiKeyAuth checks the API key of requests when auth.enabled is set. The key
+// is read from the auth.header header, falling back to the auth.query
+// query parameter. requestedProvider returns the provider the request is for
+func apiKeyAuth(requestedProvider func(c echo.Context) string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !viper.GetBool("auth.enabled") {
+				return next(c)
+			}
+
+			secret := c.Request().Header.Get(viper.GetString("auth.header"))
+			if secret == "" {
+				secret = c.QueryParam(viper.GetString("auth.query"))
+			}
+
+			key, outcome, wait := apiKeys.admit(secret, requestedProvider(c))
+			switch outcome {
+			case apiKeyUnauthorized:
+				return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{
+					Error: "invalid API key",
+				})
+			case apiKeyForbidden:
+				return c.JSON(http.StatusForbidden, utils.ErrorResponse{
+					Error: "provider not allowed for this API key",
+				})
+			case apiKeyRateLimited, apiKeyQuotaExceeded:
+				c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+
+				message := "rate limit exceeded"
+				if outcome == apiKeyQuotaExceeded {
+					message = "daily quota exceeded"
+				}
+
+				return c.JSON(http.StatusTooManyRequests, utils.ErrorResponse{
+					Error: message,
+				})
+			}
+
+			c.Set(apiKeyContextKey, key)
+
+			return next(c)
+		}
+	}
+}
+
+// requestApiKey returns the API key of the request, or nil when auth isn't
+// enabled
+func requestApiKey(c echo.Context) *apiKey {
+	key, _ := c.Get(apiKeyContextKey).(*apiKey)
+
+	return key
+}
+
+// filterFields returns ip with only the fields key is allowed to see
+func filterFields(key *apiKey, ip *utils.IPInfo) *utils.IPInfo {
+	if key == nil || len(key.Fields) == 0 || ip == nil {
+		return ip
+	}
+
+	filtered := &utils.IPInfo{
+		Address:         ip.Address,
+		OriginalAddress: ip.OriginalAddress,
+		Network:         ip.Network,
+		Source:          ip.Source,
+		IsFallback:      ip.IsFallback,
+		Reserved:        ip.Reserved,
+		Tags:            ip.Tags,
+	}
+
+	for _, field := range key.Fields {
+		switch field {
+		case "city":
+			filtered.City = ip.City
+			filtered.HasCity = ip.HasCity
+		case "continent":
+			filtered.Continent = ip.Continent
+		case "country":
+			filtered.Country = ip.Country
+		case "location":
+			filtered.Location = ip.Location
+		case "postal":
+			filtered.Postal = ip.Postal
+		case "registered_country":
+			filtered.RegisteredCountry = ip.RegisteredCountry
+		case "represented_country":
+			filtered.RepresentedCountry = ip.RepresentedCountry
+		case "subdivisions":
+			filtered.Subdivisions = ip.Subdivisions
+		case "traits":
+			filtered.Traits = ip.Traits
+		case "asn":
+			filtered.ASN = ip.ASN
+			filtered.HasASN = ip.HasASN
+		case "anonymous_ip":
+			filtered.AnonymousIP = ip.AnonymousIP
+			filtered.HasAnonymousIP = ip.HasAnonymousIP
+		case "cloud":
+			filtered.Cloud = ip.Cloud
+		}
+	}
+
+	return filtered
+}
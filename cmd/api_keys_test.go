@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/labstack/echo"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type apiKeysTestSuite struct {
+	suite.Suite
+	provider *mockProvider
+	now      time.Time
+	e        *echo.Echo
+}
+
+func (suite *apiKeysTestSuite) SetupTest() {
+	ctx := context.Background()
+	utils.Container.Clear(ctx)
+	viper.Set("default", "maxmind")
+	viper.Set("cache.enabled", false)
+	viper.Set("auth.enabled", true)
+	viper.Set("auth.header", "X-API-Key")
+	viper.Set("auth.query", "api_key")
+	viper.Set("auth.keys_file", "")
+	viper.Set("auth.keys", []map[string]interface{}{
+		{"name": "internal", "key": "internal-key"},
+		{"name": "limited", "key": "limited-key", "rps": 1, "daily_quota": 2},
+		{"name": "partner", "key": "partner-key", "providers": []string{"dbip"}},
+		{"name": "countries", "key": "countries-key", "fields": []string{"country"}},
+	})
+
+	suite.now = time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC)
+	apiKeys.now = func() time.Time { return suite.now }
+	apiKeys.load(nil)
+	suite.Require().NoError(configureApiKeys(ctx))
+
+	suite.provider = &mockProvider{}
+	suite.provider.On("Lookup", mock.Anything, "8.8.8.8").Return(&utils.IPInfo{
+		Address: "8.8.8.8",
+		Country: &utils.Country{IsoCode: "US"},
+		City:    &utils.City{Names: map[string]string{"en": "Mountain View"}},
+		HasCity: true,
+	}, nil)
+	utils.Container.Assign(ctx, utils.MaxMindProvider, suite.provider)
+
+	suite.e = echo.New()
+	suite.e.GET("/v1/ip/:address", getIP, apiKeyAuth(getIPProvider))
+}
+
+func (suite *apiKeysTestSuite) TearDownTest() {
+	viper.Set("auth.enabled", false)
+	apiKeys.now = time.Now
+}
+
+func (suite *apiKeysTestSuite) get(path string, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	suite.e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func (suite *apiKeysTestSuite) TestMissingKey() {
+	rec := suite.get("/v1/ip/8.8.8.8", "")
+	suite.EqualValues(http.StatusUnauthorized, rec.Code)
+
+	rec = suite.get("/v1/ip/8.8.8.8", "wrong")
+	suite.EqualValues(http.StatusUnauthorized, rec.Code)
+}
+
+func (suite *apiKeysTestSuite) TestHeaderAndQuery() {
+	counter := utils.APIKeyRequests.WithLabelValues("internal", apiKeyAllowed)
+	before := testutil.ToFloat64(counter)
+
+	rec := suite.get("/v1/ip/8.8.8.8", "internal-key")
+	suite.EqualValues(http.StatusOK, rec.Code)
+
+	rec = suite.get("/v1/ip/8.8.8.8?api_key=internal-key", "")
+	suite.EqualValues(http.StatusOK, rec.Code)
+
+	suite.EqualValues(before+2, testutil.ToFloat64(counter))
+}
+
+func (suite *apiKeysTestSuite) TestRateLimit() {
+	rec := suite.get("/v1/ip/8.8.8.8", "limited-key")
+	suite.EqualValues(http.StatusOK, rec.Code)
+
+	rec = suite.get("/v1/ip/8.8.8.8", "limited-key")
+	suite.EqualValues(http.StatusTooManyRequests, rec.Code)
+	suite.Equal("1", rec.Header().Get("Retry-After"))
+}
+
+func (suite *apiKeysTestSuite) TestDailyQuota() {
+	for i := 0; i < 2; i++ {
+		rec := suite.get("/v1/ip/8.8.8.8", "limited-key")
+		suite.EqualValues(http.StatusOK, rec.Code)
+		suite.now = suite.now.Add(time.Second)
+	}
+
+	rec := suite.get("/v1/ip/8.8.8.8", "limited-key")
+	suite.EqualValues(http.StatusTooManyRequests, rec.Code)
+	suite.Equal("3598", rec.Header().Get("Retry-After"))
+
+	// the quota resets at midnight UTC
+	suite.now = suite.now.Add(time.Hour)
+	rec = suite.get("/v1/ip/8.8.8.8", "limited-key")
+	suite.EqualValues(http.StatusOK, rec.Code)
+}
+
+func (suite *apiKeysTestSuite) TestAllowedProviders() {
+	rec := suite.get("/v1/ip/8.8.8.8", "partner-key")
+	suite.EqualValues(http.StatusForbidden, rec.Code)
+	suite.provider.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+
+	// refused requests don't use up the quota
+	viper.Set("auth.keys", []map[string]interface{}{
+		{"name": "partner", "key": "partner-key", "providers": []string{"maxmind"}, "daily_quota": 1},
+	})
+	suite.Require().NoError(configureApiKeys(context.Background()))
+
+	rec = suite.get("/v1/ip/8.8.8.8?provider=dbip", "partner-key")
+	suite.EqualValues(http.StatusForbidden, rec.Code)
+	rec = suite.get("/v1/ip/8.8.8.8", "partner-key")
+	suite.EqualValues(http.StatusOK, rec.Code)
+}
+
+func (suite *apiKeysTestSuite) TestAllowedFields() {
+	rec := suite.get("/v1/ip/8.8.8.8", "countries-key")
+	suite.EqualValues(http.StatusOK, rec.Code)
+
+	var body utils.IPInfo
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+	suite.Equal("8.8.8.8", body.Address)
+	suite.Equal("US", body.Country.IsoCode)
+	suite.Nil(body.City)
+	suite.False(body.HasCity)
+}
+
+func (suite *apiKeysTestSuite) TestKeysFile() {
+	file := filepath.Join(suite.T().TempDir(), "keys.yml")
+	suite.Require().NoError(os.WriteFile(file, []byte("keys:\n  - name: from-file\n    key: file-key\n"), 0600))
+	viper.Set("auth.keys_file", file)
+
+	keys, err := readApiKeys()
+	suite.Require().NoError(err)
+	apiKeys.load(keys)
+
+	rec := suite.get("/v1/ip/8.8.8.8", "file-key")
+	suite.EqualValues(http.StatusOK, rec.Code)
+
+	rec = suite.get("/v1/ip/8.8.8.8", "internal-key")
+	suite.EqualValues(http.StatusOK, rec.Code)
+}
+
+func (suite *apiKeysTestSuite) TestDuplicateKeys() {
+	viper.Set("auth.keys", []map[string]interface{}{
+		{"name": "one", "key": "same"},
+		{"name": "two", "key": "same"},
+	})
+
+	_, err := readApiKeys()
+	suite.Error(err)
+}
+
+func TestApiKeysTestSuite(t *testing.T) {
+	suite.Run(t, new(apiKeysTestSuite))
+}
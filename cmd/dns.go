@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/cloud66-oss/geo/provider"
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// The DNS interface answers TXT queries for reversed addresses under the
+// configured zone, in the style of the Team Cymru DNS service:
+//
+//	8.8.8.8.origin.geo.local  -> "15169 | 8.8.8.0/24 | US"
+//	8.8.8.8.country.geo.local -> "US"
+//	8.8.8.8.asn.geo.local     -> "15169 | GOOGLE"
+//	8.8.8.8.city.geo.local    -> "Mountain View"
+//
+// IPv6 addresses use the nibble format of ip6.arpa.
+
+const (
+	dnsOrigin  = "origin"
+	dnsCountry = "country"
+	dnsASN     = "asn"
+	dnsCity    = "city"
+)
+
+var errDnsName = errors.New("not a valid query name")
+
+type dnsHandler struct {
+	zone         string
+	providerName string
+	ttl          uint32
+}
+
+func newDnsHandler(zone string, providerName string, ttl uint32) *dnsHandler {
+	return &dnsHandler{
+		zone:         dns.CanonicalName(zone),
+		providerName: providerName,
+		ttl:          ttl,
+	}
+}
+
+// startDnsServers starts serving DNS over UDP and TCP in the background
+func startDnsServers(_ context.Context) ([]*dns.Server, error) {
+	providerName := viper.GetString("dns.provider")
+	if providerName == "" {
+		providerName = viper.GetString("default")
+	}
+
+	handler := newDnsHandler(viper.GetString("dns.zone"), providerName, uint32(viper.GetDuration("dns.ttl").Seconds()))
+	address := fmt.Sprintf("%s:%d", viper.GetString("dns.binding"), viper.GetInt("dns.port"))
+
+	packetConn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		packetConn.Close()
+		return nil, err
+	}
+
+	servers := []*dns.Server{
+		{PacketConn: packetConn, Handler: handler},
+		{Listener: listener, Handler: handler},
+	}
+
+	log.Info().Str("address", address).Str("zone", handler.zone).Str("provider", providerName).Msg("starting the DNS server")
+	for _, server := range servers {
+		go func(server *dns.Server) {
+			if err := server.ActivateAndServe(); err != nil {
+				log.Error().Err(err).Msg("failed to start the DNS server")
+			}
+		}(server)
+	}
+
+	return servers, nil
+}
+
+func stopDnsServers(ctx context.Context, servers []*dns.Server) {
+	for _, server := range servers {
+		if err := server.ShutdownContext(ctx); err != nil {
+			log.Warn().Err(err).Msg("failed to stop the DNS server")
+		}
+	}
+}
+
+func (h *dnsHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	if len(r.Question) != 1 {
+		m.SetRcode(r, dns.RcodeFormatError)
+		w.WriteMsg(m)
+		return
+	}
+
+	question := r.Question[0]
+	name := dns.CanonicalName(question.Name)
+	if !dns.IsSubDomain(h.zone, name) {
+		m.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(m)
+		return
+	}
+
+	kind, address, err := parseDnsName(strings.TrimSuffix(name, "."+h.zone))
+	if err != nil {
+		m.SetRcode(r, dns.RcodeNameError)
+		w.WriteMsg(m)
+		return
+	}
+
+	// the name exists but only has TXT records
+	if question.Qtype != dns.TypeTXT && question.Qtype != dns.TypeANY {
+		w.WriteMsg(m)
+		return
+	}
+
+	ctx := context.Background()
+	txt, err := h.answer(ctx, kind, address)
+	if err != nil {
+		switch err.(type) {
+		case *utils.IpAddressError, *utils.ReservedAddressError:
+			m.SetRcode(r, dns.RcodeNameError)
+		default:
+			log.Error().Err(err).Str("name", name).Msg("failed to answer DNS query")
+			m.SetRcode(r, dns.RcodeServerFailure)
+		}
+		w.WriteMsg(m)
+		return
+	}
+
+	if txt != "" {
+		m.Answer = append(m.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{
+				Name:   question.Name,
+				Rrtype: dns.TypeTXT,
+				Class:  dns.ClassINET,
+				Ttl:    h.ttl,
+			},
+			Txt: []string{txt},
+		})
+	}
+
+	w.WriteMsg(m)
+}
+
+func (h *dnsHandler) answer(ctx context.Context, kind string, address string) (string, error) {
+	info, err := lookupIP(ctx, h.providerName, address)
+	if err != nil {
+		return "", err
+	}
+
+	if info == nil {
+		return "", nil
+	}
+
+	switch kind {
+	case dnsCountry:
+		return countryCode(info.Country), nil
+	case dnsCity:
+		if info.City == nil {
+			return "", nil
+		}
+		return info.City.Names["en"], nil
+	case dnsASN:
+		if !info.HasASN || info.ASN == nil || info.ASN.AutonomousSystemNumber == 0 {
+			return "", nil
+		}
+		return fmt.Sprintf("%d | %s", info.ASN.AutonomousSystemNumber, info.ASN.AutonomousSystemOrganization), nil
+	default:
+		asn := "NA"
+		if info.HasASN && info.ASN != nil && info.ASN.AutonomousSystemNumber != 0 {
+			asn = strconv.FormatUint(uint64(info.ASN.AutonomousSystemNumber), 10)
+		}
+
+		prefix, err := h.prefix(ctx, address)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("%s | %s | %s", asn, prefix, countryCode(info.Country)), nil
+	}
+}
+
+// prefix returns the network address belongs to from the provider's ASN
+// database when it has one
+func (h *dnsHandler) prefix(ctx context.Context, address string) (string, error) {
+	ipProvider, err := getRequestedProvider(ctx, h.providerName)
+	if err != nil {
+		return "", err
+	}
+
+	asnProvider, ok := ipProvider.(provider.ASNProvider)
+	if !ok {
+		return "NA", nil
+	}
+
+	_, network, err := asnProvider.LookupASN(ctx, address)
+	if err != nil {
+		return "", err
+	}
+
+	if network == nil {
+		return "NA", nil
+	}
+
+	return network.String(), nil
+}
+
+// parseDnsName parses the part of a query name in front of the zone into the
+// kind of answer and the address it is for
+func parseDnsName(name string) (string, string, error) {
+	labels := dns.SplitDomainName(name)
+	if len(labels) < 2 {
+		return "", "", errDnsName
+	}
+
+	kind := labels[len(labels)-1]
+	switch kind {
+	case dnsOrigin, dnsCountry, dnsASN, dnsCity:
+	default:
+		return "", "", errDnsName
+	}
+
+	reversed := labels[:len(labels)-1]
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+
+	switch len(reversed) {
+	case 4:
+		address := strings.Join(reversed, ".")
+		if ip := net.ParseIP(address); ip == nil || ip.To4() == nil {
+			return "", "", errDnsName
+		}
+
+		return kind, address, nil
+	case 32:
+		// ip6.arpa style nibbles
+		var b strings.Builder
+		for i, nibble := range reversed {
+			if len(nibble) != 1 || !strings.Contains("0123456789abcdef", nibble) {
+				return "", "", errDnsName
+			}
+			if i > 0 && i%4 == 0 {
+				b.WriteByte(':')
+			}
+			b.WriteString(nibble)
+		}
+
+		ip := net.ParseIP(b.String())
+		if ip == nil {
+			return "", "", errDnsName
+		}
+
+		return kind, ip.String(), nil
+	default:
+		return "", "", errDnsName
+	}
+}
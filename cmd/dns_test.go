@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/miekg/dns"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type dnsTestSuite struct {
+	suite.Suite
+	provider *mockAsnProvider
+	server   *dns.Server
+	address  string
+}
+
+func (suite *dnsTestSuite) SetupTest() {
+	ctx := context.Background()
+	utils.Container.Clear(ctx)
+	viper.Set("cache.enabled", false)
+
+	suite.provider = &mockAsnProvider{}
+	utils.Container.Assign(ctx, utils.MaxMindProvider, suite.provider)
+
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+
+	started := make(chan struct{})
+	suite.server = &dns.Server{
+		PacketConn:        packetConn,
+		Handler:           newDnsHandler("geo.local", "maxmind", 60),
+		NotifyStartedFunc: func() { close(started) },
+	}
+	suite.address = packetConn.LocalAddr().String()
+
+	go suite.server.ActivateAndServe()
+	<-started
+}
+
+func (suite *dnsTestSuite) TearDownTest() {
+	suite.server.Shutdown()
+}
+
+func (suite *dnsTestSuite) query(name string, qtype uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+
+	r, _, err := new(dns.Client).Exchange(m, suite.address)
+	suite.Require().NoError(err)
+
+	return r
+}
+
+func (suite *dnsTestSuite) txt(r *dns.Msg) []string {
+	var result []string
+	for _, rr := range r.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			result = append(result, txt.Txt...)
+		}
+	}
+
+	return result
+}
+
+func (suite *dnsTestSuite) TestIPv4() {
+	_, network, _ := net.ParseCIDR("8.8.8.0/24")
+	suite.provider.On("Lookup", mock.Anything, "8.8.8.8").Return(&utils.IPInfo{
+		Address: "8.8.8.8",
+		Country: &utils.Country{IsoCode: "US"},
+		HasASN:  true,
+		ASN:     &utils.ASN{AutonomousSystemNumber: 15169, AutonomousSystemOrganization: "GOOGLE"},
+	}, nil)
+	suite.provider.On("LookupASN", mock.Anything, "8.8.8.8").Return(&utils.ASN{AutonomousSystemNumber: 15169}, network, nil)
+
+	r := suite.query("8.8.8.8.origin.geo.local", dns.TypeTXT)
+	suite.EqualValues(dns.RcodeSuccess, r.Rcode)
+	suite.EqualValues([]string{"15169 | 8.8.8.0/24 | US"}, suite.txt(r))
+
+	r = suite.query("8.8.8.8.country.geo.local", dns.TypeTXT)
+	suite.EqualValues([]string{"US"}, suite.txt(r))
+
+	r = suite.query("8.8.8.8.asn.geo.local", dns.TypeTXT)
+	suite.EqualValues([]string{"15169 | GOOGLE"}, suite.txt(r))
+}
+
+func (suite *dnsTestSuite) TestIPv6Nibbles() {
+	suite.provider.On("Lookup", mock.Anything, "2001:4860:4860::8888").Return(&utils.IPInfo{
+		Address: "2001:4860:4860::8888",
+		Country: &utils.Country{IsoCode: "US"},
+	}, nil)
+
+	reverse, err := dns.ReverseAddr("2001:4860:4860::8888")
+	suite.Require().NoError(err)
+
+	r := suite.query(strings.TrimSuffix(reverse, "ip6.arpa.")+"country.geo.local", dns.TypeTXT)
+	suite.EqualValues(dns.RcodeSuccess, r.Rcode)
+	suite.EqualValues([]string{"US"}, suite.txt(r))
+}
+
+func (suite *dnsTestSuite) TestInvalidNames() {
+	r := suite.query("8.8.8.origin.geo.local", dns.TypeTXT)
+	suite.EqualValues(dns.RcodeNameError, r.Rcode)
+
+	r = suite.query("8.8.8.8.nope.geo.local", dns.TypeTXT)
+	suite.EqualValues(dns.RcodeNameError, r.Rcode)
+
+	r = suite.query("8.8.8.8.origin.example.com", dns.TypeTXT)
+	suite.EqualValues(dns.RcodeRefused, r.Rcode)
+
+	suite.provider.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+}
+
+func (suite *dnsTestSuite) TestOtherTypes() {
+	r := suite.query("8.8.8.8.origin.geo.local", dns.TypeA)
+	suite.EqualValues(dns.RcodeSuccess, r.Rcode)
+	suite.Empty(r.Answer)
+}
+
+func TestDnsTestSuite(t *testing.T) {
+	suite.Run(t, new(dnsTestSuite))
+}
@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/netip"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/labstack/echo"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// The GeoIP2 web service compatible API lets the official MaxMind client
+// libraries use geo by only changing the host they point at.
+// See https://dev.maxmind.com/geoip/docs/web-services/responses
+
+const (
+	geoIPCountry  = "country"
+	geoIPCity     = "city"
+	geoIPInsights = "insights"
+)
+
+type geoIPError struct {
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+type geoIPNamedRecord struct {
+	GeoNameID uint              `json:"geoname_id,omitempty"`
+	Names     map[string]string `json:"names,omitempty"`
+}
+
+type geoIPContinent struct {
+	Code      string            `json:"code,omitempty"`
+	GeoNameID uint              `json:"geoname_id,omitempty"`
+	Names     map[string]string `json:"names,omitempty"`
+}
+
+type geoIPCountryRecord struct {
+	GeoNameID         uint              `json:"geoname_id,omitempty"`
+	IsInEuropeanUnion bool              `json:"is_in_european_union,omitempty"`
+	IsoCode           string            `json:"iso_code,omitempty"`
+	Names             map[string]string `json:"names,omitempty"`
+	Type              string            `json:"type,omitempty"`
+}
+
+type geoIPLocation struct {
+	AccuracyRadius uint16  `json:"accuracy_radius,omitempty"`
+	Latitude       float64 `json:"latitude,omitempty"`
+	Longitude      float64 `json:"longitude,omitempty"`
+	MetroCode      uint    `json:"metro_code,omitempty"`
+	TimeZone       string  `json:"time_zone,omitempty"`
+}
+
+type geoIPPostal struct {
+	Code string `json:"code,omitempty"`
+}
+
+type geoIPSubdivision struct {
+	GeoNameID uint              `json:"geoname_id,omitempty"`
+	IsoCode   string            `json:"iso_code,omitempty"`
+	Names     map[string]string `json:"names,omitempty"`
+}
+
+type geoIPTraits struct {
+	IPAddress                    string `json:"ip_address"`
+	Network                      string `json:"network,omitempty"`
+	AutonomousSystemNumber       uint   `json:"autonomous_system_number,omitempty"`
+	AutonomousSystemOrganization string `json:"autonomous_system_organization,omitempty"`
+	IsAnonymous                  bool   `json:"is_anonymous,omitempty"`
+	IsAnonymousProxy             bool   `json:"is_anonymous_proxy,omitempty"`
+	IsAnonymousVPN               bool   `json:"is_anonymous_vpn,omitempty"`
+	IsHostingProvider            bool   `json:"is_hosting_provider,omitempty"`
+	IsPublicProxy                bool   `json:"is_public_proxy,omitempty"`
+	IsSatelliteProvider          bool   `json:"is_satellite_provider,omitempty"`
+	IsTorExitNode                bool   `json:"is_tor_exit_node,omitempty"`
+}
+
+type geoIPResponse struct {
+	City               *geoIPNamedRecord   `json:"city,omitempty"`
+	Continent          *geoIPContinent     `json:"continent,omitempty"`
+	Country            *geoIPCountryRecord `json:"country,omitempty"`
+	Location           *geoIPLocation      `json:"location,omitempty"`
+	Postal             *geoIPPostal        `json:"postal,omitempty"`
+	RegisteredCountry  *geoIPCountryRecord `json:"registered_country,omitempty"`
+	RepresentedCountry *geoIPCountryRecord `json:"represented_country,omitempty"`
+	Subdivisions       []*geoIPSubdivision `json:"subdivisions,omitempty"`
+	Traits             *geoIPTraits        `json:"traits"`
+}
+
+// registerGeoIPRoutes adds the GeoIP2 web service compatible routes to e
+func registerGeoIPRoutes(e *echo.Echo) {
+	if len(viper.GetStringMapString("geoip.accounts")) == 0 {
+		log.Warn().Msg("no geoip accounts configured, the GeoIP2 compatible API refuses all requests")
+	}
+
+	g := e.Group("/geoip/v2.1", geoIPAuth)
+	g.GET("/country/:address", geoIPHandler(geoIPCountry))
+	g.GET("/city/:address", geoIPHandler(geoIPCity))
+	g.GET("/insights/:address", geoIPHandler(geoIPInsights))
+}
+
+// geoIPAuth checks the Basic auth account ID and license key against the
+// configured geoip.accounts. All requests are refused when no accounts are
+// configured
+func geoIPAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		accountID, licenseKey, ok := c.Request().BasicAuth()
+		if !ok || accountID == "" {
+			return geoIPErrorResponse(c, http.StatusUnauthorized, "ACCOUNT_ID_REQUIRED", "You have not supplied a MaxMind account ID in the Authorization header.")
+		}
+
+		if licenseKey == "" {
+			return geoIPErrorResponse(c, http.StatusUnauthorized, "LICENSE_KEY_REQUIRED", "You have not supplied a MaxMind license key in the Authorization header.")
+		}
+
+		accounts := viper.GetStringMapString("geoip.accounts")
+		if len(accounts) == 0 {
+			return geoIPErrorResponse(c, http.StatusUnauthorized, "AUTHORIZATION_INVALID", "No accounts are configured.")
+		}
+
+		expected, ok := accounts[accountID]
+		if !ok {
+			return geoIPErrorResponse(c, http.StatusUnauthorized, "ACCOUNT_ID_UNKNOWN", "We could not find your account ID.")
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(licenseKey)) != 1 {
+			return geoIPErrorResponse(c, http.StatusUnauthorized, "AUTHORIZATION_INVALID", "The license key you have provided is not valid.")
+		}
+
+		return next(c)
+	}
+}
+
+func geoIPHandler(service string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		address := c.Param("address")
+		if address == "me" {
+			address = c.RealIP()
+		}
+
+		ip, err := netip.ParseAddr(address)
+		if err != nil {
+			return geoIPErrorResponse(c, http.StatusBadRequest, "IP_ADDRESS_INVALID", "The value \""+address+"\" is not a valid IP address.")
+		}
+
+		if isReservedAddress(ip) {
+			return geoIPErrorResponse(c, http.StatusBadRequest, "IP_ADDRESS_RESERVED", "The IP address you provided ("+address+") is not a public IP address.")
+		}
+
+		requestedProvider := viper.GetString("geoip.provider")
+		if requestedProvider == "" {
+			requestedProvider = viper.GetString("default")
+		}
+		setRequestProvider(c, requestedProvider)
+
+		info, err := lookupIP(c.Request().Context(), requestedProvider, address)
+		if err != nil {
+			switch err.(type) {
+			case *utils.IpAddressError:
+				return geoIPErrorResponse(c, http.StatusBadRequest, "IP_ADDRESS_INVALID", "The value \""+address+"\" is not a valid IP address.")
+			case *utils.ReservedAddressError:
+				return geoIPErrorResponse(c, http.StatusBadRequest, "IP_ADDRESS_RESERVED", "The IP address you provided ("+address+") is not a public IP address.")
+			case *utils.UnknownProviderError:
+				return geoIPErrorResponse(c, http.StatusInternalServerError, "PROVIDER_UNKNOWN", err.Error())
+			default:
+				return geoIPErrorResponse(c, http.StatusInternalServerError, "LOOKUP_FAILED", err.Error())
+			}
+		}
+
+		if info == nil || (countryCode(info.Country) == "" && countryCode(info.RegisteredCountry) == "" && (info.ASN == nil || info.ASN.AutonomousSystemNumber == 0)) {
+			return geoIPErrorResponse(c, http.StatusNotFound, "IP_ADDRESS_NOT_FOUND", "The address \""+address+"\" is not in our database.")
+		}
+
+		body, err := json.Marshal(toGeoIPResponse(service, info))
+		if err != nil {
+			return err
+		}
+
+		return c.Blob(http.StatusOK, "application/vnd.maxmind.com-"+service+"+json; charset=UTF-8; version=2.1", body)
+	}
+}
+
+func geoIPErrorResponse(c echo.Context, status int, code string, message string) error {
+	body, err := json.Marshal(geoIPError{Code: code, Error: message})
+	if err != nil {
+		return err
+	}
+
+	return c.Blob(status, "application/vnd.maxmind.com-error+json; charset=UTF-8; version=2.0", body)
+}
+
+// isReservedAddress reports whether ip is a private or otherwise non-public
+// address MaxMind would refuse with IP_ADDRESS_RESERVED
+func isReservedAddress(ip netip.Addr) bool {
+	return utils.LookupReservedRange(ip) != nil
+}
+
+func countryCode(country *utils.Country) string {
+	if country == nil {
+		return ""
+	}
+
+	return country.IsoCode
+}
+
+func toGeoIPResponse(service string, info *utils.IPInfo) *geoIPResponse {
+	response := &geoIPResponse{
+		Continent:          toGeoIPContinent(info.Continent),
+		Country:            toGeoIPCountry(info.Country),
+		RegisteredCountry:  toGeoIPCountry(info.RegisteredCountry),
+		RepresentedCountry: toGeoIPCountry(info.RepresentedCountry),
+		Traits: &geoIPTraits{
+			IPAddress: info.Address,
+			Network:   info.Network,
+		},
+	}
+
+	if info.Traits != nil {
+		response.Traits.IsAnonymousProxy = info.Traits.IsAnonymousProxy
+		response.Traits.IsSatelliteProvider = info.Traits.IsSatelliteProvider
+	}
+
+	if service == geoIPCountry {
+		return response
+	}
+
+	if info.City != nil && (info.City.GeoNameID != 0 || len(info.City.Names) > 0) {
+		response.City = &geoIPNamedRecord{
+			GeoNameID: info.City.GeoNameID,
+			Names:     info.City.Names,
+		}
+	}
+
+	if info.Location != nil && *info.Location != (utils.Location{}) {
+		response.Location = &geoIPLocation{
+			AccuracyRadius: info.Location.AccuracyRadius,
+			Latitude:       info.Location.Latitude,
+			Longitude:      info.Location.Longitude,
+			MetroCode:      info.Location.MetroCode,
+			TimeZone:       info.Location.TimeZone,
+		}
+	}
+
+	if info.Postal != nil && info.Postal.Code != "" {
+		response.Postal = &geoIPPostal{
+			Code: info.Postal.Code,
+		}
+	}
+
+	for _, subdivision := range info.Subdivisions {
+		if subdivision == nil {
+			continue
+		}
+
+		response.Subdivisions = append(response.Subdivisions, &geoIPSubdivision{
+			GeoNameID: subdivision.GeoNameID,
+			IsoCode:   subdivision.IsoCode,
+			Names:     subdivision.Names,
+		})
+	}
+
+	if info.HasASN && info.ASN != nil {
+		response.Traits.AutonomousSystemNumber = info.ASN.AutonomousSystemNumber
+		response.Traits.AutonomousSystemOrganization = info.ASN.AutonomousSystemOrganization
+	}
+
+	// the anonymizer flags are only part of the insights service
+	if service == geoIPInsights && info.HasAnonymousIP && info.AnonymousIP != nil {
+		response.Traits.IsAnonymous = info.AnonymousIP.IsAnonymous
+		response.Traits.IsAnonymousVPN = info.AnonymousIP.IsAnonymousVPN
+		response.Traits.IsHostingProvider = info.AnonymousIP.IsHostingProvider
+		response.Traits.IsPublicProxy = info.AnonymousIP.IsPublicProxy
+		response.Traits.IsTorExitNode = info.AnonymousIP.IsTorExitNode
+	}
+
+	return response
+}
+
+func toGeoIPContinent(continent *utils.Continent) *geoIPContinent {
+	if continent == nil || (continent.Code == "" && continent.GeoNameID == 0) {
+		return nil
+	}
+
+	return &geoIPContinent{
+		Code:      continent.Code,
+		GeoNameID: continent.GeoNameID,
+		Names:     continent.Names,
+	}
+}
+
+func toGeoIPCountry(country *utils.Country) *geoIPCountryRecord {
+	if country == nil || (country.IsoCode == "" && country.GeoNameID == 0) {
+		return nil
+	}
+
+	return &geoIPCountryRecord{
+		GeoNameID:         country.GeoNameID,
+		IsInEuropeanUnion: country.IsInEuropeanUnion,
+		IsoCode:           country.IsoCode,
+		Names:             country.Names,
+		Type:              country.Type,
+	}
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/labstack/echo"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type geoIPTestSuite struct {
+	suite.Suite
+	provider *mockProvider
+	e        *echo.Echo
+}
+
+func (suite *geoIPTestSuite) SetupTest() {
+	ctx := context.Background()
+	utils.Container.Clear(ctx)
+	viper.Set("cache.enabled", false)
+	viper.Set("default", "maxmind")
+	viper.Set("geoip.accounts", map[string]string{"42": "secret"})
+
+	suite.provider = &mockProvider{}
+	utils.Container.Assign(ctx, utils.MaxMindProvider, suite.provider)
+
+	suite.e = echo.New()
+	registerGeoIPRoutes(suite.e)
+}
+
+func (suite *geoIPTestSuite) request(path string, accountID string, licenseKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if accountID != "" {
+		req.SetBasicAuth(accountID, licenseKey)
+	}
+	rec := httptest.NewRecorder()
+	suite.e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func (suite *geoIPTestSuite) errorCode(rec *httptest.ResponseRecorder) string {
+	var body geoIPError
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+
+	return body.Code
+}
+
+func (suite *geoIPTestSuite) TestAuth() {
+	rec := suite.request("/geoip/v2.1/country/8.8.8.8", "", "")
+	suite.EqualValues(http.StatusUnauthorized, rec.Code)
+	suite.EqualValues("ACCOUNT_ID_REQUIRED", suite.errorCode(rec))
+
+	rec = suite.request("/geoip/v2.1/country/8.8.8.8", "1", "secret")
+	suite.EqualValues(http.StatusUnauthorized, rec.Code)
+	suite.EqualValues("ACCOUNT_ID_UNKNOWN", suite.errorCode(rec))
+
+	rec = suite.request("/geoip/v2.1/country/8.8.8.8", "42", "wrong")
+	suite.EqualValues(http.StatusUnauthorized, rec.Code)
+	suite.EqualValues("AUTHORIZATION_INVALID", suite.errorCode(rec))
+
+	// no accounts refuse everyone rather than let anyone in
+	viper.Set("geoip.accounts", map[string]string{})
+	rec = suite.request("/geoip/v2.1/country/8.8.8.8", "42", "secret")
+	suite.EqualValues(http.StatusUnauthorized, rec.Code)
+	suite.EqualValues("AUTHORIZATION_INVALID", suite.errorCode(rec))
+	suite.provider.AssertNotCalled(suite.T(), "Lookup")
+}
+
+func (suite *geoIPTestSuite) TestInvalidAndReserved() {
+	rec := suite.request("/geoip/v2.1/city/nope", "42", "secret")
+	suite.EqualValues(http.StatusBadRequest, rec.Code)
+	suite.EqualValues("IP_ADDRESS_INVALID", suite.errorCode(rec))
+
+	rec = suite.request("/geoip/v2.1/city/10.0.0.1", "42", "secret")
+	suite.EqualValues(http.StatusBadRequest, rec.Code)
+	suite.EqualValues("IP_ADDRESS_RESERVED", suite.errorCode(rec))
+
+	rec = suite.request("/geoip/v2.1/city/100.64.1.1", "42", "secret")
+	suite.EqualValues(http.StatusBadRequest, rec.Code)
+	suite.EqualValues("IP_ADDRESS_RESERVED", suite.errorCode(rec))
+
+	suite.provider.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+}
+
+func (suite *geoIPTestSuite) TestNotFound() {
+	suite.provider.On("Lookup", mock.Anything, "1.0.0.1").Return(&utils.IPInfo{Address: "1.0.0.1", Country: &utils.Country{}}, nil)
+
+	rec := suite.request("/geoip/v2.1/country/1.0.0.1", "42", "secret")
+	suite.EqualValues(http.StatusNotFound, rec.Code)
+	suite.EqualValues("IP_ADDRESS_NOT_FOUND", suite.errorCode(rec))
+}
+
+func (suite *geoIPTestSuite) TestServices() {
+	suite.provider.On("Lookup", mock.Anything, "8.8.8.8").Return(&utils.IPInfo{
+		Address:        "8.8.8.8",
+		Country:        &utils.Country{IsoCode: "US", GeoNameID: 6252001},
+		City:           &utils.City{GeoNameID: 5375480, Names: map[string]string{"en": "Mountain View"}},
+		HasASN:         true,
+		ASN:            &utils.ASN{AutonomousSystemNumber: 15169, AutonomousSystemOrganization: "GOOGLE"},
+		AnonymousIP:    &utils.AnonymousIP{IsHostingProvider: true},
+		HasAnonymousIP: true,
+	}, nil)
+
+	rec := suite.request("/geoip/v2.1/country/8.8.8.8", "42", "secret")
+	suite.EqualValues(http.StatusOK, rec.Code)
+	suite.Contains(rec.Header().Get(echo.HeaderContentType), "application/vnd.maxmind.com-country+json")
+
+	var country geoIPResponse
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &country))
+	suite.EqualValues("US", country.Country.IsoCode)
+	suite.EqualValues("8.8.8.8", country.Traits.IPAddress)
+	suite.Nil(country.City)
+
+	// the looked up address is reported rather than the requested one
+	rec = suite.request("/geoip/v2.1/country/::ffff:8.8.8.8", "42", "secret")
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &country))
+	suite.EqualValues("8.8.8.8", country.Traits.IPAddress)
+
+	rec = suite.request("/geoip/v2.1/city/8.8.8.8", "42", "secret")
+	var city geoIPResponse
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &city))
+	suite.EqualValues("Mountain View", city.City.Names["en"])
+	suite.EqualValues(15169, city.Traits.AutonomousSystemNumber)
+	suite.False(city.Traits.IsHostingProvider)
+
+	rec = suite.request("/geoip/v2.1/insights/8.8.8.8", "42", "secret")
+	var insights geoIPResponse
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &insights))
+	suite.True(insights.Traits.IsHostingProvider)
+}
+
+func TestGeoIPTestSuite(t *testing.T) {
+	suite.Run(t, new(geoIPTestSuite))
+}
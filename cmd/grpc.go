@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cloud66-oss/geo/rpc"
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// geoServer implements the gRPC GeoService on top of lookupIP so it shares
+// provider selection and caching with the HTTP API
+type geoServer struct {
+	rpc.UnimplementedGeoServiceServer
+}
+
+// grpcApiKey is the context key of the API key of a call
+type grpcApiKey struct{}
+
+// providerRequest is a GeoService request, which names its provider
+type providerRequest interface {
+	GetProvider() string
+}
+
+// authorizedStream is a GeoService stream whose context carries the API key
+// of its request
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *geoServer) Lookup(ctx context.Context, req *rpc.LookupRequest) (*rpc.LookupResponse, error) {
+	ip, err := lookupIP(ctx, requestedProviderName(req.GetProvider()), req.GetAddress())
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	return &rpc.LookupResponse{
+		Info: rpc.FromIPInfo(filterFields(callApiKey(ctx), ip)),
+	}, nil
+}
+
+func (s *geoServer) BatchLookup(ctx context.Context, req *rpc.BatchLookupRequest) (*rpc.BatchLookupResponse, error) {
+	if err := checkBatchSize(req); err != nil {
+		return nil, err
+	}
+	providerName := requestedProviderName(req.GetProvider())
+
+	response := &rpc.BatchLookupResponse{
+		Results: make([]*rpc.LookupResult, 0, len(req.GetAddresses())),
+	}
+
+	for _, address := range req.GetAddresses() {
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+
+		result, err := lookupResult(ctx, providerName, address)
+		if err != nil {
+			return nil, err
+		}
+
+		response.Results = append(response.Results, result)
+	}
+
+	return response, nil
+}
+
+func (s *geoServer) StreamLookup(req *rpc.BatchLookupRequest, stream rpc.GeoService_StreamLookupServer) error {
+	if err := checkBatchSize(req); err != nil {
+		return err
+	}
+	ctx := stream.Context()
+	providerName := requestedProviderName(req.GetProvider())
+
+	for _, address := range req.GetAddresses() {
+		if err := ctx.Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+
+		result, err := lookupResult(ctx, providerName, address)
+		if err != nil {
+			return err
+		}
+
+		if err := stream.Send(result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lookupResult wraps a single lookup of a batch. Errors that only concern the
+// address are reported in the result so the rest of the batch can carry on
+func lookupResult(ctx context.Context, providerName string, address string) (*rpc.LookupResult, error) {
+	result := &rpc.LookupResult{
+		Address: address,
+	}
+
+	ip, err := lookupIP(ctx, providerName, address)
+	if err != nil {
+		if _, ok := err.(*utils.UnknownProviderError); ok {
+			return nil, toStatusError(err)
+		}
+
+		result.Error = err.Error()
+		return result, nil
+	}
+
+	result.Info = rpc.FromIPInfo(filterFields(callApiKey(ctx), ip))
+
+	return result, nil
+}
+
+// checkBatchSize refuses batches of more than grpc.max_batch addresses
+func checkBatchSize(req *rpc.BatchLookupRequest) error {
+	if limit := viper.GetInt("grpc.max_batch"); limit > 0 && len(req.GetAddresses()) > limit {
+		return status.Errorf(codes.InvalidArgument, "batches are limited to %d addresses", limit)
+	}
+
+	return nil
+}
+
+// authorizeCall checks the API key of a GeoService call for the provider of
+// req when auth.enabled is set, as apiKeyAuth does for the HTTP API. The key
+// is read from the auth.header metadata. It returns ctx with the key
+func authorizeCall(ctx context.Context, req any) (context.Context, error) {
+	request, ok := req.(providerRequest)
+	if !ok || !viper.GetBool("auth.enabled") {
+		return ctx, nil
+	}
+
+	var secret string
+	if values := metadata.ValueFromIncomingContext(ctx, viper.GetString("auth.header")); len(values) > 0 {
+		secret = values[0]
+	}
+
+	key, outcome, _ := apiKeys.admit(secret, requestedProviderName(request.GetProvider()))
+	switch outcome {
+	case apiKeyUnauthorized:
+		return nil, status.Error(codes.Unauthenticated, "invalid API key")
+	case apiKeyForbidden:
+		return nil, status.Error(codes.PermissionDenied, "provider not allowed for this API key")
+	case apiKeyRateLimited:
+		return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	case apiKeyQuotaExceeded:
+		return nil, status.Error(codes.ResourceExhausted, "daily quota exceeded")
+	}
+
+	return context.WithValue(ctx, grpcApiKey{}, key), nil
+}
+
+// callApiKey returns the API key of the call, or nil when auth isn't enabled
+func callApiKey(ctx context.Context) *apiKey {
+	key, _ := ctx.Value(grpcApiKey{}).(*apiKey)
+
+	return key
+}
+
+// isGeoServiceMethod is whether method belongs to the GeoService rather than
+// the health or reflection services, which don't need a key
+func isGeoServiceMethod(method string) bool {
+	return strings.HasPrefix(method, "/"+rpc.GeoService_ServiceDesc.ServiceName+"/")
+}
+
+func apiKeyUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !isGeoServiceMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	ctx, err := authorizeCall(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// apiKeyStreamInterceptor checks the API key of a stream once its request is
+// received, as the provider is only known then
+func apiKeyStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !isGeoServiceMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+
+	return handler(srv, &authorizedStream{ServerStream: ss, ctx: ss.Context()})
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authorizedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	ctx, err := authorizeCall(s.ServerStream.Context(), m)
+	if err != nil {
+		return err
+	}
+	s.ctx = ctx
+
+	return nil
+}
+
+// newGrpcServer returns a server for the GeoService with the API key checks
+func newGrpcServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(apiKeyUnaryInterceptor),
+		grpc.ChainStreamInterceptor(apiKeyStreamInterceptor),
+	)
+
+	server := grpc.NewServer(opts...)
+	rpc.RegisterGeoServiceServer(server, &geoServer{})
+
+	return server
+}
+
+func requestedProviderName(name string) string {
+	if name == "" {
+		return viper.GetString("default")
+	}
+
+	return name
+}
+
+func toStatusError(err error) error {
+	switch err.(type) {
+	case *utils.UnknownProviderError, *utils.IpAddressError, *utils.ReservedAddressError:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case *utils.ProviderUnavailableError:
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// startGrpcServer starts serving the gRPC API in the background. The returned
+// server should be stopped with stopGrpcServer
+func startGrpcServer(_ context.Context) (*grpc.Server, *health.Server, error) {
+	address := fmt.Sprintf("%s:%d", viper.GetString("grpc.binding"), viper.GetInt("grpc.port"))
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	server := newGrpcServer(grpc.StatsHandler(otelgrpc.NewServerHandler()))
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(rpc.GeoService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	reflection.Register(server)
+
+	log.Info().Str("address", address).Msg("starting the gRPC server")
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Error().Err(err).Msg("failed to start the gRPC server")
+		}
+	}()
+
+	return server, healthServer, nil
+}
+
+// stopGrpcServer drains the in-flight calls and forcefully stops the server
+// if they don't finish before ctx is done
+func stopGrpcServer(ctx context.Context, server *grpc.Server, healthServer *health.Server) {
+	// let the clients know we're going away before draining in-flight calls
+	healthServer.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Warn().Msg("timed out waiting for gRPC calls to finish")
+		server.Stop()
+	}
+}
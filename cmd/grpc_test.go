@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/cloud66-oss/geo/rpc"
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type grpcTestSuite struct {
+	suite.Suite
+	provider *mockProvider
+	server   *grpc.Server
+	client   rpc.GeoServiceClient
+	conn     *grpc.ClientConn
+}
+
+func (suite *grpcTestSuite) SetupTest() {
+	ctx := context.Background()
+	utils.Container.Clear(ctx)
+	viper.Set("cache.enabled", false)
+	viper.Set("default", "maxmind")
+
+	suite.provider = &mockProvider{}
+	utils.Container.Assign(ctx, utils.MaxMindProvider, suite.provider)
+
+	listener := bufconn.Listen(1024 * 1024)
+	suite.server = newGrpcServer()
+	healthpb.RegisterHealthServer(suite.server, health.NewServer())
+	go suite.server.Serve(listener)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	suite.Require().NoError(err)
+
+	suite.conn = conn
+	suite.client = rpc.NewGeoServiceClient(conn)
+}
+
+func (suite *grpcTestSuite) TearDownTest() {
+	suite.conn.Close()
+	suite.server.Stop()
+}
+
+func (suite *grpcTestSuite) TestLookup() {
+	suite.provider.On("Lookup", mock.Anything, "1.1.1.1").Return(&utils.IPInfo{
+		Address: "1.1.1.1",
+		Country: &utils.Country{IsoCode: "AU"},
+	}, nil)
+
+	resp, err := suite.client.Lookup(context.Background(), &rpc.LookupRequest{Address: "1.1.1.1"})
+	if suite.NoError(err) {
+		suite.EqualValues("1.1.1.1", resp.GetInfo().GetAddress())
+		suite.EqualValues("AU", resp.GetInfo().GetCountry().GetIsoCode())
+	}
+}
+
+func (suite *grpcTestSuite) TestLookupFields() {
+	suite.provider.On("Lookup", mock.Anything, "1.1.1.1").Return(&utils.IPInfo{
+		Address: "1.1.1.1",
+		Network: "1.1.1.0/24",
+		Traits:  &utils.Traits{ISP: "Cloudflare", Domain: "cloudflare.com", UsageType: "CDN"},
+		Tags:    []string{"dns"},
+		Cloud:   &utils.Cloud{Provider: "cloudflare", Service: "dns", Region: "global"},
+	}, nil)
+
+	resp, err := suite.client.Lookup(context.Background(), &rpc.LookupRequest{Address: "::ffff:1.1.1.1"})
+	if suite.NoError(err) {
+		info := resp.GetInfo()
+		suite.EqualValues("1.1.1.1", info.GetAddress())
+		suite.EqualValues("::ffff:1.1.1.1", info.GetOriginalAddress())
+		suite.EqualValues("1.1.1.0/24", info.GetNetwork())
+		suite.EqualValues("Cloudflare", info.GetTraits().GetIsp())
+		suite.EqualValues("cloudflare.com", info.GetTraits().GetDomain())
+		suite.EqualValues("CDN", info.GetTraits().GetUsageType())
+		suite.EqualValues([]string{"dns"}, info.GetTags())
+		suite.EqualValues("cloudflare", info.GetCloud().GetProvider())
+		suite.EqualValues("dns", info.GetCloud().GetService())
+		suite.EqualValues("global", info.GetCloud().GetRegion())
+	}
+}
+
+func (suite *grpcTestSuite) TestLookupInvalidAddress() {
+	suite.provider.On("Lookup", mock.Anything, "nope").Return(nil, &utils.IpAddressError{})
+
+	_, err := suite.client.Lookup(context.Background(), &rpc.LookupRequest{Address: "nope"})
+	suite.EqualValues(codes.InvalidArgument, status.Code(err))
+}
+
+func (suite *grpcTestSuite) TestLookupReserved() {
+	resp, err := suite.client.Lookup(context.Background(), &rpc.LookupRequest{Address: "100.64.1.1"})
+	if suite.NoError(err) {
+		suite.EqualValues("reserved", resp.GetInfo().GetSource())
+		suite.EqualValues("100.64.0.0/10", resp.GetInfo().GetNetwork())
+		suite.EqualValues("100.64.0.0/10", resp.GetInfo().GetReserved().GetNetwork())
+		suite.EqualValues("Shared Address Space", resp.GetInfo().GetReserved().GetName())
+		suite.EqualValues("RFC 6598", resp.GetInfo().GetReserved().GetRfc())
+	}
+
+	viper.Set("reserved.response", "error")
+	defer viper.Set("reserved.response", "reserved")
+
+	_, err = suite.client.Lookup(context.Background(), &rpc.LookupRequest{Address: "fe80::1"})
+	suite.EqualValues(codes.InvalidArgument, status.Code(err))
+	suite.provider.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+}
+
+func (suite *grpcTestSuite) TestLookupUnknownProvider() {
+	_, err := suite.client.Lookup(context.Background(), &rpc.LookupRequest{Address: "1.1.1.1", Provider: "nope"})
+	suite.EqualValues(codes.InvalidArgument, status.Code(err))
+}
+
+func (suite *grpcTestSuite) TestBatchLookup() {
+	suite.provider.On("Lookup", mock.Anything, "1.1.1.1").Return(&utils.IPInfo{Address: "1.1.1.1"}, nil)
+	suite.provider.On("Lookup", mock.Anything, "nope").Return(nil, &utils.IpAddressError{})
+
+	resp, err := suite.client.BatchLookup(context.Background(), &rpc.BatchLookupRequest{Addresses: []string{"1.1.1.1", "nope"}})
+	if suite.NoError(err) && suite.Len(resp.GetResults(), 2) {
+		suite.EqualValues("1.1.1.1", resp.GetResults()[0].GetInfo().GetAddress())
+		suite.Empty(resp.GetResults()[0].GetError())
+		suite.Nil(resp.GetResults()[1].GetInfo())
+		suite.NotEmpty(resp.GetResults()[1].GetError())
+	}
+}
+
+func (suite *grpcTestSuite) TestStreamLookup() {
+	suite.provider.On("Lookup", mock.Anything, "1.1.1.1").Return(&utils.IPInfo{Address: "1.1.1.1"}, nil)
+	suite.provider.On("Lookup", mock.Anything, "2.2.2.2").Return(&utils.IPInfo{Address: "2.2.2.2"}, nil)
+
+	stream, err := suite.client.StreamLookup(context.Background(), &rpc.BatchLookupRequest{Addresses: []string{"1.1.1.1", "2.2.2.2"}})
+	suite.Require().NoError(err)
+
+	var addresses []string
+	for {
+		result, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		addresses = append(addresses, result.GetInfo().GetAddress())
+	}
+
+	suite.EqualValues([]string{"1.1.1.1", "2.2.2.2"}, addresses)
+}
+
+func (suite *grpcTestSuite) TestBatchLimit() {
+	viper.Set("grpc.max_batch", 1)
+	defer viper.Set("grpc.max_batch", 100)
+
+	_, err := suite.client.BatchLookup(context.Background(), &rpc.BatchLookupRequest{Addresses: []string{"1.1.1.1", "2.2.2.2"}})
+	suite.EqualValues(codes.InvalidArgument, status.Code(err))
+
+	stream, err := suite.client.StreamLookup(context.Background(), &rpc.BatchLookupRequest{Addresses: []string{"1.1.1.1", "2.2.2.2"}})
+	suite.Require().NoError(err)
+	_, err = stream.Recv()
+	suite.EqualValues(codes.InvalidArgument, status.Code(err))
+	suite.provider.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+}
+
+func (suite *grpcTestSuite) TestApiKeys() {
+	viper.Set("auth.enabled", true)
+	viper.Set("auth.header", "X-API-Key")
+	viper.Set("auth.keys_file", "")
+	viper.Set("auth.keys", []map[string]interface{}{
+		{"name": "partner", "key": "partner-key", "providers": []string{"dbip"}},
+		{"name": "countries", "key": "countries-key", "fields": []string{"country"}},
+	})
+	suite.Require().NoError(configureApiKeys(context.Background()))
+	defer viper.Set("auth.enabled", false)
+
+	suite.provider.On("Lookup", mock.Anything, "1.1.1.1").Return(&utils.IPInfo{
+		Address: "1.1.1.1",
+		Country: &utils.Country{IsoCode: "AU"},
+		City:    &utils.City{Names: map[string]string{"en": "Sydney"}},
+	}, nil)
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+
+	_, err := suite.client.Lookup(context.Background(), &rpc.LookupRequest{Address: "1.1.1.1"})
+	suite.EqualValues(codes.Unauthenticated, status.Code(err))
+
+	_, err = suite.client.BatchLookup(withKey("partner-key"), &rpc.BatchLookupRequest{Addresses: []string{"1.1.1.1"}})
+	suite.EqualValues(codes.PermissionDenied, status.Code(err))
+
+	stream, err := suite.client.StreamLookup(withKey("wrong"), &rpc.BatchLookupRequest{Addresses: []string{"1.1.1.1"}})
+	suite.Require().NoError(err)
+	_, err = stream.Recv()
+	suite.EqualValues(codes.Unauthenticated, status.Code(err))
+	suite.provider.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+
+	// the fields are filtered as for the HTTP API
+	resp, err := suite.client.Lookup(withKey("countries-key"), &rpc.LookupRequest{Address: "1.1.1.1"})
+	if suite.NoError(err) {
+		suite.EqualValues("AU", resp.GetInfo().GetCountry().GetIsoCode())
+		suite.Nil(resp.GetInfo().GetCity())
+	}
+
+	stream, err = suite.client.StreamLookup(withKey("countries-key"), &rpc.BatchLookupRequest{Addresses: []string{"1.1.1.1"}})
+	suite.Require().NoError(err)
+	result, err := stream.Recv()
+	if suite.NoError(err) {
+		suite.EqualValues("AU", result.GetInfo().GetCountry().GetIsoCode())
+		suite.Nil(result.GetInfo().GetCity())
+	}
+
+	// health checks don't need a key
+	_, err = healthpb.NewHealthClient(suite.conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	suite.NoError(err)
+}
+
+func TestGrpcTestSuite(t *testing.T) {
+	suite.Run(t, new(grpcTestSuite))
+}
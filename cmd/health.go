@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloud66-oss/geo/provider"
+	"github.com/labstack/echo"
+	"github.com/spf13/viper"
+)
+
+const (
+	healthOK          = "ok"
+	healthDegraded    = "degraded"
+	healthUnavailable = "unavailable"
+)
+
+// refreshState is the outcome of the last refresh of a provider
+type refreshState struct {
+	at  time.Time
+	err error
+}
+
+var refreshStates = struct {
+	sync.RWMutex
+	states map[string]*refreshState
+}{
+	states: map[string]*refreshState{},
+}
+
+// recordRefresh keeps the outcome of a provider start or refresh for /_health
+func recordRefresh(name string, err error) {
+	refreshStates.Lock()
+	defer refreshStates.Unlock()
+
+	refreshStates.states[name] = &refreshState{
+		at:  time.Now(),
+		err: err,
+	}
+}
+
+func lastRefresh(name string) *refreshState {
+	refreshStates.RLock()
+	defer refreshStates.RUnlock()
+
+	return refreshStates.states[name]
+}
+
+type healthResponse struct {
+	Status    string                     `json:"status"`
+	Default   string                     `json:"default"`
+	Providers map[string]*providerHealth `json:"providers"`
+}
+
+type providerHealth struct {
+	Ready            bool                   `json:"ready"`
+	Databases        []*databaseHealth      `json:"databases,omitempty"`
+	Breaker          string                 `json:"breaker,omitempty"`
+	Budget           *provider.BudgetStatus `json:"budget,omitempty"`
+	LastRefresh      time.Time              `json:"last_refresh,omitzero"`
+	LastRefreshError string                 `json:"last_refresh_error,omitempty"`
+}
+
+type databaseHealth struct {
+	*provider.DatabaseInfo
+	Age   string `json:"age,omitempty"`
+	Stale bool   `json:"stale"`
+}
+
+// getReady returns 200 once the default provider is able to answer lookups and
+// 503 until then
+func getReady(c echo.Context) error {
+	response := checkHealth(c.Request().Context())
+	if response.Status == healthUnavailable {
+		return c.String(http.StatusServiceUnavailable, "not ready")
+	}
+
+	return c.String(http.StatusOK, "ready")
+}
+
+// getHealth returns the state of every enabled provider. Like getReady it returns
+// 503 until the default provider is able to answer lookups
+func getHealth(c echo.Context) error {
+	response := checkHealth(c.Request().Context())
+	if response.Status == healthUnavailable {
+		return c.JSON(http.StatusServiceUnavailable, response)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func checkHealth(ctx context.Context) *healthResponse {
+	response := &healthResponse{
+		Status:    healthOK,
+		Default:   viper.GetString("default"),
+		Providers: map[string]*providerHealth{},
+	}
+
+	maxStaleness := viper.GetDuration("health.max_staleness")
+	for _, name := range getEnabledProviderNames() {
+		ipProvider, err := getRequestedProvider(ctx, name)
+		if err != nil {
+			continue
+		}
+
+		state := checkProviderHealth(ctx, name, ipProvider, maxStaleness)
+		response.Providers[name] = state
+
+		if !state.Ready || state.LastRefreshError != "" {
+			response.Status = healthDegraded
+		}
+
+		for _, database := range state.Databases {
+			if database.Stale {
+				response.Status = healthDegraded
+			}
+		}
+	}
+
+	if state, ok := response.Providers[response.Default]; !ok || !state.Ready {
+		response.Status = healthUnavailable
+	}
+
+	return response
+}
+
+func checkProviderHealth(ctx context.Context, name string, ipProvider provider.IPProvider, maxStaleness time.Duration) *providerHealth {
+	state := &providerHealth{
+		Ready: true,
+	}
+
+	if reporter, ok := ipProvider.(provider.HealthReporter); ok {
+		reported := reporter.Health(ctx)
+		state.Ready = reported.Ready
+		state.Breaker = reported.Breaker
+		state.Budget = reported.Budget
+
+		for _, database := range reported.Databases {
+			databaseState := &databaseHealth{
+				DatabaseInfo: database,
+			}
+
+			if database.Loaded && !database.BuildTime.IsZero() {
+				age := time.Since(database.BuildTime)
+				databaseState.Age = age.Truncate(time.Second).String()
+				databaseState.Stale = maxStaleness > 0 && age > maxStaleness
+			}
+
+			state.Databases = append(state.Databases, databaseState)
+		}
+	}
+
+	if refresh := lastRefresh(name); refresh != nil {
+		state.LastRefresh = refresh.at
+		if refresh.err != nil {
+			state.LastRefreshError = refresh.err.Error()
+		}
+	}
+
+	return state
+}
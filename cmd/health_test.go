@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloud66-oss/geo/provider"
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/labstack/echo"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockHealthProvider struct {
+	mockProvider
+	health *provider.Health
+}
+
+var _ provider.HealthReporter = &mockHealthProvider{}
+
+func (mhp *mockHealthProvider) Health(ctx context.Context) *provider.Health {
+	return mhp.health
+}
+
+type healthTestSuite struct {
+	suite.Suite
+	maxmind *mockHealthProvider
+	dbip    *mockHealthProvider
+	e       *echo.Echo
+}
+
+func (suite *healthTestSuite) SetupTest() {
+	ctx := context.Background()
+	utils.Container.Clear(ctx)
+	viper.Set("default", "maxmind")
+	viper.Set("providers.maxmind.enabled", true)
+	viper.Set("providers.dbip.enabled", true)
+	viper.Set("health.max_staleness", "720h")
+
+	suite.maxmind = &mockHealthProvider{health: &provider.Health{
+		Ready: true,
+		Databases: []*provider.DatabaseInfo{
+			{Name: "city", Path: "/tmp/city.mmdb", Loaded: true, BuildTime: time.Now().Add(-24 * time.Hour)},
+		},
+	}}
+	suite.dbip = &mockHealthProvider{health: &provider.Health{Ready: true}}
+	utils.Container.Assign(ctx, utils.MaxMindProvider, suite.maxmind)
+	utils.Container.Assign(ctx, utils.DbIpProvider, suite.dbip)
+
+	refreshStates.Lock()
+	refreshStates.states = map[string]*refreshState{}
+	refreshStates.Unlock()
+
+	suite.e = echo.New()
+	suite.e.GET("/_ready", getReady)
+	suite.e.GET("/_health", getHealth)
+}
+
+func (suite *healthTestSuite) TearDownTest() {
+	viper.Set("providers.dbip.enabled", false)
+}
+
+func (suite *healthTestSuite) get(path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	suite.e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func (suite *healthTestSuite) health() (int, *healthResponse) {
+	rec := suite.get("/_health")
+
+	var body healthResponse
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+
+	return rec.Code, &body
+}
+
+func (suite *healthTestSuite) TestReady() {
+	rec := suite.get("/_ready")
+	suite.EqualValues(http.StatusOK, rec.Code)
+
+	code, body := suite.health()
+	suite.EqualValues(http.StatusOK, code)
+	suite.Equal(healthOK, body.Status)
+	suite.Equal("maxmind", body.Default)
+	suite.True(body.Providers["maxmind"].Ready)
+	suite.Len(body.Providers["maxmind"].Databases, 1)
+	suite.False(body.Providers["maxmind"].Databases[0].Stale)
+	suite.True(body.Providers["dbip"].Ready)
+}
+
+func (suite *healthTestSuite) TestDefaultNotLoaded() {
+	suite.maxmind.health = &provider.Health{
+		Databases: []*provider.DatabaseInfo{{Name: "city", Path: "/tmp/city.mmdb"}},
+	}
+
+	rec := suite.get("/_ready")
+	suite.EqualValues(http.StatusServiceUnavailable, rec.Code)
+
+	code, body := suite.health()
+	suite.EqualValues(http.StatusServiceUnavailable, code)
+	suite.Equal(healthUnavailable, body.Status)
+	suite.False(body.Providers["maxmind"].Databases[0].Loaded)
+}
+
+func (suite *healthTestSuite) TestOtherProviderNotReady() {
+	suite.dbip.health = &provider.Health{Breaker: provider.BreakerOpen}
+
+	rec := suite.get("/_ready")
+	suite.EqualValues(http.StatusOK, rec.Code)
+
+	code, body := suite.health()
+	suite.EqualValues(http.StatusOK, code)
+	suite.Equal(healthDegraded, body.Status)
+	suite.Equal(provider.BreakerOpen, body.Providers["dbip"].Breaker)
+}
+
+func (suite *healthTestSuite) TestStaleDatabase() {
+	suite.maxmind.health.Databases[0].BuildTime = time.Now().Add(-60 * 24 * time.Hour)
+
+	code, body := suite.health()
+	suite.EqualValues(http.StatusOK, code)
+	suite.Equal(healthDegraded, body.Status)
+	suite.True(body.Providers["maxmind"].Databases[0].Stale)
+}
+
+func (suite *healthTestSuite) TestRefreshError() {
+	recordRefresh("dbip", errors.New("download failed"))
+
+	code, body := suite.health()
+	suite.EqualValues(http.StatusOK, code)
+	suite.Equal(healthDegraded, body.Status)
+	suite.Equal("download failed", body.Providers["dbip"].LastRefreshError)
+	suite.False(body.Providers["dbip"].LastRefresh.IsZero())
+}
+
+func (suite *healthTestSuite) TestStartLoadError() {
+	suite.dbip.On("Start", mock.Anything).Return(&utils.LoadError{Err: errors.New("database not found")})
+	startProvider(context.Background(), "dbip", suite.dbip)
+
+	_, body := suite.health()
+	suite.Equal(healthDegraded, body.Status)
+	suite.Equal("database not found", body.Providers["dbip"].LastRefreshError)
+
+	suite.maxmind.On("Start", mock.Anything).Return(nil)
+	startProvider(context.Background(), "maxmind", suite.maxmind)
+
+	_, body = suite.health()
+	suite.Empty(body.Providers["maxmind"].LastRefreshError)
+}
+
+func TestHealthTestSuite(t *testing.T) {
+	suite.Run(t, new(healthTestSuite))
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/labstack/echo"
+	"github.com/spf13/viper"
+)
+
+// ipInfoResponse is the flat response format used by ipinfo.io
+type ipInfoResponse struct {
+	IP       string `json:"ip"`
+	City     string `json:"city,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Loc      string `json:"loc,omitempty"`
+	Org      string `json:"org,omitempty"`
+	Postal   string `json:"postal,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	Bogon    bool   `json:"bogon,omitempty"`
+}
+
+// registerIpInfoRoutes adds the ipinfo.io compatible routes to e
+func registerIpInfoRoutes(e *echo.Echo) {
+	g := e.Group("/compat/ipinfo", apiKeyAuth(getIpInfoProvider))
+	g.GET("/json", getIpInfo)
+	g.GET("/:address", getIpInfo)
+	g.GET("/:address/json", getIpInfo)
+}
+
+// getIpInfoProvider returns the provider an ipinfo.io compatible request is
+// for
+func getIpInfoProvider(c echo.Context) string {
+	requestedProvider := c.QueryParam("provider")
+	if requestedProvider == "" {
+		requestedProvider = viper.GetString("compat.ipinfo.provider")
+	}
+	if requestedProvider == "" {
+		requestedProvider = viper.GetString("default")
+	}
+
+	return requestedProvider
+}
+
+func getIpInfo(c echo.Context) error {
+	address := c.Param("address")
+	if address == "" {
+		address = c.RealIP()
+	}
+
+	requestedProvider := getIpInfoProvider(c)
+	setRequestProvider(c, requestedProvider)
+
+	ip, err := lookupIP(c.Request().Context(), requestedProvider, address)
+	if err != nil {
+		switch err := err.(type) {
+		case *utils.UnknownProviderError, *utils.IpAddressError:
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+				Error: err.Error(),
+			})
+		case *utils.ProviderUnavailableError:
+			return c.JSON(http.StatusServiceUnavailable, utils.ErrorResponse{
+				Error: err.Error(),
+			})
+		case *utils.ReservedAddressError:
+			// ipinfo.io answers reserved addresses as bogons
+			return c.JSON(http.StatusOK, &ipInfoResponse{IP: err.Address, Bogon: true})
+		default:
+			return err
+		}
+	}
+
+	if ip == nil {
+		return c.JSON(http.StatusNotFound, utils.ErrorResponse{
+			Error: "address not found",
+		})
+	}
+
+	return c.JSON(http.StatusOK, toIpInfoResponse(filterFields(requestApiKey(c), ip)))
+}
+
+func toIpInfoResponse(ip *utils.IPInfo) *ipInfoResponse {
+	response := &ipInfoResponse{
+		IP:    ip.Address,
+		Bogon: ip.Reserved != nil,
+	}
+
+	if ip.City != nil {
+		response.City = ip.City.Names["en"]
+	}
+
+	if len(ip.Subdivisions) > 0 && ip.Subdivisions[0] != nil {
+		response.Region = ip.Subdivisions[0].Names["en"]
+	}
+
+	if ip.Country != nil {
+		response.Country = ip.Country.IsoCode
+	}
+
+	if ip.Location != nil {
+		if ip.Location.Latitude != 0 || ip.Location.Longitude != 0 {
+			response.Loc = strconv.FormatFloat(ip.Location.Latitude, 'f', 4, 64) + "," + strconv.FormatFloat(ip.Location.Longitude, 'f', 4, 64)
+		}
+		response.Timezone = ip.Location.TimeZone
+	}
+
+	if ip.Postal != nil {
+		response.Postal = ip.Postal.Code
+	}
+
+	if ip.HasASN && ip.ASN != nil && ip.ASN.AutonomousSystemNumber != 0 {
+		response.Org = fmt.Sprintf("AS%d %s", ip.ASN.AutonomousSystemNumber, ip.ASN.AutonomousSystemOrganization)
+	}
+
+	return response
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/labstack/echo"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ipInfoTestSuite struct {
+	suite.Suite
+	provider *mockProvider
+	e        *echo.Echo
+}
+
+func (suite *ipInfoTestSuite) SetupTest() {
+	ctx := context.Background()
+	utils.Container.Clear(ctx)
+	viper.Set("cache.enabled", false)
+	viper.Set("default", "maxmind")
+
+	suite.provider = &mockProvider{}
+	utils.Container.Assign(ctx, utils.MaxMindProvider, suite.provider)
+
+	suite.e = echo.New()
+	registerIpInfoRoutes(suite.e)
+}
+
+func (suite *ipInfoTestSuite) TestLookup() {
+	suite.provider.On("Lookup", mock.Anything, "8.8.8.8").Return(&utils.IPInfo{
+		Address:      "8.8.8.8",
+		City:         &utils.City{Names: map[string]string{"en": "Mountain View"}},
+		Subdivisions: []*utils.Subdivision{{IsoCode: "CA", Names: map[string]string{"en": "California"}}},
+		Country:      &utils.Country{IsoCode: "US"},
+		Location:     &utils.Location{Latitude: 37.386, Longitude: -122.0838, TimeZone: "America/Los_Angeles"},
+		Postal:       &utils.Postal{Code: "94035"},
+		HasASN:       true,
+		ASN:          &utils.ASN{AutonomousSystemNumber: 15169, AutonomousSystemOrganization: "GOOGLE"},
+	}, nil)
+
+	for _, path := range []string{"/compat/ipinfo/8.8.8.8", "/compat/ipinfo/8.8.8.8/json", "/compat/ipinfo/::ffff:8.8.8.8"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		suite.e.ServeHTTP(rec, req)
+
+		suite.EqualValues(http.StatusOK, rec.Code)
+
+		var body ipInfoResponse
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+		suite.EqualValues(ipInfoResponse{
+			IP:       "8.8.8.8",
+			City:     "Mountain View",
+			Region:   "California",
+			Country:  "US",
+			Loc:      "37.3860,-122.0838",
+			Org:      "AS15169 GOOGLE",
+			Postal:   "94035",
+			Timezone: "America/Los_Angeles",
+		}, body)
+	}
+}
+
+func (suite *ipInfoTestSuite) TestSelf() {
+	suite.provider.On("Lookup", mock.Anything, "1.1.1.1").Return(&utils.IPInfo{Address: "1.1.1.1"}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/compat/ipinfo/json", nil)
+	req.Header.Set(echo.HeaderXRealIP, "1.1.1.1")
+	rec := httptest.NewRecorder()
+	suite.e.ServeHTTP(rec, req)
+
+	suite.EqualValues(http.StatusOK, rec.Code)
+	suite.provider.AssertCalled(suite.T(), "Lookup", mock.Anything, "1.1.1.1")
+}
+
+func (suite *ipInfoTestSuite) TestBogon() {
+	for _, response := range []string{"reserved", "error"} {
+		viper.Set("reserved.response", response)
+
+		for _, path := range []string{"/compat/ipinfo/192.168.1.1", "/compat/ipinfo/::ffff:192.168.1.1"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rec := httptest.NewRecorder()
+			suite.e.ServeHTTP(rec, req)
+
+			suite.EqualValues(http.StatusOK, rec.Code)
+			suite.JSONEq(`{"ip": "192.168.1.1", "bogon": true}`, rec.Body.String())
+		}
+	}
+	viper.Set("reserved.response", "reserved")
+
+	suite.provider.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+}
+
+func TestIpInfoTestSuite(t *testing.T) {
+	suite.Run(t, new(ipInfoTestSuite))
+}
@@ -111,6 +111,18 @@ func initConfig() {
 		ctx := context.Background()
 		log.Info().Str("file", e.Name).Msg("reloading config")
 		configureLogging(ctx)
-		configureCache(ctx)
+		if viper.GetBool("cache.enabled") {
+			if err := configureCache(ctx); err != nil {
+				log.Error().Err(err).Msg("failed to reload the cache")
+			}
+		}
+		if err := utils.ConfigureReservedRanges(); err != nil {
+			log.Error().Err(err).Msg("failed to reload reserved address ranges, keeping the previous ones")
+		}
+		if viper.GetBool("auth.enabled") {
+			if err := configureApiKeys(ctx); err != nil {
+				log.Error().Err(err).Msg("failed to reload API keys, keeping the previous ones")
+			}
+		}
 	})
 }
@@ -2,10 +2,15 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/cloud66-oss/geo/cache"
@@ -14,11 +19,27 @@ import (
 	"github.com/getsentry/sentry-go"
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
 )
 
+// providerNames are all the providers, with the cascade provider last
+var providerNames = []string{"maxmind", "dbip", "ipstack", "globio", "ip2location", "mmdb", "overrides", "geofeed", "cloud", "blocklist", "cascade"}
+
+// refreshLock serializes provider refreshes
+var refreshLock sync.Mutex
+
+// inflightLookups coalesces concurrent provider lookups of the same address
+var inflightLookups singleflight.Group
+
 var serveCmd = &cobra.Command{
 	Use: "serve",
 	Run: execServe,
@@ -31,6 +52,38 @@ func init() {
 
 	serveCmd.PersistentFlags().String("default", "maxmind", "Default IP provider")
 
+	// grpc server
+	serveCmd.PersistentFlags().Bool("grpc.enabled", false, "gRPC API enabled")
+	serveCmd.PersistentFlags().String("grpc.binding", "0.0.0.0", "gRPC API binding")
+	serveCmd.PersistentFlags().Int("grpc.port", 9913, "gRPC API port")
+	serveCmd.PersistentFlags().Int("grpc.max_batch", 100, "Largest number of addresses in a gRPC batch")
+
+	// admin api
+	serveCmd.PersistentFlags().Bool("admin.enabled", false, "Admin API enabled")
+	serveCmd.PersistentFlags().String("admin.binding", "127.0.0.1", "Admin API binding when on its own port")
+	serveCmd.PersistentFlags().Int("admin.port", 0, "Admin API port (0 serves it on the API port)")
+
+	// maxmind compatible api
+	serveCmd.PersistentFlags().Bool("geoip.enabled", false, "GeoIP2 web service compatible API enabled")
+	serveCmd.PersistentFlags().String("geoip.provider", "", "GeoIP2 web service compatible API provider (defaults to the default provider)")
+
+	// ipinfo compatible api
+	serveCmd.PersistentFlags().Bool("compat.ipinfo.enabled", false, "ipinfo.io compatible API enabled")
+	serveCmd.PersistentFlags().String("compat.ipinfo.provider", "", "ipinfo.io compatible API provider (defaults to the default provider)")
+
+	// whois server
+	serveCmd.PersistentFlags().Bool("whois.enabled", false, "Team Cymru style whois server enabled")
+	serveCmd.PersistentFlags().String("whois.binding", "127.0.0.1", "whois server binding")
+	serveCmd.PersistentFlags().Int("whois.port", 4343, "whois server port")
+	serveCmd.PersistentFlags().String("whois.provider", "", "whois server provider (defaults to the default provider)")
+
+	// dns server
+	serveCmd.PersistentFlags().Bool("dns.enabled", false, "DNS server enabled")
+	serveCmd.PersistentFlags().String("dns.binding", "127.0.0.1", "DNS server binding")
+	serveCmd.PersistentFlags().Int("dns.port", 8053, "DNS server port")
+	serveCmd.PersistentFlags().String("dns.zone", "geo.local.", "DNS zone to answer queries for")
+	serveCmd.PersistentFlags().String("dns.provider", "", "DNS server provider (defaults to the default provider)")
+
 	serveCmd.PersistentFlags().String("providers.maxmind.db.city", "", "MaxMind city database")
 	serveCmd.PersistentFlags().String("providers.maxmind.db.asn", "", "MaxMind ASN database")
 	serveCmd.PersistentFlags().Bool("providers.maxmind.download.enabled", false, "MaxMind download enabled")
@@ -62,14 +115,66 @@ func init() {
 	serveCmd.PersistentFlags().String("providers.globio.download.asn", "", "Globio download ASN database URL")
 	serveCmd.PersistentFlags().Bool("providers.globio.enabled", false, "Globio enabled")
 
+	serveCmd.PersistentFlags().String("providers.ip2location.db", "", "IP2Location database")
+	serveCmd.PersistentFlags().Bool("providers.ip2location.download.enabled", false, "IP2Location download enabled")
+	serveCmd.PersistentFlags().String("providers.ip2location.download.url", "", "IP2Location download database URL")
+	serveCmd.PersistentFlags().Bool("providers.ip2location.enabled", false, "IP2Location enabled")
+
+	serveCmd.PersistentFlags().Bool("providers.mmdb.enabled", false, "Mmdb enabled")
+	serveCmd.PersistentFlags().String("providers.mmdb.path", "dbs/mmdb", "Directory of the downloaded mmdb databases")
+
+	serveCmd.PersistentFlags().Bool("providers.overrides.enabled", false, "Overrides enabled")
+	serveCmd.PersistentFlags().String("providers.overrides.file", "overrides.yml", "Overrides file (YAML, JSON or CSV)")
+	serveCmd.PersistentFlags().Bool("providers.overrides.patch", false, "Apply the overrides on top of every provider")
+	serveCmd.PersistentFlags().Bool("providers.overrides.watch", true, "Reload the overrides file when it changes")
+	serveCmd.PersistentFlags().String("providers.overrides.store", "dbs/overrides-store.json", "Overrides edited through the admin API")
+	serveCmd.PersistentFlags().String("providers.overrides.audit_file", "dbs/overrides-audit.log", "Audit trail of the overrides edited through the admin API")
+
+	serveCmd.PersistentFlags().Bool("providers.geofeed.enabled", false, "Geofeed enabled")
+	serveCmd.PersistentFlags().String("providers.geofeed.path", "dbs/geofeeds", "Directory of the downloaded geofeeds")
+
+	serveCmd.PersistentFlags().Bool("providers.cloud.enabled", false, "Cloud ranges enabled")
+	serveCmd.PersistentFlags().String("providers.cloud.path", "dbs/cloud", "Directory of the downloaded cloud range files")
+
+	serveCmd.PersistentFlags().Bool("providers.blocklist.enabled", false, "Blocklists enabled")
+	serveCmd.PersistentFlags().String("providers.blocklist.path", "dbs/blocklists", "Directory of the downloaded blocklists")
+	serveCmd.PersistentFlags().Duration("providers.blocklist.refresh", time.Hour, "Refresh interval of the blocklists, on top of the refresh of all providers")
+
 	serveCmd.PersistentFlags().Bool("providers.cascade.enabled", false, "Cascade enabled")
 	serveCmd.PersistentFlags().StringArray("providers.cascade.providers", []string{"maxmind", "ipstack"}, "Cascade providers")
+	serveCmd.PersistentFlags().StringArray("providers.cascade.patches", []string{}, "Providers patching the answers of the cascade")
 	serveCmd.PersistentFlags().Bool("providers.cascade.stopOnError", false, "Cascade stop on error")
 
 	viper.BindPFlag("default", serveCmd.PersistentFlags().Lookup("default"))
 	viper.BindPFlag("api.binding", serveCmd.PersistentFlags().Lookup("binding"))
 	viper.BindPFlag("api.port", serveCmd.PersistentFlags().Lookup("port"))
 
+	viper.BindPFlag("grpc.enabled", serveCmd.PersistentFlags().Lookup("grpc.enabled"))
+	viper.BindPFlag("grpc.binding", serveCmd.PersistentFlags().Lookup("grpc.binding"))
+	viper.BindPFlag("grpc.port", serveCmd.PersistentFlags().Lookup("grpc.port"))
+	viper.BindPFlag("grpc.max_batch", serveCmd.PersistentFlags().Lookup("grpc.max_batch"))
+
+	viper.BindPFlag("admin.enabled", serveCmd.PersistentFlags().Lookup("admin.enabled"))
+	viper.BindPFlag("admin.binding", serveCmd.PersistentFlags().Lookup("admin.binding"))
+	viper.BindPFlag("admin.port", serveCmd.PersistentFlags().Lookup("admin.port"))
+
+	viper.BindPFlag("geoip.enabled", serveCmd.PersistentFlags().Lookup("geoip.enabled"))
+	viper.BindPFlag("geoip.provider", serveCmd.PersistentFlags().Lookup("geoip.provider"))
+
+	viper.BindPFlag("compat.ipinfo.enabled", serveCmd.PersistentFlags().Lookup("compat.ipinfo.enabled"))
+	viper.BindPFlag("compat.ipinfo.provider", serveCmd.PersistentFlags().Lookup("compat.ipinfo.provider"))
+
+	viper.BindPFlag("whois.enabled", serveCmd.PersistentFlags().Lookup("whois.enabled"))
+	viper.BindPFlag("whois.binding", serveCmd.PersistentFlags().Lookup("whois.binding"))
+	viper.BindPFlag("whois.port", serveCmd.PersistentFlags().Lookup("whois.port"))
+	viper.BindPFlag("whois.provider", serveCmd.PersistentFlags().Lookup("whois.provider"))
+
+	viper.BindPFlag("dns.enabled", serveCmd.PersistentFlags().Lookup("dns.enabled"))
+	viper.BindPFlag("dns.binding", serveCmd.PersistentFlags().Lookup("dns.binding"))
+	viper.BindPFlag("dns.port", serveCmd.PersistentFlags().Lookup("dns.port"))
+	viper.BindPFlag("dns.zone", serveCmd.PersistentFlags().Lookup("dns.zone"))
+	viper.BindPFlag("dns.provider", serveCmd.PersistentFlags().Lookup("dns.provider"))
+
 	viper.BindPFlag("providers.maxmind.db.city", serveCmd.PersistentFlags().Lookup("providers.maxmind.db.city"))
 	viper.BindPFlag("providers.maxmind.db.asn", serveCmd.PersistentFlags().Lookup("providers.maxmind.db.asn"))
 	viper.BindPFlag("providers.maxmind.download.enabled", serveCmd.PersistentFlags().Lookup("providers.maxmind.download.enabled"))
@@ -100,9 +205,35 @@ func init() {
 	viper.BindPFlag("providers.globio.download.asn", serveCmd.PersistentFlags().Lookup("providers.globio.download.asn"))
 	viper.BindPFlag("providers.globio.enabled", serveCmd.PersistentFlags().Lookup("providers.globio.enabled"))
 
+	viper.BindPFlag("providers.ip2location.db", serveCmd.PersistentFlags().Lookup("providers.ip2location.db"))
+	viper.BindPFlag("providers.ip2location.download.enabled", serveCmd.PersistentFlags().Lookup("providers.ip2location.download.enabled"))
+	viper.BindPFlag("providers.ip2location.download.url", serveCmd.PersistentFlags().Lookup("providers.ip2location.download.url"))
+	viper.BindPFlag("providers.ip2location.enabled", serveCmd.PersistentFlags().Lookup("providers.ip2location.enabled"))
+
+	viper.BindPFlag("providers.mmdb.enabled", serveCmd.PersistentFlags().Lookup("providers.mmdb.enabled"))
+	viper.BindPFlag("providers.mmdb.path", serveCmd.PersistentFlags().Lookup("providers.mmdb.path"))
+
+	viper.BindPFlag("providers.overrides.enabled", serveCmd.PersistentFlags().Lookup("providers.overrides.enabled"))
+	viper.BindPFlag("providers.overrides.file", serveCmd.PersistentFlags().Lookup("providers.overrides.file"))
+	viper.BindPFlag("providers.overrides.patch", serveCmd.PersistentFlags().Lookup("providers.overrides.patch"))
+	viper.BindPFlag("providers.overrides.watch", serveCmd.PersistentFlags().Lookup("providers.overrides.watch"))
+	viper.BindPFlag("providers.overrides.store", serveCmd.PersistentFlags().Lookup("providers.overrides.store"))
+	viper.BindPFlag("providers.overrides.audit_file", serveCmd.PersistentFlags().Lookup("providers.overrides.audit_file"))
+
+	viper.BindPFlag("providers.geofeed.enabled", serveCmd.PersistentFlags().Lookup("providers.geofeed.enabled"))
+	viper.BindPFlag("providers.geofeed.path", serveCmd.PersistentFlags().Lookup("providers.geofeed.path"))
+
+	viper.BindPFlag("providers.cloud.enabled", serveCmd.PersistentFlags().Lookup("providers.cloud.enabled"))
+	viper.BindPFlag("providers.cloud.path", serveCmd.PersistentFlags().Lookup("providers.cloud.path"))
+
+	viper.BindPFlag("providers.blocklist.enabled", serveCmd.PersistentFlags().Lookup("providers.blocklist.enabled"))
+	viper.BindPFlag("providers.blocklist.path", serveCmd.PersistentFlags().Lookup("providers.blocklist.path"))
+	viper.BindPFlag("providers.blocklist.refresh", serveCmd.PersistentFlags().Lookup("providers.blocklist.refresh"))
+
 	viper.BindPFlag("providers.cascade.enabled", serveCmd.PersistentFlags().Lookup("providers.cascade.enabled"))
 	viper.BindPFlag("providers.cascade.stopOnError", serveCmd.PersistentFlags().Lookup("providers.cascade.stopOnError"))
 	viper.BindPFlag("providers.cascade.providers", serveCmd.PersistentFlags().Lookup("providers.cascade.providers"))
+	viper.BindPFlag("providers.cascade.patches", serveCmd.PersistentFlags().Lookup("providers.cascade.patches"))
 
 	// providers
 	viper.SetDefault("providers.maxmind.db.city", "")
@@ -128,6 +259,13 @@ func init() {
 
 	viper.SetDefault("providers.ipstack.apikey", "")
 	viper.SetDefault("providers.ipstack.enabled", false)
+	viper.SetDefault("providers.ipstack.breaker.threshold", 5)
+	viper.SetDefault("providers.ipstack.breaker.cooldown", "1m")
+	viper.SetDefault("providers.ipstack.budget.daily", 0)
+	viper.SetDefault("providers.ipstack.budget.monthly", 0)
+	viper.SetDefault("providers.ipstack.budget.state_file", "dbs/ipstack-budget.json")
+	viper.SetDefault("providers.ipstack.budget.warn_at", []string{"0.5", "0.8", "0.95"})
+	viper.SetDefault("providers.ipstack.budget.save_interval", "10s")
 
 	viper.SetDefault("providers.globio.db.country", "")
 	viper.SetDefault("providers.globio.db.asn", "")
@@ -136,45 +274,301 @@ func init() {
 	viper.SetDefault("providers.globio.download.asn", "")
 	viper.SetDefault("providers.globio.enabled", false)
 
+	viper.SetDefault("providers.ip2location.db", "")
+	viper.SetDefault("providers.ip2location.download.enabled", false)
+	viper.SetDefault("providers.ip2location.download.url", "")
+	viper.SetDefault("providers.ip2location.enabled", false)
+
+	viper.SetDefault("providers.mmdb.enabled", false)
+	viper.SetDefault("providers.mmdb.path", "dbs/mmdb")
+	viper.SetDefault("providers.mmdb.databases", []map[string]any{})
+
+	viper.SetDefault("providers.overrides.enabled", false)
+	viper.SetDefault("providers.overrides.file", "overrides.yml")
+	viper.SetDefault("providers.overrides.patch", false)
+	viper.SetDefault("providers.overrides.watch", true)
+	viper.SetDefault("providers.overrides.store", "dbs/overrides-store.json")
+	viper.SetDefault("providers.overrides.audit_file", "dbs/overrides-audit.log")
+
+	viper.SetDefault("providers.geofeed.enabled", false)
+	viper.SetDefault("providers.geofeed.path", "dbs/geofeeds")
+	viper.SetDefault("providers.geofeed.feeds", []map[string]string{})
+
+	viper.SetDefault("providers.cloud.enabled", false)
+	viper.SetDefault("providers.cloud.path", "dbs/cloud")
+	viper.SetDefault("providers.cloud.sources", []map[string]string{})
+
+	viper.SetDefault("providers.blocklist.enabled", false)
+	viper.SetDefault("providers.blocklist.path", "dbs/blocklists")
+	viper.SetDefault("providers.blocklist.refresh", time.Hour)
+	viper.SetDefault("providers.blocklist.lists", []map[string]string{
+		{"name": "tor", "url": "https://check.torproject.org/torbulkexitlist", "flag": "tor"},
+	})
+
 	viper.SetDefault("providers.cascade.enabled", false)
 	viper.SetDefault("providers.cascade.stopOnError", false)
 	viper.SetDefault("providers.cascade.providers", []string{"maxmind", "ipstack"})
+	viper.SetDefault("providers.cascade.patches", []string{})
 
 	// cache
 	viper.SetDefault("cache.enabled", true)
 	viper.SetDefault("cache.size", 128)
+	viper.SetDefault("cache.type", "memory")
+	viper.SetDefault("cache.tiered", true)
+	viper.SetDefault("cache.ttl.default", 0)
+	viper.SetDefault("cache.prefix.enabled", true)
+	viper.SetDefault("cache.prefix.ipv4", 32)
+	viper.SetDefault("cache.prefix.ipv6", 128)
+	viper.SetDefault("cache.disk.path", "dbs/cache.db")
+	viper.SetDefault("cache.disk.max_entries", 1000000)
+	viper.SetDefault("cache.disk.compact_interval", "24h")
+	viper.SetDefault("cache.redis.address", "localhost:6379")
+	viper.SetDefault("cache.redis.url", "")
+	viper.SetDefault("cache.redis.password", "")
+	viper.SetDefault("cache.redis.db", 0)
+	viper.SetDefault("cache.redis.prefix", "geo")
+	viper.SetDefault("cache.redis.max_ttl", "168h")
 
 	// refresh
 	viper.SetDefault("refresh", "24h")
+	viper.SetDefault("address.embedded_ipv4", []string{})
+	viper.SetDefault("reserved.enabled", true)
+	viper.SetDefault("reserved.response", "reserved")
+	viper.SetDefault("reserved.extra", []map[string]string{})
+
+	// health
+	viper.SetDefault("health.max_staleness", "720h")
+
+	// metrics
+	viper.SetDefault("metrics.enabled", true)
+
+	// tracing
+	viper.SetDefault("tracing.enabled", false)
+	viper.SetDefault("tracing.protocol", "grpc")
+	viper.SetDefault("tracing.endpoint", "localhost:4317")
+	viper.SetDefault("tracing.insecure", true)
+	viper.SetDefault("tracing.service_name", "geo")
+	viper.SetDefault("tracing.sample_ratio", 1.0)
+
+	// grpc
+	viper.SetDefault("grpc.enabled", false)
+	viper.SetDefault("grpc.binding", "0.0.0.0")
+	viper.SetDefault("grpc.port", 9913)
+	viper.SetDefault("grpc.max_batch", 100)
+
+	// api keys
+	viper.SetDefault("auth.enabled", false)
+	viper.SetDefault("auth.header", "X-API-Key")
+	viper.SetDefault("auth.query", "api_key")
+	viper.SetDefault("auth.keys_file", "")
+
+	// admin api
+	viper.SetDefault("admin.enabled", false)
+	viper.SetDefault("admin.binding", "127.0.0.1")
+	viper.SetDefault("admin.port", 0)
+	viper.SetDefault("admin.token", "")
+
+	// maxmind compatible api
+	viper.SetDefault("geoip.enabled", false)
+	viper.SetDefault("geoip.provider", "")
+	viper.SetDefault("geoip.accounts", map[string]string{})
+
+	// ipinfo compatible api
+	viper.SetDefault("compat.ipinfo.enabled", false)
+	viper.SetDefault("compat.ipinfo.provider", "")
+
+	// whois server
+	viper.SetDefault("whois.enabled", false)
+	viper.SetDefault("whois.binding", "127.0.0.1")
+	viper.SetDefault("whois.port", 4343)
+	viper.SetDefault("whois.provider", "")
+	viper.SetDefault("whois.timeout", "5m")
+
+	// dns server
+	viper.SetDefault("dns.enabled", false)
+	viper.SetDefault("dns.binding", "127.0.0.1")
+	viper.SetDefault("dns.port", 8053)
+	viper.SetDefault("dns.zone", "geo.local.")
+	viper.SetDefault("dns.provider", "")
+	viper.SetDefault("dns.ttl", "5m")
 
 	rootCmd.AddCommand(serveCmd)
 }
 
-func getIP(c echo.Context) error {
-	cached := viper.GetBool("cache.enabled")
-
-	var cp cache.CacheProvider
-	if cached {
-		cp = utils.Container.Fetch(c.Request().Context(), utils.Cache).(cache.CacheProvider)
-	}
-
+// getIPProvider returns the provider a /v1/ip request is for
+func getIPProvider(c echo.Context) string {
 	requestedProvider := c.QueryParam("provider")
 	if requestedProvider == "" {
 		requestedProvider = viper.GetString("default")
 	}
 
+	return requestedProvider
+}
+
+func getIP(c echo.Context) error {
+	requestedProvider := getIPProvider(c)
 	address := c.Param("address")
+
+	setRequestProvider(c, requestedProvider)
+
+	ip, err := lookupIP(c.Request().Context(), requestedProvider, address)
+	if err != nil {
+		switch err.(type) {
+		case *utils.UnknownProviderError, *utils.IpAddressError:
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{
+				Error: err.Error(),
+			})
+		case *utils.ProviderUnavailableError:
+			return c.JSON(http.StatusServiceUnavailable, utils.ErrorResponse{
+				Error: err.Error(),
+			})
+		case *utils.ReservedAddressError:
+			return c.JSON(http.StatusUnprocessableEntity, utils.ErrorResponse{
+				Error:    err.Error(),
+				Code:     "IP_ADDRESS_RESERVED",
+				Reserved: err.(*utils.ReservedAddressError).Range,
+			})
+		default:
+			return err
+		}
+	}
+
+	return c.JSON(http.StatusOK, filterFields(requestApiKey(c), ip))
+}
+
+// setRequestProvider labels the request logs and metrics with the provider
+// answering it. Names are only used once they're known to be providers, as
+// anything could be asked for and every name would add metric series
+func setRequestProvider(c echo.Context, name string) {
+	if slices.Contains(providerNames, name) {
+		c.Set(utils.ProviderContextKey, name)
+	}
+}
+
+// lookupIP returns the information for address from the requested provider,
+// going through the cache when it is enabled. It is shared by the HTTP and
+// gRPC APIs. The address is normalized first, and the result reports both
+// the address as requested and the address that was looked up
+func lookupIP(ctx context.Context, requestedProvider string, requestedAddress string) (*utils.IPInfo, error) {
+	address, err := utils.NormalizeAddress(requestedAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	// reserved addresses aren't looked up, but a bad provider is still a bad
+	// request
+	if !slices.Contains(providerNames, requestedProvider) {
+		return nil, &utils.UnknownProviderError{}
+	}
+
+	// no provider knows anything about addresses that aren't public
+	if viper.GetBool("reserved.enabled") {
+		if reserved := utils.LookupReservedRange(netip.MustParseAddr(address)); reserved != nil {
+			log.Debug().Str("address", address).Str("range", reserved.Name).Msg("reserved address")
+			utils.ReservedLookups.WithLabelValues(reserved.Name).Inc()
+
+			if viper.GetString("reserved.response") == "error" {
+				return nil, &utils.ReservedAddressError{Address: address, Range: reserved}
+			}
+
+			ip := &utils.IPInfo{
+				Address:         address,
+				OriginalAddress: requestedAddress,
+				Network:         reserved.Network,
+				Source:          "reserved",
+				Reserved:        reserved,
+			}
+
+			// internal networks are what overrides are usually for, so they
+			// apply to reserved addresses whatever the provider
+			if overrides := getOverridesProvider(ctx); overrides != nil {
+				ip = overrides.Patch(ctx, ip)
+			}
+
+			return ip, nil
+		}
+	}
+
+	ip, err := lookupAddress(ctx, requestedProvider, address)
+	if err != nil || ip == nil {
+		return ip, err
+	}
+
+	// cached and coalesced results are shared, so they're never updated in
+	// place
+	result := *ip
+	result.Address = address
+	result.OriginalAddress = requestedAddress
+
+	return patchOverrides(ctx, requestedProvider, patchCascade(ctx, requestedProvider, &result)), nil
+}
+
+// patchCascade applies the patches of the cascade on top of its answers. It
+// runs after the cache so the cached answers don't change with the patches
+func patchCascade(ctx context.Context, requestedProvider string, ip *utils.IPInfo) *utils.IPInfo {
+	if requestedProvider != "cascade" {
+		return ip
+	}
+
+	cascade, ok := utils.Container.Fetch(ctx, utils.CascadeProvider).(*provider.CascadeIPProvider)
+	if !ok {
+		return ip
+	}
+
+	return cascade.Patch(ctx, ip)
+}
+
+// patchOverrides applies the overrides on top of ip when
+// providers.overrides.patch is set. It runs after the cache so edits to the
+// overrides show up straight away
+func patchOverrides(ctx context.Context, requestedProvider string, ip *utils.IPInfo) *utils.IPInfo {
+	if requestedProvider == "overrides" || !viper.GetBool("providers.overrides.patch") {
+		return ip
+	}
+
+	overrides := getOverridesProvider(ctx)
+	if overrides == nil {
+		return ip
+	}
+
+	return overrides.Patch(ctx, ip)
+}
+
+// getOverridesProvider returns the overrides provider, or nil when it isn't
+// enabled
+func getOverridesProvider(ctx context.Context) *provider.OverridesProvider {
+	if !viper.GetBool("providers.overrides.enabled") {
+		return nil
+	}
+
+	overrides, _ := utils.Container.Fetch(ctx, utils.OverridesProvider).(*provider.OverridesProvider)
+
+	return overrides
+}
+
+// lookupAddress is lookupIP for a normalized address
+func lookupAddress(ctx context.Context, requestedProvider string, address string) (*utils.IPInfo, error) {
+	cached := viper.GetBool("cache.enabled")
+
 	log.Debug().Str("address", address).Str("provider", requestedProvider).Msg("fetching")
 
 	if cached {
-		ip, err := cp.Fetch(c.Request().Context(), requestedProvider, address)
+		fetchCtx, span := utils.Tracer().Start(ctx, "cache.Fetch", trace.WithAttributes(
+			attribute.String("geo.provider", requestedProvider),
+			attribute.String("geo.address", address),
+		))
+		cp, release := acquireCache(ctx)
+		ip, err := cp.Fetch(fetchCtx, requestedProvider, address)
+		release()
+		span.SetAttributes(attribute.Bool("geo.cache.hit", ip != nil))
+		utils.EndSpan(span, err)
 		if err != nil {
 			log.Error().Err(err).Msg("failed to fetch from cache")
 		}
 
 		if ip != nil {
 			log.Trace().Str("address", address).Msg("returning cached value")
-			return c.JSON(http.StatusOK, ip)
+			return ip, nil
 		}
 	}
 
@@ -182,39 +576,89 @@ func getIP(c echo.Context) error {
 		log.Trace().Str("address", address).Str("provider", requestedProvider).Msg("not found in cache")
 	}
 
-	ipProvider, err := getRequestedProvider(c.Request().Context(), requestedProvider)
+	ipProvider, err := getRequestedProvider(ctx, requestedProvider)
 	if err != nil {
-		if _, ok := err.(*utils.UnknownProviderError); ok {
-			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{
-				Error: err.Error(),
-			})
-		} else {
+		if _, ok := err.(*utils.UnknownProviderError); !ok {
 			log.Err(err).Msg("failed to get provider")
-			return c.JSON(http.StatusInternalServerError, err)
 		}
+
+		return nil, err
+	}
+
+	// concurrent misses for the same address wait for a single lookup. It
+	// isn't cancelled with the request that started it as others may be
+	// waiting on it
+	leader := false
+	results := inflightLookups.DoChan(requestedProvider+"--"+address, func() (any, error) {
+		leader = true
+
+		// the lookup holds the cache itself as it can outlive the request
+		var cp cache.CacheProvider
+		if cached {
+			var release func()
+			cp, release = acquireCache(ctx)
+			defer release()
+		}
+
+		return lookupProvider(context.WithoutCancel(ctx), cp, ipProvider, requestedProvider, address)
+	})
+
+	select {
+	case result := <-results:
+		if !leader {
+			utils.LookupsCoalesced.WithLabelValues(requestedProvider).Inc()
+			log.Trace().Str("address", address).Str("provider", requestedProvider).Msg("coalesced with a lookup in flight")
+		}
+		if result.Err != nil {
+			return nil, result.Err
+		}
+
+		return result.Val.(*utils.IPInfo), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
+}
 
-	ip, err := ipProvider.Lookup(c.Request().Context(), address, false)
+// lookupProvider looks address up with ipProvider and adds the result to cp
+// when caching is enabled
+func lookupProvider(ctx context.Context, cp cache.CacheProvider, ipProvider provider.IPProvider, requestedProvider string, address string) (*utils.IPInfo, error) {
+	lookupCtx, span := utils.Tracer().Start(ctx, "provider.Lookup", trace.WithAttributes(
+		attribute.String("geo.provider", requestedProvider),
+		attribute.String("geo.address", address),
+	))
+	start := time.Now()
+	ip, err := ipProvider.Lookup(lookupCtx, address, false)
+	utils.ProviderLookupDuration.WithLabelValues(requestedProvider).Observe(time.Since(start).Seconds())
+	utils.EndSpan(span, err)
 	if err != nil {
-		if ipErr, ok := err.(*utils.IpAddressError); ok {
-			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{
-				Error: ipErr.Error(),
-			})
-		} else {
+		switch err.(type) {
+		case *utils.IpAddressError:
+		case *utils.ProviderUnavailableError:
+			utils.ProviderLookupErrors.WithLabelValues(requestedProvider).Inc()
+			log.Warn().Str("address", address).Str("provider", requestedProvider).Err(err).Msg("provider unavailable")
+		default:
+			utils.ProviderLookupErrors.WithLabelValues(requestedProvider).Inc()
 			log.Error().Str("address", address).Str("provider", requestedProvider).Err(err).Msg("failed to lookup ip address")
 			sentry.CaptureException(err)
-			return err
 		}
+
+		return nil, err
 	}
 
-	if cached {
+	if cp != nil && ip != nil {
 		log.Trace().Str("address", address).Msg("adding to cache")
-		if err := cp.Add(c.Request().Context(), requestedProvider, ip); err != nil {
+		addCtx, span := utils.Tracer().Start(ctx, "cache.Add", trace.WithAttributes(
+			attribute.String("geo.provider", requestedProvider),
+			attribute.String("geo.address", address),
+		))
+		err := cp.Add(addCtx, requestedProvider, ip)
+		utils.EndSpan(span, err)
+		if err != nil {
 			log.Error().Err(err).Msg("failed to update cache")
 		}
 	}
 
-	return c.JSON(http.StatusOK, ip)
+	return ip, nil
 }
 
 func getRequestedProvider(ctx context.Context, name string) (provider.IPProvider, error) {
@@ -227,6 +671,20 @@ func getRequestedProvider(ctx context.Context, name string) (provider.IPProvider
 		return utils.Container.Fetch(ctx, utils.IpStackProvider).(provider.IPProvider), nil
 	case "globio":
 		return utils.Container.Fetch(ctx, utils.GlobioProvider).(provider.IPProvider), nil
+	case "ip2location":
+		return utils.Container.Fetch(ctx, utils.Ip2LocationProvider).(provider.IPProvider), nil
+	case "mmdb":
+		return utils.Container.Fetch(ctx, utils.MmdbProvider).(provider.IPProvider), nil
+	case "overrides":
+		return utils.Container.Fetch(ctx, utils.OverridesProvider).(provider.IPProvider), nil
+	case "geofeed":
+		return utils.Container.Fetch(ctx, utils.GeofeedProvider).(provider.IPProvider), nil
+	case "cloud":
+		return utils.Container.Fetch(ctx, utils.CloudProvider).(provider.IPProvider), nil
+	case "blocklist":
+		return utils.Container.Fetch(ctx, utils.BlocklistProvider).(provider.IPProvider), nil
+	case "cascade":
+		return utils.Container.Fetch(ctx, utils.CascadeProvider).(provider.IPProvider), nil
 	default:
 		return nil, &utils.UnknownProviderError{}
 	}
@@ -242,6 +700,18 @@ func isProviderEnabled(name string) bool {
 		return viper.GetBool("providers.ipstack.enabled")
 	case "globio":
 		return viper.GetBool("providers.globio.enabled")
+	case "ip2location":
+		return viper.GetBool("providers.ip2location.enabled")
+	case "mmdb":
+		return viper.GetBool("providers.mmdb.enabled")
+	case "overrides":
+		return viper.GetBool("providers.overrides.enabled")
+	case "geofeed":
+		return viper.GetBool("providers.geofeed.enabled")
+	case "cloud":
+		return viper.GetBool("providers.cloud.enabled")
+	case "blocklist":
+		return viper.GetBool("providers.blocklist.enabled")
 	case "cascade":
 		return viper.GetBool("providers.cascade.enabled")
 	default:
@@ -249,42 +719,244 @@ func isProviderEnabled(name string) bool {
 	}
 }
 
+// getEnabledProviderNames returns the names of the enabled providers, with the
+// cascade provider last
+func getEnabledProviderNames() []string {
+	var names []string
+	for _, name := range providerNames {
+		if isProviderEnabled(name) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// getEnabledProviders returns the enabled providers other than cascade, which
+// only delegates to them
 func getEnabledProviders(ctx context.Context) []provider.IPProvider {
 	var providers []provider.IPProvider
 
-	if viper.GetBool("providers.maxmind.enabled") {
-		providers = append(providers, utils.Container.Fetch(ctx, utils.MaxMindProvider).(provider.IPProvider))
+	for _, name := range getEnabledProviderNames() {
+		if name == "cascade" {
+			continue
+		}
+
+		ipProvider, err := getRequestedProvider(ctx, name)
+		if err != nil {
+			continue
+		}
+
+		providers = append(providers, ipProvider)
 	}
 
-	if viper.GetBool("providers.dbip.enabled") {
-		providers = append(providers, utils.Container.Fetch(ctx, utils.DbIpProvider).(provider.IPProvider))
+	return providers
+}
+
+// startProvider starts the named provider and records the outcome for
+// /_health. A provider starting without some of its databases keeps serving
+func startProvider(ctx context.Context, name string, ipProvider provider.IPProvider) {
+	err := ipProvider.Start(ctx)
+	var loadErr *utils.LoadError
+	if errors.As(err, &loadErr) {
+		log.Warn().Err(err).Str("provider", name).Msg("started without some of its databases")
+	} else if err != nil {
+		log.Fatal().Err(err).Msgf("failed to start %s provider", name)
 	}
 
-	if viper.GetBool("providers.ipstack.enabled") {
-		providers = append(providers, utils.Container.Fetch(ctx, utils.IpStackProvider).(provider.IPProvider))
+	recordRefresh(name, err)
+}
+
+// refreshProvider refreshes the named provider and records the outcome for
+// /_health. Refreshes run one at a time so the ticker and the admin API
+// don't download the same databases at once
+func refreshProvider(ctx context.Context, name string) error {
+	ipProvider, err := getRequestedProvider(ctx, name)
+	if err != nil {
+		return err
 	}
 
-	if viper.GetBool("providers.globio.enabled") {
-		providers = append(providers, utils.Container.Fetch(ctx, utils.GlobioProvider).(provider.IPProvider))
+	refreshLock.Lock()
+	defer refreshLock.Unlock()
+
+	err = ipProvider.Refresh(ctx)
+	recordRefresh(name, err)
+
+	return err
+}
+
+// newMemoryCache returns the in-memory cache, keyed by network when
+// cache.prefix.enabled is set and by address otherwise
+func newMemoryCache(ctx context.Context) (cache.CacheProvider, error) {
+	if viper.GetBool("cache.prefix.enabled") {
+		return cache.NewPrefixCache(ctx)
 	}
 
-	return providers
+	return cache.NewLocalCache(ctx)
 }
 
+// cacheSettings are the cache settings the cache in the container was built
+// with, and cacheDiskPath the file its disk cache holds, if any. Config
+// reloads only replace the cache when its settings change
+var (
+	cacheSettings string
+	cacheDiskPath string
+)
+
+// configureCache builds the cache from the cache settings and puts it in the
+// container
 func configureCache(ctx context.Context) error {
-	cache, err := cache.NewLocalCache(ctx)
+	settings := fmt.Sprint(viper.AllSettings()["cache"])
+	if settings == cacheSettings {
+		return nil
+	}
+
+	// bbolt locks its file, so the disk cache using it is closed before it
+	// is opened again, once the requests using it are done. It is reopened
+	// if the new cache fails to start
+	var previous *activeCache
+	if viper.GetString("cache.type") == "disk" && viper.GetString("cache.disk.path") == cacheDiskPath {
+		previous = utils.Container.Fetch(ctx, utils.Cache).(*activeCache)
+		previous.Lock()
+		defer previous.Unlock()
+		closeCache(previous.CacheProvider)
+	}
+
+	cp, err := newCache(ctx)
 	if err != nil {
+		if previous != nil {
+			if reopener, ok := previous.CacheProvider.(interface{ Reopen() error }); ok {
+				if err := reopener.Reopen(); err != nil {
+					log.Error().Err(err).Msg("failed to reopen the previous cache")
+				}
+			}
+		}
+
 		return err
 	}
 
-	utils.Container.Assign(ctx, utils.Cache, cache)
+	replaced := utils.Container.Replace(ctx, utils.Cache, &activeCache{CacheProvider: cp})
+	if previous != nil {
+		previous.closed = true
+	} else if replaced != nil {
+		closeCache(replaced)
+	}
+
+	cacheSettings = settings
+	cacheDiskPath = ""
+	if viper.GetString("cache.type") == "disk" {
+		cacheDiskPath = viper.GetString("cache.disk.path")
+	}
 
 	return nil
 }
 
+// newCache builds the cache from the cache settings
+func newCache(ctx context.Context) (cache.CacheProvider, error) {
+	var cp cache.CacheProvider
+	switch viper.GetString("cache.type") {
+	case "memory":
+		memoryCache, err := newMemoryCache(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cp = memoryCache
+	case "disk":
+		diskCache, err := cache.NewDiskCache(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cp = diskCache
+	case "redis":
+		redisCache, err := cache.NewRedisCache(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cp = redisCache
+	default:
+		return nil, fmt.Errorf("unknown cache type %s. Use memory, disk or redis", viper.GetString("cache.type"))
+	}
+
+	// the in-memory cache goes in front of the others
+	if viper.GetString("cache.type") != "memory" && viper.GetBool("cache.tiered") {
+		memoryCache, err := newMemoryCache(ctx)
+		if err != nil {
+			closeCache(cp)
+			return nil, err
+		}
+
+		tieredCache, err := cache.NewTieredCache(ctx, memoryCache, cp)
+		if err != nil {
+			closeCache(cp)
+			return nil, err
+		}
+		cp = tieredCache
+	}
+
+	return cp, nil
+}
+
+// activeCache is the cache in the container. Requests hold it for reading
+// while they use it so a replaced cache is only closed once they are done
+type activeCache struct {
+	cache.CacheProvider
+	sync.RWMutex
+	closed bool
+}
+
+// Close closes the cache once the requests using it are done
+func (ac *activeCache) Close() error {
+	ac.Lock()
+	defer ac.Unlock()
+
+	ac.closed = true
+	if closer, ok := ac.CacheProvider.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// acquireCache returns the cache and the function to call once done with it
+func acquireCache(ctx context.Context) (cache.CacheProvider, func()) {
+	for {
+		cp := utils.Container.Fetch(ctx, utils.Cache).(cache.CacheProvider)
+		shared, ok := cp.(*activeCache)
+		if !ok {
+			return cp, func() {}
+		}
+
+		shared.RLock()
+		if !shared.closed {
+			return shared.CacheProvider, shared.RUnlock
+		}
+		shared.RUnlock()
+
+		// a closed cache that wasn't replaced is only left after shutdown
+		if utils.Container.Fetch(ctx, utils.Cache) == cp {
+			return shared.CacheProvider, func() {}
+		}
+	}
+}
+
+// closeCache closes caches holding files or connections
+func closeCache(cp interface{}) {
+	if closer, ok := cp.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Error().Err(err).Msg("failed to close the cache")
+		}
+	}
+}
+
 func execServe(cmd *cobra.Command, args []string) {
 	ctx := context.Background()
 
+	// tracing goes first so provider downloads at startup are traced too
+	shutdownTracing, err := utils.ConfigureTracing(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to configure tracing")
+	}
+
 	// which provider
 	defaultProviderName := viper.GetString("default")
 	log.Info().Str("default", defaultProviderName).Msg("using provider as default")
@@ -301,10 +973,7 @@ func execServe(cmd *cobra.Command, args []string) {
 		}
 
 		utils.Container.Assign(ctx, utils.MaxMindProvider, ipProvider)
-		err = ipProvider.Start(ctx)
-		if err != nil {
-			log.Fatal().Err(err).Msg("failed to start maxmind provider")
-		}
+		startProvider(ctx, "maxmind", ipProvider)
 	}
 
 	if viper.GetBool("providers.dbip.enabled") {
@@ -314,10 +983,7 @@ func execServe(cmd *cobra.Command, args []string) {
 		}
 
 		utils.Container.Assign(ctx, utils.DbIpProvider, ipProvider)
-		err = ipProvider.Start(ctx)
-		if err != nil {
-			log.Fatal().Err(err).Msg("failed to start dbip provider")
-		}
+		startProvider(ctx, "dbip", ipProvider)
 	}
 	if viper.GetBool("providers.ipstack.enabled") {
 		ipProvider, err := provider.NewIpStackProvider(ctx)
@@ -326,10 +992,7 @@ func execServe(cmd *cobra.Command, args []string) {
 		}
 
 		utils.Container.Assign(ctx, utils.IpStackProvider, ipProvider)
-		err = ipProvider.Start(ctx)
-		if err != nil {
-			log.Fatal().Err(err).Msg("failed to start ipstack provider")
-		}
+		startProvider(ctx, "ipstack", ipProvider)
 	}
 
 	if viper.GetBool("providers.globio.enabled") {
@@ -339,42 +1002,123 @@ func execServe(cmd *cobra.Command, args []string) {
 		}
 
 		utils.Container.Assign(ctx, utils.GlobioProvider, ipProvider)
-		err = ipProvider.Start(ctx)
+		startProvider(ctx, "globio", ipProvider)
+	}
+
+	if viper.GetBool("providers.ip2location.enabled") {
+		ipProvider, err := provider.NewIp2LocationProvider(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to open ip2location provider")
+		}
+
+		utils.Container.Assign(ctx, utils.Ip2LocationProvider, ipProvider)
+		startProvider(ctx, "ip2location", ipProvider)
+	}
+
+	if viper.GetBool("providers.mmdb.enabled") {
+		ipProvider, err := provider.NewMmdbProvider(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to open mmdb provider")
+		}
+
+		utils.Container.Assign(ctx, utils.MmdbProvider, ipProvider)
+		startProvider(ctx, "mmdb", ipProvider)
+	}
+
+	if viper.GetBool("providers.overrides.enabled") {
+		ipProvider, err := provider.NewOverridesProvider(ctx)
 		if err != nil {
-			log.Fatal().Err(err).Msg("failed to start globio provider")
+			log.Fatal().Err(err).Msg("failed to open overrides provider")
+		}
+
+		utils.Container.Assign(ctx, utils.OverridesProvider, ipProvider)
+		startProvider(ctx, "overrides", ipProvider)
+
+		if sharedCache() {
+			log.Warn().Msg("the cache is shared, so the admin API refuses to edit the overrides")
 		}
 	}
 
+	if viper.GetBool("providers.geofeed.enabled") {
+		ipProvider, err := provider.NewGeofeedProvider(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to open geofeed provider")
+		}
+
+		utils.Container.Assign(ctx, utils.GeofeedProvider, ipProvider)
+		startProvider(ctx, "geofeed", ipProvider)
+	}
+
+	if viper.GetBool("providers.cloud.enabled") {
+		ipProvider, err := provider.NewCloudProvider(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to open cloud provider")
+		}
+
+		utils.Container.Assign(ctx, utils.CloudProvider, ipProvider)
+		startProvider(ctx, "cloud", ipProvider)
+	}
+
+	if viper.GetBool("providers.blocklist.enabled") {
+		ipProvider, err := provider.NewBlocklistProvider(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to open blocklist provider")
+		}
+
+		utils.Container.Assign(ctx, utils.BlocklistProvider, ipProvider)
+		startProvider(ctx, "blocklist", ipProvider)
+	}
+
 	// this should always be the last and all used providers should be enabled
 	if viper.GetBool("providers.cascade.enabled") {
 		providers := make([]provider.IPProvider, 0)
 		for _, providerName := range viper.GetStringSlice("providers.cascade.providers") {
 			log.Info().Str("provider", providerName).Msg("adding provider to cascade")
-			switch providerName {
-			case "maxmind":
-				providers = append(providers, utils.Container.Fetch(ctx, utils.MaxMindProvider).(provider.IPProvider))
-			case "dbip":
-				providers = append(providers, utils.Container.Fetch(ctx, utils.DbIpProvider).(provider.IPProvider))
-			case "ipstack":
-				providers = append(providers, utils.Container.Fetch(ctx, utils.IpStackProvider).(provider.IPProvider))
-			case "globio":
-				providers = append(providers, utils.Container.Fetch(ctx, utils.GlobioProvider).(provider.IPProvider))
-			default:
-				log.Fatal().Str("provider", providerName).Msg("unknown provider")
+			if providerName == "cascade" {
+				log.Fatal().Msg("the cascade provider can't be a member of itself")
 			}
 
-			ipProvider, err := provider.NewCascadeIPProvider(ctx, viper.GetBool("providers.cascade.stopOnError"), providers)
+			ipProvider, err := getRequestedProvider(ctx, providerName)
 			if err != nil {
-				log.Fatal().Err(err).Msg("failed to open cascade provider")
+				log.Fatal().Str("provider", providerName).Msg("unknown provider")
 			}
+			providers = append(providers, ipProvider)
+		}
 
-			err = ipProvider.Start(ctx)
-			if err != nil {
-				log.Fatal().Err(err).Msg("failed to start ip provider")
+		patches := make([]provider.Patcher, 0)
+		for _, providerName := range viper.GetStringSlice("providers.cascade.patches") {
+			log.Info().Str("provider", providerName).Msg("adding patch to cascade")
+			ipProvider, err := getRequestedProvider(ctx, providerName)
+			if err != nil || providerName == "cascade" {
+				log.Fatal().Str("provider", providerName).Msg("unknown provider")
+			}
+
+			patch, ok := ipProvider.(provider.Patcher)
+			if !ok {
+				log.Fatal().Str("provider", providerName).Msg("the provider can't patch the answers of the cascade")
 			}
+			patches = append(patches, patch)
+		}
+
+		// the patches are applied after the cache, so only the databases of
+		// the providers change the cached answers
+		utils.SetProviderMembers("cascade", viper.GetStringSlice("providers.cascade.providers"))
+		ipProvider, err := provider.NewCascadeIPProvider(ctx, viper.GetBool("providers.cascade.stopOnError"), providers, patches)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to open cascade provider")
+		}
+
+		utils.Container.Assign(ctx, utils.CascadeProvider, ipProvider)
+		err = ipProvider.Start(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to start ip provider")
 		}
 	}
 
+	if err := utils.ConfigureReservedRanges(); err != nil {
+		log.Fatal().Err(err).Msg("failed to configure reserved address ranges")
+	}
+
 	if viper.GetBool("cache.enabled") {
 		err := configureCache(ctx)
 		if err != nil {
@@ -382,10 +1126,23 @@ func execServe(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	err := startServer(ctx)
+	if viper.GetBool("auth.enabled") {
+		err := configureApiKeys(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to load API keys")
+		}
+	}
+
+	err = startServer(ctx)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to start the api server")
 	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("failed to flush traces")
+	}
 }
 
 func ping(c echo.Context) error {
@@ -398,8 +1155,38 @@ func startServer(ctx context.Context) error {
 	e.HidePort = true
 	e.Use(middleware.RequestID())
 	e.Use(utils.ZeroLogger(&log.Logger))
+	e.Use(utils.Tracing())
 	e.GET("/_ping", ping)
-	e.GET("/v1/ip/:address", getIP)
+	e.GET("/_ready", getReady)
+	e.GET("/_health", getHealth)
+
+	if viper.GetBool("metrics.enabled") {
+		e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+	}
+	e.GET("/v1/ip/:address", getIP, apiKeyAuth(getIPProvider))
+
+	if viper.GetBool("geoip.enabled") {
+		registerGeoIPRoutes(e)
+	}
+
+	if viper.GetBool("compat.ipinfo.enabled") {
+		registerIpInfoRoutes(e)
+	}
+
+	var adminServer *echo.Echo
+	if viper.GetBool("admin.enabled") {
+		if viper.GetInt("admin.port") == 0 {
+			if err := registerAdminRoutes(e); err != nil {
+				return err
+			}
+		} else {
+			var err error
+			adminServer, err = startAdminServer(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
 
 	go func() {
 		if err := e.Start(fmt.Sprintf("%s:%d", viper.GetString("api.binding"), viper.GetInt("api.port"))); err != nil {
@@ -409,20 +1196,64 @@ func startServer(ctx context.Context) error {
 		}
 	}()
 
+	var grpcServer *grpc.Server
+	var grpcHealth *health.Server
+	if viper.GetBool("grpc.enabled") {
+		var err error
+		grpcServer, grpcHealth, err = startGrpcServer(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	var whois *whoisServer
+	if viper.GetBool("whois.enabled") {
+		var err error
+		whois, err = startWhoisServer(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	var dnsServers []*dns.Server
+	if viper.GetBool("dns.enabled") {
+		var err error
+		dnsServers, err = startDnsServers(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
 	stopRefresh := make(chan bool)
 	// refresh in intervals
 	ticker := time.NewTicker(time.Duration(viper.GetDuration("refresh")))
+	// blocklists such as the Tor exit list change within the hour, so they
+	// have a refresh of their own
+	var blocklistRefresh <-chan time.Time
+	if viper.GetBool("providers.blocklist.enabled") && viper.GetDuration("providers.blocklist.refresh") > 0 {
+		blocklistTicker := time.NewTicker(viper.GetDuration("providers.blocklist.refresh"))
+		defer blocklistTicker.Stop()
+		blocklistRefresh = blocklistTicker.C
+	}
 	go func() {
 		for {
 			select {
 			case <-ticker.C:
 				log.Info().Msg("refreshing providers")
-				for _, provider := range getEnabledProviders(ctx) {
-					err := provider.Refresh(ctx)
+				for _, name := range getEnabledProviderNames() {
+					if name == "cascade" {
+						continue
+					}
+
+					err := refreshProvider(ctx, name)
 					if err != nil {
-						log.Error().Err(err).Msg("failed to refresh provider")
+						log.Error().Err(err).Str("provider", name).Msg("failed to refresh provider")
 					}
 				}
+			case <-blocklistRefresh:
+				if err := refreshProvider(ctx, "blocklist"); err != nil {
+					log.Error().Err(err).Msg("failed to refresh blocklists")
+				}
 			case <-stopRefresh:
 				log.Info().Msg("stopping refresh")
 				return
@@ -436,16 +1267,36 @@ func startServer(ctx context.Context) error {
 
 	stopRefresh <- true
 
-	// shutdown the all enabled providers
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if grpcServer != nil {
+		stopGrpcServer(shutdownCtx, grpcServer, grpcHealth)
+	}
+
+	if whois != nil {
+		whois.shutdown(shutdownCtx)
+	}
+
+	stopDnsServers(shutdownCtx, dnsServers)
+
+	if adminServer != nil {
+		if err := adminServer.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("failed to shutdown the admin server")
+		}
+	}
+
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("failed to shutdown the server")
+	}
+
+	// providers and the cache are closed once no request can use them
 	for _, provider := range getEnabledProviders(ctx) {
 		provider.Shutdown(ctx)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	if err := e.Shutdown(ctx); err != nil {
-		e.Logger.Fatal(err)
+	if cacheSettings != "" {
+		closeCache(utils.Container.Fetch(ctx, utils.Cache))
 	}
 
 	return nil
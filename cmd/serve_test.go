@@ -2,20 +2,30 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cloud66-oss/geo/cache"
 	"github.com/cloud66-oss/geo/provider"
 	"github.com/cloud66-oss/geo/utils"
 	"github.com/labstack/echo"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 type mockProvider struct {
@@ -75,6 +85,11 @@ func (mcp *mockCacheProvider) Add(ctx context.Context, provider string, ipInfo *
 	return args.Error(0)
 }
 
+func (mcp *mockCacheProvider) Purge(ctx context.Context, provider string, address string) (int, error) {
+	args := mcp.Called(ctx, provider, address)
+	return args.Int(0), args.Error(1)
+}
+
 func (suite *serveCmdTestSuite) SetupTest() {
 	ctx := context.Background()
 	utils.Container.Clear(ctx)
@@ -165,6 +180,351 @@ func (suite *serveCmdTestSuite) TestLookupWithEmptyCache() {
 		suite.cache.AssertCalled(suite.T(), "Add", mock.Anything, "maxmind", mock.MatchedBy(func(ipInfo *utils.IPInfo) bool { return ipInfo.Address == "2.2.2.2" }))
 	}
 }
+
+func (suite *serveCmdTestSuite) TestRequestMetrics() {
+	viper.Set("cache.enabled", false)
+
+	suite.provider.On("Lookup", mock.Anything, "3.3.3.3").Return(&utils.IPInfo{Address: "3.3.3.3"}, nil)
+
+	e := echo.New()
+	e.Use(utils.ZeroLogger(&log.Logger))
+	e.GET("/v1/ip/:address", getIP)
+
+	counter := utils.RequestsTotal.WithLabelValues("/v1/ip/:address", "maxmind", "200")
+	before := testutil.ToFloat64(counter)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/ip/3.3.3.3", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	suite.Assert().EqualValues(http.StatusOK, rec.Code)
+	suite.Assert().EqualValues(before+1, testutil.ToFloat64(counter))
+}
+
+func (suite *serveCmdTestSuite) TestUnknownProviderMetrics() {
+	viper.Set("cache.enabled", false)
+
+	e := echo.New()
+	e.Use(utils.ZeroLogger(&log.Logger))
+	e.GET("/v1/ip/:address", getIP)
+
+	request := func(provider string) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/ip/3.3.3.3?provider="+provider, nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		suite.EqualValues(http.StatusBadRequest, rec.Code)
+	}
+
+	request("nope-1")
+	counter := utils.RequestsTotal.WithLabelValues("/v1/ip/:address", "unknown", "400")
+	before := testutil.ToFloat64(counter)
+	series := testutil.CollectAndCount(utils.RequestsTotal)
+
+	// names that aren't providers share a single series
+	request("nope-2")
+	suite.EqualValues(before+1, testutil.ToFloat64(counter))
+	suite.Equal(series, testutil.CollectAndCount(utils.RequestsTotal))
+}
+
+func (suite *serveCmdTestSuite) TestConfigureCache() {
+	ctx := context.Background()
+	utils.Container.Clear(ctx)
+	viper.Set("cache.type", "disk")
+	viper.Set("cache.tiered", false)
+	viper.Set("cache.disk.path", filepath.Join(suite.T().TempDir(), "cache.db"))
+	defer func() {
+		closeCache(utils.Container.Fetch(ctx, utils.Cache))
+		cacheSettings, cacheDiskPath = "", ""
+		viper.Set("cache.type", "memory")
+		viper.Set("cache.tiered", true)
+		viper.Set("cache.ttl.default", 0)
+	}()
+
+	suite.Require().NoError(configureCache(ctx))
+	first := utils.Container.Fetch(ctx, utils.Cache)
+
+	// reloads with the same settings keep the cache
+	suite.Require().NoError(configureCache(ctx))
+	suite.Same(first, utils.Container.Fetch(ctx, utils.Cache))
+
+	// the disk cache lets go of its file for the one replacing it
+	viper.Set("cache.ttl.default", "1h")
+	suite.Require().NoError(configureCache(ctx))
+	cp := utils.Container.Fetch(ctx, utils.Cache).(cache.CacheProvider)
+	suite.NotSame(first, cp)
+
+	suite.Require().NoError(cp.Add(ctx, "maxmind", &utils.IPInfo{Address: "1.1.1.1"}))
+	ip, err := cp.Fetch(ctx, "maxmind", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.Require().NotNil(ip)
+
+	// the disk cache is reopened when its replacement fails to start
+	viper.Set("cache.tiered", true)
+	viper.Set("cache.size", 0)
+	suite.Error(configureCache(ctx))
+	viper.Set("cache.tiered", false)
+	viper.Set("cache.size", 128)
+	suite.Same(cp, utils.Container.Fetch(ctx, utils.Cache))
+	ip, err = cp.Fetch(ctx, "maxmind", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.NotNil(ip)
+
+	// a replaced cache is closed once the requests using it are done
+	held, release := acquireCache(ctx)
+	viper.Set("cache.type", "memory")
+	configured := make(chan error, 1)
+	go func() { configured <- configureCache(ctx) }()
+
+	suite.Never(func() bool { return len(configured) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+	ip, err = held.Fetch(ctx, "maxmind", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.NotNil(ip)
+
+	release()
+	suite.NoError(<-configured)
+	_, err = held.Fetch(ctx, "maxmind", "1.1.1.1")
+	suite.Error(err)
+}
+
+func (suite *serveCmdTestSuite) TestTracing() {
+	viper.Set("cache.enabled", true)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	suite.provider.On("Lookup", mock.Anything, "4.4.4.4").Return(&utils.IPInfo{Address: "4.4.4.4"}, nil)
+	suite.cache.On("Fetch", mock.Anything, "maxmind", "4.4.4.4").Return(nil, nil)
+	suite.cache.On("Add", mock.Anything, "maxmind", mock.Anything).Return(nil)
+
+	e := echo.New()
+	e.Use(utils.Tracing())
+	e.GET("/v1/ip/:address", getIP)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/ip/4.4.4.4", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	suite.Assert().EqualValues(http.StatusOK, rec.Code)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	server, ok := spans["GET /v1/ip/:address"]
+	if suite.Assert().True(ok) {
+		suite.Assert().Equal("4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+		suite.Assert().Equal("00f067aa0ba902b7", server.Parent().SpanID().String())
+	}
+
+	for _, name := range []string{"cache.Fetch", "provider.Lookup", "cache.Add"} {
+		span, ok := spans[name]
+		if suite.Assert().True(ok, name) {
+			suite.Assert().Equal(server.SpanContext().SpanID(), span.Parent().SpanID(), name)
+		}
+	}
+}
+
+func (suite *serveCmdTestSuite) TestNormalizedAddress() {
+	viper.Set("cache.enabled", true)
+	viper.Set("address.embedded_ipv4", []string{utils.NAT64})
+	defer viper.Set("address.embedded_ipv4", []string{})
+
+	suite.provider.On("Lookup", mock.Anything, "6.6.6.6").Return(&utils.IPInfo{Address: "6.6.6.6"}, nil)
+	suite.provider.On("Lookup", mock.Anything, "2606:4700::6").Return(&utils.IPInfo{Address: "2606:4700::6"}, nil)
+	suite.cache.On("Fetch", mock.Anything, "maxmind", mock.Anything).Return(nil, nil)
+	suite.cache.On("Add", mock.Anything, "maxmind", mock.Anything).Return(nil)
+
+	for requested, effective := range map[string]string{
+		"::ffff:6.6.6.6":   "6.6.6.6",
+		"64:ff9b::606:606": "6.6.6.6",
+		"2606:4700:0::6":   "2606:4700::6",
+	} {
+		ip, err := lookupIP(context.Background(), "maxmind", requested)
+		suite.Require().NoError(err)
+		suite.Equal(effective, ip.Address)
+		suite.Equal(requested, ip.OriginalAddress)
+		suite.cache.AssertCalled(suite.T(), "Fetch", mock.Anything, "maxmind", effective)
+	}
+
+	_, err := lookupIP(context.Background(), "maxmind", "fe80::1%eth0")
+	suite.IsType(&utils.IpAddressError{}, err)
+}
+
+func (suite *serveCmdTestSuite) TestReservedAddress() {
+	viper.Set("cache.enabled", true)
+
+	e := echo.New()
+	e.GET("/v1/ip/:address", getIP)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/ip/100.64.1.1", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	suite.EqualValues(http.StatusOK, rec.Code)
+	var info utils.IPInfo
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &info))
+	suite.Equal("reserved", info.Source)
+	suite.Equal(&utils.ReservedRange{Network: "100.64.0.0/10", Name: "Shared Address Space", RFC: "RFC 6598"}, info.Reserved)
+
+	// unknown providers are refused for reserved addresses too
+	req = httptest.NewRequest(http.MethodGet, "/v1/ip/10.0.0.1?provider=bogus", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	suite.EqualValues(http.StatusBadRequest, rec.Code)
+
+	viper.Set("reserved.response", "error")
+	defer viper.Set("reserved.response", "reserved")
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/ip/fe80::1", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	suite.EqualValues(http.StatusUnprocessableEntity, rec.Code)
+	var body utils.ErrorResponse
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+	suite.Equal("IP_ADDRESS_RESERVED", body.Code)
+	suite.Equal("Link-Local Unicast", body.Reserved.Name)
+
+	suite.provider.AssertNotCalled(suite.T(), "Lookup", mock.Anything, mock.Anything)
+	suite.cache.AssertNotCalled(suite.T(), "Fetch", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *serveCmdTestSuite) TestOverridesPatch() {
+	ctx := context.Background()
+	viper.Set("cache.enabled", false)
+
+	file := filepath.Join(suite.T().TempDir(), "overrides.yml")
+	suite.Require().NoError(os.WriteFile(file, []byte("overrides:\n"+
+		"  - network: 1.1.1.0/24\n    country: AU\n    tags: [anycast]\n"+
+		"  - network: 10.0.0.0/8\n    city: London\n"), 0600))
+	viper.Set("providers.overrides.file", file)
+	viper.Set("providers.overrides.watch", false)
+	viper.Set("providers.overrides.enabled", true)
+	viper.Set("providers.overrides.patch", true)
+	defer viper.Set("providers.overrides.enabled", false)
+	defer viper.Set("providers.overrides.patch", false)
+
+	overrides, err := provider.NewOverridesProvider(ctx)
+	suite.Require().NoError(err)
+	suite.Require().NoError(overrides.Start(ctx))
+	utils.Container.Assign(ctx, utils.OverridesProvider, overrides)
+
+	suite.provider.On("Lookup", mock.Anything, "1.1.1.1").Return(&utils.IPInfo{
+		Address: "1.1.1.1",
+		Source:  "maxmind",
+		Country: &utils.Country{IsoCode: "US"},
+	}, nil)
+
+	info, err := lookupIP(ctx, "maxmind", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.Equal("maxmind", info.Source)
+	suite.Equal("AU", info.Country.IsoCode)
+	suite.Equal([]string{"anycast"}, info.Tags)
+
+	// reserved answers are patched too
+	info, err = lookupIP(ctx, "maxmind", "10.1.2.3")
+	suite.Require().NoError(err)
+	suite.Equal("reserved", info.Source)
+	suite.Equal("London", info.City.Names["en"])
+
+	// and the overrides provider itself isn't patched twice
+	info, err = lookupIP(ctx, "overrides", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.Equal("overrides", info.Source)
+
+	// reserved addresses get their overrides without the patch layer
+	viper.Set("providers.overrides.patch", false)
+	info, err = lookupIP(ctx, "maxmind", "10.1.2.3")
+	suite.Require().NoError(err)
+	suite.Equal("London", info.City.Names["en"])
+}
+
+func (suite *serveCmdTestSuite) TestCascadePatches() {
+	ctx := context.Background()
+	viper.Set("cache.enabled", true)
+
+	file := filepath.Join(suite.T().TempDir(), "overrides.yml")
+	suite.Require().NoError(os.WriteFile(file, []byte("overrides:\n  - network: 1.1.1.0/24\n    tags: [anycast]\n"), 0600))
+	viper.Set("providers.overrides.file", file)
+	viper.Set("providers.overrides.watch", false)
+
+	overrides, err := provider.NewOverridesProvider(ctx)
+	suite.Require().NoError(err)
+	suite.Require().NoError(overrides.Start(ctx))
+
+	cascade, err := provider.NewCascadeIPProvider(ctx, true, []provider.IPProvider{suite.provider}, []provider.Patcher{overrides})
+	suite.Require().NoError(err)
+	utils.Container.Assign(ctx, utils.CascadeProvider, cascade)
+
+	answer := &utils.IPInfo{Address: "1.1.1.1", Source: "maxmind"}
+	suite.provider.On("Lookup", mock.Anything, "1.1.1.1").Return(answer, nil).Once()
+	suite.cache.On("Fetch", mock.Anything, "cascade", "1.1.1.1").Return(nil, nil).Once()
+	suite.cache.On("Add", mock.Anything, "cascade", answer).Return(nil).Once()
+
+	// the answer is cached without the patches
+	info, err := lookupIP(ctx, "cascade", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.Equal([]string{"anycast"}, info.Tags)
+	suite.Empty(answer.Tags)
+
+	// and patched on the way out of the cache
+	suite.cache.On("Fetch", mock.Anything, "cascade", "1.1.1.1").Return(answer, nil).Once()
+	info, err = lookupIP(ctx, "cascade", "1.1.1.1")
+	suite.Require().NoError(err)
+	suite.Equal([]string{"anycast"}, info.Tags)
+
+	suite.provider.AssertExpectations(suite.T())
+	suite.cache.AssertExpectations(suite.T())
+}
+
+func (suite *serveCmdTestSuite) TestCoalescedLookups() {
+	viper.Set("cache.enabled", false)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	suite.provider.On("Lookup", mock.Anything, "5.5.5.5").Return(&utils.IPInfo{Address: "5.5.5.5"}, nil).Once().Run(func(args mock.Arguments) {
+		close(started)
+		<-release
+	})
+
+	coalesced := utils.LookupsCoalesced.WithLabelValues("maxmind")
+	before := testutil.ToFloat64(coalesced)
+
+	var wg sync.WaitGroup
+	addresses := []string{"5.5.5.5", "5.5.5.5", "::ffff:5.5.5.5"}
+	results := make([]*utils.IPInfo, len(addresses))
+	lookup := func(i int) {
+		defer wg.Done()
+		ip, err := lookupIP(context.Background(), "maxmind", addresses[i])
+		suite.NoError(err)
+		results[i] = ip
+	}
+
+	wg.Add(len(addresses))
+	go lookup(0)
+	<-started
+	for i := 1; i < len(addresses); i++ {
+		go lookup(i)
+	}
+	// let the followers join the lookup in flight
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	suite.provider.AssertNumberOfCalls(suite.T(), "Lookup", 1)
+	suite.EqualValues(before+2, testutil.ToFloat64(coalesced))
+	for i, address := range addresses {
+		if suite.NotNil(results[i]) {
+			suite.Equal("5.5.5.5", results[i].Address)
+			suite.Equal(address, results[i].OriginalAddress)
+		}
+	}
+}
+
 func TestServeCmdTestSuite(t *testing.T) {
 	suite.Run(t, new(serveCmdTestSuite))
 }
@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud66-oss/geo/provider"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// whoisServer answers IP to ASN queries using the Team Cymru whois protocol
+// (https://www.team-cymru.com/ip-asn-mapping) so existing netcat and whois
+// scripts can run against our own ASN databases
+type whoisServer struct {
+	listener     net.Listener
+	providerName string
+	timeout      time.Duration
+	wg           sync.WaitGroup
+}
+
+// whoisOptions are the output options of a query. In bulk mode they are
+// toggled by keywords between begin and end, otherwise by flags on the query
+type whoisOptions struct {
+	header      bool
+	prefix      bool
+	countryCode bool
+	asName      bool
+}
+
+func newWhoisServer(listener net.Listener, providerName string, timeout time.Duration) *whoisServer {
+	return &whoisServer{
+		listener:     listener,
+		providerName: providerName,
+		timeout:      timeout,
+	}
+}
+
+// startWhoisServer starts serving the whois protocol in the background
+func startWhoisServer(ctx context.Context) (*whoisServer, error) {
+	providerName := viper.GetString("whois.provider")
+	if providerName == "" {
+		providerName = viper.GetString("default")
+	}
+
+	ipProvider, err := getRequestedProvider(ctx, providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := ipProvider.(provider.ASNProvider); !ok {
+		return nil, fmt.Errorf("provider %s has no ASN database to serve whois from", providerName)
+	}
+
+	address := fmt.Sprintf("%s:%d", viper.GetString("whois.binding"), viper.GetInt("whois.port"))
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	server := newWhoisServer(listener, providerName, viper.GetDuration("whois.timeout"))
+
+	log.Info().Str("address", address).Str("provider", providerName).Msg("starting the whois server")
+	go server.serve(ctx)
+
+	return server, nil
+}
+
+func (s *whoisServer) serve(ctx context.Context) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			// the listener has been closed
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+// shutdown stops accepting connections and waits for the open ones to finish
+// until ctx is done
+func (s *whoisServer) shutdown(ctx context.Context) {
+	s.listener.Close()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Warn().Msg("timed out waiting for whois connections to finish")
+	}
+}
+
+func (s *whoisServer) handle(ctx context.Context, conn net.Conn) {
+	if s.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.timeout))
+	}
+
+	scanner := bufio.NewScanner(conn)
+	writer := bufio.NewWriter(conn)
+	defer writer.Flush()
+
+	// the first line is either a single query or the start of bulk mode
+	line := ""
+	for line == "" {
+		if !scanner.Scan() {
+			return
+		}
+		line = strings.TrimSpace(scanner.Text())
+	}
+
+	if !strings.EqualFold(line, "begin") {
+		options := whoisOptions{header: true, asName: true}
+		var addresses []string
+		for _, field := range strings.Fields(line) {
+			switch field {
+			case "-v":
+				options.header, options.prefix, options.countryCode = true, true, true
+			case "-p":
+				options.prefix = true
+			case "-c":
+				options.countryCode = true
+			case "-f":
+				options.header = false
+			default:
+				addresses = append(addresses, field)
+			}
+		}
+
+		if options.header {
+			s.writeHeader(writer, options)
+		}
+		for _, address := range addresses {
+			s.writeAnswer(ctx, writer, options, address, 1)
+		}
+
+		return
+	}
+
+	fmt.Fprintf(writer, "Bulk mode; geo [%s]\n", time.Now().UTC().Format("2006-01-02 15:04:05 -0700"))
+
+	options := whoisOptions{asName: true}
+	headerWritten := false
+	lineNumber := 1
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		switch strings.ToLower(line) {
+		case "":
+			continue
+		case "end":
+			return
+		case "verbose":
+			options.header, options.prefix, options.countryCode = true, true, true
+		case "header":
+			options.header = true
+		case "noheader":
+			options.header = false
+		case "prefix":
+			options.prefix = true
+		case "noprefix":
+			options.prefix = false
+		case "countrycode":
+			options.countryCode = true
+		case "nocountrycode":
+			options.countryCode = false
+		case "asname":
+			options.asName = true
+		case "noasname":
+			options.asName = false
+		case "notruncate", "asnumber":
+			// accepted for compatibility, we never truncate and always return the AS number
+		default:
+			if options.header && !headerWritten {
+				s.writeHeader(writer, options)
+				headerWritten = true
+			}
+
+			// the address can be followed by a comment
+			s.writeAnswer(ctx, writer, options, strings.Fields(line)[0], lineNumber)
+		}
+
+		// push out the answers as we go for clients reading interactively
+		if err := writer.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *whoisServer) writeHeader(w io.Writer, options whoisOptions) {
+	columns := []string{"AS      ", "IP               "}
+	if options.prefix {
+		columns = append(columns, "BGP Prefix          ")
+	}
+	if options.countryCode {
+		columns = append(columns, "CC ")
+	}
+	if options.asName {
+		columns = append(columns, "AS Name")
+	}
+
+	fmt.Fprintln(w, strings.TrimRight(strings.Join(columns, "| "), " "))
+}
+
+func (s *whoisServer) writeAnswer(ctx context.Context, w io.Writer, options whoisOptions, address string, lineNumber int) {
+	if net.ParseIP(address) == nil {
+		fmt.Fprintf(w, "Error: no ASN or IP match on line %d.\n", lineNumber)
+		return
+	}
+
+	asNumber, prefix, countryCode, asName := "NA", "NA", "", "NA"
+
+	ipProvider, err := getRequestedProvider(ctx, s.providerName)
+	if err != nil {
+		fmt.Fprintf(w, "Error: %s\n", err.Error())
+		return
+	}
+
+	asnProvider, ok := ipProvider.(provider.ASNProvider)
+	if !ok {
+		fmt.Fprintf(w, "Error: provider %s has no ASN database\n", s.providerName)
+		return
+	}
+
+	asn, network, err := asnProvider.LookupASN(ctx, address)
+	if err != nil {
+		log.Error().Err(err).Str("address", address).Msg("failed to lookup ASN for whois")
+		fmt.Fprintf(w, "Error: failed to lookup %s\n", address)
+		return
+	}
+
+	if asn != nil && asn.AutonomousSystemNumber != 0 {
+		asNumber = fmt.Sprintf("%d", asn.AutonomousSystemNumber)
+		asName = asn.AutonomousSystemOrganization
+	}
+
+	if network != nil {
+		prefix = network.String()
+	}
+
+	if options.countryCode {
+		countryCode = whoisCountryCode(ctx, s.providerName, address)
+	}
+
+	columns := []string{fmt.Sprintf("%-8s", asNumber), fmt.Sprintf("%-17s", address)}
+	if options.prefix {
+		columns = append(columns, fmt.Sprintf("%-20s", prefix))
+	}
+	if options.countryCode {
+		columns = append(columns, fmt.Sprintf("%-3s", countryCode))
+	}
+	if options.asName {
+		columns = append(columns, asName)
+	}
+
+	fmt.Fprintln(w, strings.TrimRight(strings.Join(columns, "| "), " "))
+}
+
+// whoisCountryCode prefers the registered country as that is what the
+// registries (and so Team Cymru) report for a prefix
+func whoisCountryCode(ctx context.Context, providerName string, address string) string {
+	info, err := lookupIP(ctx, providerName, address)
+	if err != nil || info == nil {
+		return ""
+	}
+
+	if code := countryCode(info.RegisteredCountry); code != "" {
+		return code
+	}
+
+	return countryCode(info.Country)
+}
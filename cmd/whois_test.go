@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud66-oss/geo/provider"
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockAsnProvider struct {
+	mockProvider
+}
+
+var _ provider.ASNProvider = &mockAsnProvider{}
+
+func (mp *mockAsnProvider) LookupASN(ctx context.Context, address string) (*utils.ASN, *net.IPNet, error) {
+	args := mp.Called(ctx, address)
+
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+
+	return args.Get(0).(*utils.ASN), args.Get(1).(*net.IPNet), args.Error(2)
+}
+
+type whoisTestSuite struct {
+	suite.Suite
+	provider *mockAsnProvider
+	server   *whoisServer
+}
+
+func (suite *whoisTestSuite) SetupTest() {
+	ctx := context.Background()
+	utils.Container.Clear(ctx)
+	viper.Set("cache.enabled", false)
+
+	suite.provider = &mockAsnProvider{}
+	utils.Container.Assign(ctx, utils.MaxMindProvider, suite.provider)
+
+	_, network, _ := net.ParseCIDR("8.8.8.0/24")
+	suite.provider.On("LookupASN", mock.Anything, "8.8.8.8").Return(&utils.ASN{AutonomousSystemNumber: 15169, AutonomousSystemOrganization: "GOOGLE"}, network, nil)
+	suite.provider.On("Lookup", mock.Anything, "8.8.8.8").Return(&utils.IPInfo{Address: "8.8.8.8", Country: &utils.Country{IsoCode: "US"}}, nil)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+
+	suite.server = newWhoisServer(listener, "maxmind", 5*time.Second)
+	go suite.server.serve(ctx)
+}
+
+func (suite *whoisTestSuite) TearDownTest() {
+	suite.server.shutdown(context.Background())
+}
+
+func (suite *whoisTestSuite) query(input string) []string {
+	conn, err := net.Dial("tcp", suite.server.listener.Addr().String())
+	suite.Require().NoError(err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(input))
+	suite.Require().NoError(err)
+
+	output, err := io.ReadAll(conn)
+	suite.Require().NoError(err)
+
+	return strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+}
+
+func (suite *whoisTestSuite) TestSingleQuery() {
+	lines := suite.query(" -v 8.8.8.8\n")
+
+	suite.EqualValues([]string{
+		"AS      | IP               | BGP Prefix          | CC | AS Name",
+		"15169   | 8.8.8.8          | 8.8.8.0/24          | US | GOOGLE",
+	}, lines)
+}
+
+func (suite *whoisTestSuite) TestBulkQuery() {
+	lines := suite.query("begin\nverbose\n8.8.8.8\nnope\nend\n")
+
+	if suite.Len(lines, 4) {
+		suite.True(strings.HasPrefix(lines[0], "Bulk mode;"))
+		suite.EqualValues("AS      | IP               | BGP Prefix          | CC | AS Name", lines[1])
+		suite.EqualValues("15169   | 8.8.8.8          | 8.8.8.0/24          | US | GOOGLE", lines[2])
+		suite.EqualValues("Error: no ASN or IP match on line 4.", lines[3])
+	}
+}
+
+func (suite *whoisTestSuite) TestBulkQueryWithoutHeader() {
+	lines := suite.query("begin\n8.8.8.8\nend\n")
+
+	if suite.Len(lines, 2) {
+		suite.EqualValues("15169   | 8.8.8.8          | GOOGLE", lines[1])
+	}
+}
+
+func TestWhoisTestSuite(t *testing.T) {
+	suite.Run(t, new(whoisTestSuite))
+}
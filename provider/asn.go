@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/jinzhu/copier"
+	"github.com/oschwald/geoip2-golang"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// ASNProvider is implemented by providers with a local ASN database that can
+// report the network an address was matched in, on top of the ASN itself
+type ASNProvider interface {
+	LookupASN(ctx context.Context, address string) (*utils.ASN, *net.IPNet, error)
+}
+
+// readAsnDb opens an ASN database
+func readAsnDb(_ context.Context, file string) (*maxminddb.Reader, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	if !utils.FileExists(file) {
+		return nil, fmt.Errorf("file not found %s", file)
+	}
+
+	db, err := maxminddb.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// lookupAsnDb returns the ASN record for ip, the network it belongs to and
+// whether the address is in the database. The network is returned either way
+func lookupAsnDb(db *maxminddb.Reader, ip net.IP) (*utils.ASN, *net.IPNet, bool, error) {
+	var record geoip2.ASN
+	network, found, err := db.LookupNetwork(ip, &record)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	asn := &utils.ASN{}
+	err = copier.Copy(asn, &record)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	return asn, network, found, nil
+}
+
+// lookupAsn implements ASNProvider.LookupASN for the database backed providers
+func lookupAsn(db *maxminddb.Reader, address string) (*utils.ASN, *net.IPNet, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, nil, &utils.IpAddressError{}
+	}
+
+	if db == nil {
+		return nil, nil, nil
+	}
+
+	asn, network, found, err := lookupAsnDb(db, ip)
+	if err != nil || !found {
+		// a nil network means the address is not in the database
+		return asn, nil, err
+	}
+
+	return asn, network, nil
+}
@@ -0,0 +1,389 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+
+	"github.com/cloud66-oss/geo/utils"
+)
+
+// Flags set on the addresses of a blocklist
+const (
+	BlocklistFlagTor       = "tor"
+	BlocklistFlagVPN       = "vpn"
+	BlocklistFlagProxy     = "proxy"
+	BlocklistFlagHosting   = "hosting"
+	BlocklistFlagAnonymous = "anonymous"
+)
+
+// BlocklistSource is a plain text list of addresses and networks configured
+// under providers.blocklist.lists. Lists with a URL are downloaded to their
+// file
+type BlocklistSource struct {
+	Name string `mapstructure:"name"`
+	URL  string `mapstructure:"url"`
+	File string `mapstructure:"file"`
+	// Flag is the anonymous IP flag set on the addresses of the list
+	Flag string `mapstructure:"flag"`
+}
+
+// blocklistEntry is a network in the lookup table. specific is set when no
+// other network or address of the lists is inside it
+type blocklistEntry struct {
+	flags    utils.AnonymousIP
+	specific bool
+}
+
+// BlocklistProvider flags the addresses of the Tor bulk exit list and of
+// plain text blocklists of VPN and proxy exits as anonymous. Listed addresses
+// are kept in a set and listed networks in a prefix table. It is meant to
+// patch the answers of other providers in a cascade
+type BlocklistProvider struct {
+	sync.RWMutex
+	addrs  map[netip.Addr]utils.AnonymousIP
+	table  *utils.PrefixTable[*blocklistEntry]
+	starts prefixStarts
+	lists  []*DatabaseInfo
+}
+
+func NewBlocklistProvider(ctx context.Context) (*BlocklistProvider, error) {
+	return &BlocklistProvider{
+		addrs: map[netip.Addr]utils.AnonymousIP{},
+		table: utils.NewPrefixTable[*blocklistEntry](),
+	}, nil
+}
+
+func (bp *BlocklistProvider) Start(ctx context.Context) error {
+	log.Info().Msg("starting Blocklist Provider")
+
+	// a list that can't be fetched shouldn't keep the others from serving
+	err := bp.Refresh(ctx)
+	if err != nil && bp.Health(ctx).Ready {
+		return &utils.LoadError{Err: err}
+	}
+
+	return err
+}
+
+// blocklistSources returns the configured lists, with the file of downloaded
+// lists defaulting to providers.blocklist.path
+func blocklistSources() ([]*BlocklistSource, error) {
+	var sources []*BlocklistSource
+	if err := viper.UnmarshalKey("providers.blocklist.lists", &sources); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, source := range sources {
+		if source.Name == "" {
+			return nil, errors.New("blocklists need a name")
+		}
+		if names[source.Name] {
+			return nil, fmt.Errorf("blocklist %s is defined more than once", source.Name)
+		}
+		names[source.Name] = true
+
+		if err := setBlocklistFlag(&utils.AnonymousIP{}, source.Flag); err != nil {
+			return nil, fmt.Errorf("blocklist %s: %w", source.Name, err)
+		}
+
+		if source.File == "" {
+			if source.URL == "" {
+				return nil, fmt.Errorf("blocklist %s needs a url or a file", source.Name)
+			}
+			source.File = filepath.Join(viper.GetString("providers.blocklist.path"), source.Name+".txt")
+		}
+	}
+
+	return sources, nil
+}
+
+// setBlocklistFlag sets flag on flags. Every flag also marks the address as
+// anonymous, as the MaxMind anonymous IP database does
+func setBlocklistFlag(flags *utils.AnonymousIP, flag string) error {
+	switch flag {
+	case BlocklistFlagTor:
+		flags.IsTorExitNode = true
+	case BlocklistFlagVPN:
+		flags.IsAnonymousVPN = true
+	case BlocklistFlagProxy:
+		flags.IsPublicProxy = true
+	case BlocklistFlagHosting:
+		flags.IsHostingProvider = true
+		// hosting providers aren't anonymous on their own
+		return nil
+	case BlocklistFlagAnonymous:
+	default:
+		return fmt.Errorf("unknown flag %q", flag)
+	}
+
+	flags.IsAnonymous = true
+
+	return nil
+}
+
+// mergeBlocklistFlags sets the flags of from on to
+func mergeBlocklistFlags(to *utils.AnonymousIP, from utils.AnonymousIP) {
+	to.IsAnonymous = to.IsAnonymous || from.IsAnonymous
+	to.IsAnonymousVPN = to.IsAnonymousVPN || from.IsAnonymousVPN
+	to.IsHostingProvider = to.IsHostingProvider || from.IsHostingProvider
+	to.IsPublicProxy = to.IsPublicProxy || from.IsPublicProxy
+	to.IsTorExitNode = to.IsTorExitNode || from.IsTorExitNode
+}
+
+// Refresh downloads the lists with a URL and reloads all of them. Lists that
+// fail keep their previous copy, if any
+func (bp *BlocklistProvider) Refresh(ctx context.Context) error {
+	log.Info().Msg("refreshing Blocklist Provider")
+
+	sources, err := blocklistSources()
+	if err != nil {
+		return err
+	}
+
+	bp.RLock()
+	previous := bp.lists
+	bp.RUnlock()
+
+	var errs []error
+	keep := false
+	addrs := map[netip.Addr]utils.AnonymousIP{}
+	table := utils.NewPrefixTable[*blocklistEntry]()
+	lists := make([]*DatabaseInfo, 0, len(sources))
+	for _, source := range sources {
+		if source.URL != "" {
+			if err := bp.download(ctx, source); err != nil {
+				errs = append(errs, fmt.Errorf("failed to download blocklist %s: %w", source.Name, err))
+			}
+		}
+
+		list := &DatabaseInfo{
+			Name: source.Name,
+			Path: source.File,
+		}
+		lists = append(lists, list)
+
+		stat, err := os.Stat(source.File)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load blocklist %s: %w", source.Name, err))
+			continue
+		}
+
+		prefixes, err := readBlocklist(source.File)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load blocklist %s: %w", source.Name, err))
+			continue
+		}
+		if emptied(previous, source.Name, len(prefixes)) {
+			errs = append(errs, fmt.Errorf("blocklist %s is empty, keeping the previous lists", source.Name))
+			keep = true
+			continue
+		}
+
+		var flags utils.AnonymousIP
+		setBlocklistFlag(&flags, source.Flag)
+		for _, prefix := range prefixes {
+			if prefix.IsSingleIP() {
+				listed := addrs[prefix.Addr()]
+				mergeBlocklistFlags(&listed, flags)
+				addrs[prefix.Addr()] = listed
+				continue
+			}
+
+			entry, ok := table.Get(prefix)
+			if !ok {
+				entry = &blocklistEntry{specific: true}
+				table.Insert(prefix, entry)
+			}
+			mergeBlocklistFlags(&entry.flags, flags)
+		}
+
+		list.Loaded = true
+		list.DatabaseType = source.Flag
+		list.BuildTime = stat.ModTime().UTC()
+		list.Entries = len(prefixes)
+		utils.RecordDatabaseLoad("blocklist", source.Name, uint(stat.ModTime().Unix()))
+		log.Info().Str("list", source.Name).Int("entries", len(prefixes)).Msg("loaded blocklist")
+	}
+
+	if keep {
+		return errors.Join(errs...)
+	}
+
+	// addresses and networks get the flags of the networks they're in, so
+	// the most specific match has them all
+	prefixes := tablePrefixes(table)
+	for _, prefix := range prefixes {
+		child, _ := table.Get(prefix)
+		for _, parent := range blocklistParents(table, prefix) {
+			mergeBlocklistFlags(&child.flags, parent.flags)
+			parent.specific = false
+		}
+	}
+	for addr, flags := range addrs {
+		prefix := netip.PrefixFrom(addr, addr.BitLen())
+		prefixes = append(prefixes, prefix)
+		for _, parent := range blocklistParents(table, prefix) {
+			mergeBlocklistFlags(&flags, parent.flags)
+			parent.specific = false
+		}
+		addrs[addr] = flags
+	}
+	starts := newPrefixStarts(prefixes)
+
+	bp.Lock()
+	bp.addrs = addrs
+	bp.table = table
+	bp.starts = starts
+	bp.lists = lists
+	bp.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// blocklistParents returns the networks of table containing prefix
+func blocklistParents(table *utils.PrefixTable[*blocklistEntry], prefix netip.Prefix) []*blocklistEntry {
+	var parents []*blocklistEntry
+	for bits := prefix.Bits() - 1; bits >= 0; bits-- {
+		parent, _ := prefix.Addr().Prefix(bits)
+		if entry, ok := table.Get(parent); ok {
+			parents = append(parents, entry)
+		}
+	}
+
+	return parents
+}
+
+func (bp *BlocklistProvider) download(ctx context.Context, source *BlocklistSource) error {
+	log.Info().Str("source", utils.RedactURL(source.URL)).Str("dest", source.File).Msg("downloading")
+
+	if err := os.MkdirAll(filepath.Dir(source.File), 0700); err != nil {
+		return err
+	}
+
+	return utils.DownloadFileWithProgress(ctx, source.URL, source.File)
+}
+
+// match returns the flags of address and the network they hold for, if any
+func (bp *BlocklistProvider) match(address string) (netip.Prefix, *blocklistEntry, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Prefix{}, nil, &utils.IpAddressError{}
+	}
+
+	bp.RLock()
+	defer bp.RUnlock()
+
+	if flags, ok := bp.addrs[addr]; ok {
+		return netip.PrefixFrom(addr, addr.BitLen()), &blocklistEntry{flags: flags, specific: true}, nil
+	}
+
+	prefix, entry, _ := bp.table.Lookup(addr)
+
+	return prefix, entry, nil
+}
+
+// Lookup returns the flags of address, or nil when it isn't listed
+func (bp *BlocklistProvider) Lookup(ctx context.Context, address string, asFallback bool) (*utils.IPInfo, error) {
+	prefix, entry, err := bp.match(address)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	flags := entry.flags
+	info := &utils.IPInfo{
+		Address:            address,
+		Source:             "blocklist",
+		IsFallback:         asFallback,
+		ASN:                &utils.ASN{},
+		Location:           &utils.Location{},
+		AnonymousIP:        &flags,
+		HasAnonymousIP:     true,
+		City:               &utils.City{},
+		Continent:          &utils.Continent{},
+		Country:            &utils.Country{},
+		Postal:             &utils.Postal{},
+		RegisteredCountry:  &utils.Country{},
+		RepresentedCountry: &utils.Country{},
+		Subdivisions:       []*utils.Subdivision{},
+		Traits:             &utils.Traits{},
+	}
+
+	if entry.specific {
+		info.Network = prefix.String()
+	}
+
+	return info, nil
+}
+
+// Patch returns a copy of info with the flags of its address added, or info
+// itself when the answer holds as it is
+func (bp *BlocklistProvider) Patch(ctx context.Context, info *utils.IPInfo) *utils.IPInfo {
+	if info == nil {
+		return nil
+	}
+
+	prefix, entry, err := bp.match(info.Address)
+	if err != nil {
+		return info
+	}
+
+	bp.RLock()
+	starts := bp.starts
+	bp.RUnlock()
+
+	if entry == nil {
+		network := patchedNetwork(info.Network, netip.Prefix{}, false, starts)
+		if network == info.Network {
+			return info
+		}
+
+		// neighbours of the address in its network are listed
+		patched := *info
+		patched.Network = network
+		return &patched
+	}
+
+	flags := entry.flags
+	if info.AnonymousIP != nil {
+		mergeBlocklistFlags(&flags, *info.AnonymousIP)
+	}
+
+	patched := *info
+	patched.AnonymousIP = &flags
+	patched.HasAnonymousIP = true
+	patched.Network = patchedNetwork(info.Network, prefix, entry.specific, starts)
+
+	return &patched
+}
+
+// Health reports the state of the lists. It is ready as soon as one of them
+// is loaded
+func (bp *BlocklistProvider) Health(ctx context.Context) *Health {
+	bp.RLock()
+	defer bp.RUnlock()
+
+	return databaseHealth(bp.lists)
+}
+
+func (bp *BlocklistProvider) Shutdown(ctx context.Context) {
+}
+
+// readBlocklist reads the addresses and networks of a plain text blocklist
+func readBlocklist(file string) ([]netip.Prefix, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readNetworks(f)
+}
@@ -0,0 +1,204 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+const testTorExits = `185.220.101.1
+185.220.101.2
+2001:db8::7
+`
+
+const testVpnList = `# known VPN exits
+198.51.100.0/24 ; example VPN
+203.0.113.0/24
+203.0.113.7/32
+not an address
+`
+
+type blocklistProviderTestSuite struct {
+	suite.Suite
+	dir      string
+	provider *BlocklistProvider
+}
+
+func (suite *blocklistProviderTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.dir, "tor.txt"), []byte(testTorExits), 0600))
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.dir, "vpn.txt"), []byte(testVpnList), 0600))
+
+	viper.Set("providers.blocklist.path", suite.dir)
+	viper.Set("providers.blocklist.lists", []map[string]string{
+		{"name": "tor", "flag": "tor", "file": filepath.Join(suite.dir, "tor.txt")},
+		{"name": "vpn", "flag": "vpn", "file": filepath.Join(suite.dir, "vpn.txt")},
+	})
+
+	var err error
+	suite.provider, err = NewBlocklistProvider(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.provider.Start(context.Background()))
+}
+
+func (suite *blocklistProviderTestSuite) lookup(address string) *utils.IPInfo {
+	info, err := suite.provider.Lookup(context.Background(), address, false)
+	suite.Require().NoError(err)
+
+	return info
+}
+
+func (suite *blocklistProviderTestSuite) TestLookup() {
+	info := suite.lookup("185.220.101.1")
+	suite.Require().NotNil(info)
+	suite.Equal("blocklist", info.Source)
+	suite.True(info.HasAnonymousIP)
+	suite.Equal(&utils.AnonymousIP{IsAnonymous: true, IsTorExitNode: true}, info.AnonymousIP)
+	suite.Equal("185.220.101.1/32", info.Network)
+
+	suite.True(suite.lookup("2001:db8::7").AnonymousIP.IsTorExitNode)
+
+	info = suite.lookup("198.51.100.20")
+	suite.Require().NotNil(info)
+	suite.Equal(&utils.AnonymousIP{IsAnonymous: true, IsAnonymousVPN: true}, info.AnonymousIP)
+	suite.Equal("198.51.100.0/24", info.Network)
+
+	// 203.0.113.7 is listed on its own inside the network
+	info = suite.lookup("203.0.113.1")
+	suite.Require().NotNil(info)
+	suite.Empty(info.Network)
+
+	suite.Nil(suite.lookup("185.220.101.3"))
+	suite.Nil(suite.lookup("1.1.1.1"))
+}
+
+func (suite *blocklistProviderTestSuite) TestPatch() {
+	ctx := context.Background()
+	answer := &utils.IPInfo{
+		Address:     "185.220.101.1",
+		Network:     "185.220.101.0/24",
+		Country:     &utils.Country{IsoCode: "DE"},
+		AnonymousIP: &utils.AnonymousIP{IsHostingProvider: true},
+	}
+
+	patched := suite.provider.Patch(ctx, answer)
+	suite.True(patched.HasAnonymousIP)
+	suite.Equal(&utils.AnonymousIP{IsAnonymous: true, IsHostingProvider: true, IsTorExitNode: true}, patched.AnonymousIP)
+	suite.Equal("DE", patched.Country.IsoCode)
+	suite.Equal("185.220.101.1/32", patched.Network)
+	// the answer patched may be shared
+	suite.False(answer.AnonymousIP.IsTorExitNode)
+
+	// other addresses of the network are listed
+	patched = suite.provider.Patch(ctx, &utils.IPInfo{Address: "185.220.101.3", Network: "185.220.101.0/24"})
+	suite.False(patched.HasAnonymousIP)
+	suite.Empty(patched.Network)
+
+	unlisted := &utils.IPInfo{Address: "1.1.1.1", Network: "1.1.1.0/24"}
+	suite.Same(unlisted, suite.provider.Patch(ctx, unlisted))
+}
+
+func (suite *blocklistProviderTestSuite) TestCascadePatch() {
+	ctx := context.Background()
+
+	maxmind := &mockProvider{}
+	maxmind.On("Lookup", mock.Anything, "185.220.101.2", false).Return(&utils.IPInfo{
+		Address:     "185.220.101.2",
+		Network:     "185.220.0.0/16",
+		Country:     &utils.Country{IsoCode: "DE"},
+		AnonymousIP: &utils.AnonymousIP{},
+	}, nil)
+
+	cascade, err := NewCascadeIPProvider(ctx, true, []IPProvider{maxmind}, []Patcher{suite.provider})
+	suite.Require().NoError(err)
+
+	// the answers are patched apart from the lookup so they can be cached
+	// without the patches
+	answer, err := cascade.Lookup(ctx, "185.220.101.2", false)
+	suite.Require().NoError(err)
+	suite.False(answer.AnonymousIP.IsTorExitNode)
+
+	info := cascade.Patch(ctx, answer)
+	suite.Equal("DE", info.Country.IsoCode)
+	suite.True(info.HasAnonymousIP)
+	suite.True(info.AnonymousIP.IsTorExitNode)
+	suite.Equal("185.220.101.2/32", info.Network)
+	suite.Equal("185.220.0.0/16", answer.Network)
+}
+
+func (suite *blocklistProviderTestSuite) TestDownload() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "torbulkexitlist", time.Time{}, strings.NewReader("192.0.2.9\n"))
+	}))
+	defer server.Close()
+
+	viper.Set("providers.blocklist.lists", []map[string]string{
+		{"name": "tor", "flag": "tor", "url": server.URL},
+		{"name": "vpn", "flag": "vpn", "file": filepath.Join(suite.dir, "vpn.txt")},
+		{"name": "broken", "flag": "proxy", "url": "http://127.0.0.1:1/proxies.txt"},
+	})
+
+	// a list failing doesn't keep the others from loading
+	suite.Error(suite.provider.Refresh(context.Background()))
+	suite.FileExists(filepath.Join(suite.dir, "tor.txt"))
+
+	suite.True(suite.lookup("192.0.2.9").AnonymousIP.IsTorExitNode)
+	suite.Nil(suite.lookup("185.220.101.1"))
+	suite.NotNil(suite.lookup("198.51.100.20"))
+
+	health := suite.provider.Health(context.Background())
+	suite.True(health.Ready)
+	suite.Len(health.Databases, 3)
+	suite.False(health.Databases[2].Loaded)
+}
+
+func (suite *blocklistProviderTestSuite) TestEmptiedList() {
+	status, body := http.StatusOK, ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", fmt.Sprintf(`"%d-%d"`, status, len(body)))
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	viper.Set("providers.blocklist.lists", []map[string]string{
+		{"name": "tor", "flag": "tor", "url": server.URL},
+	})
+
+	// a list that had entries and comes back empty keeps the previous ones
+	body = "192.0.2.9\n"
+	suite.Require().NoError(suite.provider.Refresh(context.Background()))
+	body = "# no exits\n"
+	suite.Error(suite.provider.Refresh(context.Background()))
+	suite.True(suite.lookup("192.0.2.9").AnonymousIP.IsTorExitNode)
+
+	// so does an error page
+	status, body = http.StatusServiceUnavailable, "192.0.2.10\n"
+	suite.Error(suite.provider.Refresh(context.Background()))
+	suite.True(suite.lookup("192.0.2.9").AnonymousIP.IsTorExitNode)
+	suite.Nil(suite.lookup("192.0.2.10"))
+}
+
+func (suite *blocklistProviderTestSuite) TestInvalidLists() {
+	viper.Set("providers.blocklist.lists", []map[string]string{{"name": "tor", "flag": "onion", "file": "tor.txt"}})
+	suite.Error(suite.provider.Refresh(context.Background()))
+
+	viper.Set("providers.blocklist.lists", []map[string]string{{"name": "tor", "flag": "tor"}})
+	suite.Error(suite.provider.Refresh(context.Background()))
+}
+
+func TestBlocklistProviderTestSuite(t *testing.T) {
+	suite.Run(t, new(blocklistProviderTestSuite))
+}
@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker is a circuit breaker for remote providers. It opens after threshold
+// consecutive failures and lets a single trial call through once cooldown
+// has passed. A threshold of 0 disables it
+type breaker struct {
+	sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	trial     bool
+	now       func() time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// allow reports whether a call can go through
+func (b *breaker) allow() bool {
+	b.Lock()
+	defer b.Unlock()
+
+	switch b.stateLocked() {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+
+	return true
+}
+
+// record counts the outcome of a call let through by allow
+func (b *breaker) record(err error) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.trial = false
+	if err == nil {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
+
+// cancel gives back a call let through by allow that wasn't made
+func (b *breaker) cancel() {
+	b.Lock()
+	defer b.Unlock()
+
+	b.trial = false
+}
+
+func (b *breaker) state() string {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.stateLocked()
+}
+
+func (b *breaker) stateLocked() string {
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return BreakerClosed
+	}
+
+	if b.now().Sub(b.openedAt) < b.cooldown {
+		return BreakerOpen
+	}
+
+	return BreakerHalfOpen
+}
@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type breakerTestSuite struct {
+	suite.Suite
+	now     time.Time
+	breaker *breaker
+}
+
+func (suite *breakerTestSuite) SetupTest() {
+	suite.now = time.Unix(1700000000, 0)
+	suite.breaker = newBreaker(2, time.Minute)
+	suite.breaker.now = func() time.Time { return suite.now }
+}
+
+func (suite *breakerTestSuite) TestOpensAfterThreshold() {
+	suite.breaker.record(errors.New("failed"))
+	suite.Assert().Equal(BreakerClosed, suite.breaker.state())
+	suite.Assert().True(suite.breaker.allow())
+
+	suite.breaker.record(errors.New("failed"))
+	suite.Assert().Equal(BreakerOpen, suite.breaker.state())
+	suite.Assert().False(suite.breaker.allow())
+}
+
+func (suite *breakerTestSuite) TestSuccessResets() {
+	suite.breaker.record(errors.New("failed"))
+	suite.breaker.record(nil)
+	suite.breaker.record(errors.New("failed"))
+
+	suite.Assert().Equal(BreakerClosed, suite.breaker.state())
+}
+
+func (suite *breakerTestSuite) TestHalfOpenAllowsSingleTrial() {
+	suite.breaker.record(errors.New("failed"))
+	suite.breaker.record(errors.New("failed"))
+
+	suite.now = suite.now.Add(2 * time.Minute)
+	suite.Assert().Equal(BreakerHalfOpen, suite.breaker.state())
+	suite.Assert().True(suite.breaker.allow())
+	suite.Assert().False(suite.breaker.allow())
+
+	// a failed trial opens it again for another cooldown
+	suite.breaker.record(errors.New("failed"))
+	suite.Assert().Equal(BreakerOpen, suite.breaker.state())
+
+	suite.now = suite.now.Add(2 * time.Minute)
+	suite.Assert().True(suite.breaker.allow())
+	suite.breaker.record(nil)
+	suite.Assert().Equal(BreakerClosed, suite.breaker.state())
+}
+
+func (suite *breakerTestSuite) TestDisabled() {
+	suite.breaker.threshold = 0
+	for i := 0; i < 5; i++ {
+		suite.breaker.record(errors.New("failed"))
+	}
+
+	suite.Assert().Equal(BreakerClosed, suite.breaker.state())
+	suite.Assert().True(suite.breaker.allow())
+}
+
+func TestBreakerTestSuite(t *testing.T) {
+	suite.Run(t, new(breakerTestSuite))
+}
@@ -0,0 +1,252 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// BudgetStatus is the call budget of a remote provider as reported in Health
+type BudgetStatus struct {
+	Daily        int  `json:"daily"`
+	DailyLimit   int  `json:"daily_limit,omitempty"`
+	Monthly      int  `json:"monthly"`
+	MonthlyLimit int  `json:"monthly_limit,omitempty"`
+	Exhausted    bool `json:"exhausted"`
+}
+
+// budgetState is the part of a budget persisted in its state file
+type budgetState struct {
+	Day           string    `json:"day"`
+	Daily         int       `json:"daily"`
+	Month         string    `json:"month"`
+	Monthly       int       `json:"monthly"`
+	WarnedDaily   []float64 `json:"warned_daily,omitempty"`
+	WarnedMonthly []float64 `json:"warned_monthly,omitempty"`
+}
+
+// budget limits the number of calls made to a paid remote provider per UTC
+// day and month. The counts are kept in a state file so they survive restarts.
+// The file is saved every save_interval, when a warning threshold is reached,
+// when the budget runs out and on close rather than on every call
+type budget struct {
+	sync.Mutex
+	// saveLock orders the writes of the state file
+	saveLock     sync.Mutex
+	provider     string
+	dailyLimit   int
+	monthlyLimit int
+	warnAt       []float64
+	stateFile    string
+	state        budgetState
+	dirty        bool
+	stop         chan struct{}
+	now          func() time.Time
+}
+
+// newBudget returns the budget configured under providers.<provider>.budget.
+// A limit of 0 is unlimited
+func newBudget(provider string) (*budget, error) {
+	b := &budget{
+		provider:     provider,
+		dailyLimit:   viper.GetInt(fmt.Sprintf("providers.%s.budget.daily", provider)),
+		monthlyLimit: viper.GetInt(fmt.Sprintf("providers.%s.budget.monthly", provider)),
+		stateFile:    viper.GetString(fmt.Sprintf("providers.%s.budget.state_file", provider)),
+		stop:         make(chan struct{}),
+		now:          time.Now,
+	}
+
+	for _, threshold := range viper.GetStringSlice(fmt.Sprintf("providers.%s.budget.warn_at", provider)) {
+		var value float64
+		if _, err := fmt.Sscanf(threshold, "%g", &value); err != nil {
+			return nil, fmt.Errorf("invalid budget warning threshold %s", threshold)
+		}
+		b.warnAt = append(b.warnAt, value)
+	}
+	slices.Sort(b.warnAt)
+
+	if b.stateFile != "" && utils.FileExists(b.stateFile) {
+		data, err := os.ReadFile(b.stateFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(data, &b.state); err != nil {
+			return nil, fmt.Errorf("invalid budget state file %s: %w", b.stateFile, err)
+		}
+	}
+
+	if interval := viper.GetDuration(fmt.Sprintf("providers.%s.budget.save_interval", provider)); b.stateFile != "" && interval > 0 {
+		go b.saveEvery(interval)
+	}
+
+	return b, nil
+}
+
+// reserve counts a call against the budget. It returns false, without
+// counting it, when the budget is exhausted
+func (b *budget) reserve() bool {
+	b.Lock()
+
+	b.rollLocked()
+	if b.exhaustedLocked() {
+		b.Unlock()
+		return false
+	}
+
+	warned := len(b.state.WarnedDaily) + len(b.state.WarnedMonthly)
+	b.state.Daily++
+	b.state.Monthly++
+	b.state.WarnedDaily = b.warnLocked("daily", b.state.Daily, b.dailyLimit, b.state.WarnedDaily)
+	b.state.WarnedMonthly = b.warnLocked("monthly", b.state.Monthly, b.monthlyLimit, b.state.WarnedMonthly)
+	b.dirty = true
+
+	utils.ProviderBudgetUsed.WithLabelValues(b.provider, "daily").Set(float64(b.state.Daily))
+	utils.ProviderBudgetUsed.WithLabelValues(b.provider, "monthly").Set(float64(b.state.Monthly))
+
+	// thresholds and the end of the budget are saved right away so a restart
+	// doesn't warn again or allow calls over the limit
+	flush := warned != len(b.state.WarnedDaily)+len(b.state.WarnedMonthly) || b.exhaustedLocked()
+	b.Unlock()
+
+	if flush {
+		b.save()
+	}
+
+	return true
+}
+
+func (b *budget) status() *BudgetStatus {
+	b.Lock()
+	defer b.Unlock()
+
+	b.rollLocked()
+
+	return &BudgetStatus{
+		Daily:        b.state.Daily,
+		DailyLimit:   b.dailyLimit,
+		Monthly:      b.state.Monthly,
+		MonthlyLimit: b.monthlyLimit,
+		Exhausted:    b.exhaustedLocked(),
+	}
+}
+
+// rollLocked resets the counts when a new day or month starts
+func (b *budget) rollLocked() {
+	now := b.now().UTC()
+
+	if day := now.Format(time.DateOnly); b.state.Day != day {
+		b.state.Day = day
+		b.state.Daily = 0
+		b.state.WarnedDaily = nil
+	}
+
+	if month := now.Format("2006-01"); b.state.Month != month {
+		b.state.Month = month
+		b.state.Monthly = 0
+		b.state.WarnedMonthly = nil
+	}
+}
+
+func (b *budget) exhaustedLocked() bool {
+	return (b.dailyLimit > 0 && b.state.Daily >= b.dailyLimit) ||
+		(b.monthlyLimit > 0 && b.state.Monthly >= b.monthlyLimit)
+}
+
+// warnLocked logs the thresholds used crosses for the first time in the
+// period and returns the thresholds warned about so far
+func (b *budget) warnLocked(period string, used int, limit int, warned []float64) []float64 {
+	if limit <= 0 {
+		return warned
+	}
+
+	for _, threshold := range b.warnAt {
+		if float64(used) < threshold*float64(limit) || slices.Contains(warned, threshold) {
+			continue
+		}
+
+		warned = append(warned, threshold)
+		log.Warn().
+			Str("provider", b.provider).
+			Str("period", period).
+			Int("used", used).
+			Int("limit", limit).
+			Float64("threshold", threshold).
+			Msg("provider call budget threshold reached")
+	}
+
+	return warned
+}
+
+func (b *budget) saveEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.save()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// close stops the periodic saves and saves the counts made since the last one
+func (b *budget) close() {
+	close(b.stop)
+	b.save()
+}
+
+// save writes the state file if the counts changed since it was last saved
+func (b *budget) save() {
+	if b.stateFile == "" {
+		return
+	}
+
+	b.saveLock.Lock()
+	defer b.saveLock.Unlock()
+
+	b.Lock()
+	if !b.dirty {
+		b.Unlock()
+		return
+	}
+	data, err := json.Marshal(b.state)
+	b.dirty = false
+	b.Unlock()
+
+	if err == nil {
+		err = b.write(data)
+	}
+
+	if err != nil {
+		b.Lock()
+		b.dirty = true
+		b.Unlock()
+		log.Error().Err(err).Str("provider", b.provider).Msg("failed to save budget state")
+	}
+}
+
+// write writes the state file through a temporary file so a crash doesn't
+// leave it truncated
+func (b *budget) write(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(b.stateFile), 0700); err != nil {
+		return err
+	}
+
+	tmpPath := b.stateFile + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, b.stateFile)
+}
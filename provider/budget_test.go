@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type budgetTestSuite struct {
+	suite.Suite
+	now       time.Time
+	stateFile string
+}
+
+func (suite *budgetTestSuite) SetupTest() {
+	suite.now = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	suite.stateFile = filepath.Join(suite.T().TempDir(), "budget.json")
+
+	viper.Set("providers.test.budget.daily", 3)
+	viper.Set("providers.test.budget.monthly", 5)
+	viper.Set("providers.test.budget.state_file", suite.stateFile)
+	viper.Set("providers.test.budget.warn_at", []string{"0.5", "0.8"})
+}
+
+func (suite *budgetTestSuite) newBudget() *budget {
+	b, err := newBudget("test")
+	suite.Require().NoError(err)
+	b.now = func() time.Time { return suite.now }
+
+	return b
+}
+
+func (suite *budgetTestSuite) TestDailyLimit() {
+	b := suite.newBudget()
+	for i := 0; i < 3; i++ {
+		suite.True(b.reserve())
+	}
+
+	suite.False(b.reserve())
+	suite.True(b.status().Exhausted)
+	suite.Equal(3, b.status().Daily)
+
+	// a new day resets the daily count but not the monthly one
+	suite.now = suite.now.Add(24 * time.Hour)
+	suite.False(b.status().Exhausted)
+	suite.True(b.reserve())
+	suite.True(b.reserve())
+	suite.False(b.reserve())
+	suite.Equal(5, b.status().Monthly)
+
+	// a new month resets both
+	suite.now = time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	suite.True(b.reserve())
+	suite.Equal(1, b.status().Monthly)
+}
+
+func (suite *budgetTestSuite) TestPersisted() {
+	b := suite.newBudget()
+	suite.True(b.reserve())
+	suite.NoFileExists(suite.stateFile)
+
+	// reaching a warning threshold saves the counts right away
+	suite.True(b.reserve())
+	suite.FileExists(suite.stateFile)
+	b.close()
+
+	restarted := suite.newBudget()
+	suite.Equal(2, restarted.status().Daily)
+	suite.Equal(2, restarted.status().Monthly)
+
+	suite.True(restarted.reserve())
+	suite.False(restarted.reserve())
+}
+
+func (suite *budgetTestSuite) TestSavedOnClose() {
+	viper.Set("providers.test.budget.warn_at", nil)
+
+	b := suite.newBudget()
+	suite.True(b.reserve())
+	suite.NoFileExists(suite.stateFile)
+	b.close()
+
+	suite.Equal(1, suite.newBudget().status().Daily)
+}
+
+func (suite *budgetTestSuite) TestSavedEveryInterval() {
+	viper.Set("providers.test.budget.warn_at", nil)
+	viper.Set("providers.test.budget.save_interval", 10*time.Millisecond)
+	defer viper.Set("providers.test.budget.save_interval", 0)
+
+	b := suite.newBudget()
+	defer b.close()
+	suite.True(b.reserve())
+
+	suite.Eventually(func() bool {
+		_, err := os.Stat(suite.stateFile)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (suite *budgetTestSuite) TestWarnings() {
+	b := suite.newBudget()
+	b.reserve()
+	suite.Empty(b.state.WarnedDaily)
+
+	b.reserve()
+	suite.Equal([]float64{0.5}, b.state.WarnedDaily)
+
+	b.reserve()
+	suite.Equal([]float64{0.5, 0.8}, b.state.WarnedDaily)
+	suite.Equal([]float64{0.5}, b.state.WarnedMonthly)
+}
+
+func (suite *budgetTestSuite) TestUnlimited() {
+	viper.Set("providers.test.budget.daily", 0)
+	viper.Set("providers.test.budget.monthly", 0)
+
+	b := suite.newBudget()
+	for i := 0; i < 10; i++ {
+		suite.True(b.reserve())
+	}
+	suite.False(b.status().Exhausted)
+}
+
+func TestBudgetTestSuite(t *testing.T) {
+	suite.Run(t, new(budgetTestSuite))
+}
@@ -2,20 +2,26 @@ package provider
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/cloud66-oss/geo/utils"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// CascadeIPProvider is a IPProvider that will try to lookup an IP address in multiple providers
+// CascadeIPProvider is a IPProvider that will try to lookup an IP address in multiple providers.
+// Its answers can then be patched by each of its patches in turn with Patch
 type CascadeIPProvider struct {
 	providers    []IPProvider
+	patches      []Patcher
 	stopAtErrors bool
 }
 
-func NewCascadeIPProvider(ctx context.Context, stopAtErrors bool, providers []IPProvider) (*CascadeIPProvider, error) {
+func NewCascadeIPProvider(ctx context.Context, stopAtErrors bool, providers []IPProvider, patches []Patcher) (*CascadeIPProvider, error) {
 	return &CascadeIPProvider{
 		providers:    providers,
+		patches:      patches,
 		stopAtErrors: stopAtErrors,
 	}, nil
 }
@@ -28,7 +34,17 @@ func (dpi *CascadeIPProvider) Start(ctx context.Context) error {
 
 func (dpi *CascadeIPProvider) Lookup(ctx context.Context, address string, asFallback bool) (*utils.IPInfo, error) {
 	for idx, provider := range dpi.providers {
-		ip, err := provider.Lookup(ctx, address, idx != 0)
+		memberCtx, span := utils.Tracer().Start(ctx, "cascade.Lookup", trace.WithAttributes(
+			attribute.Int("geo.cascade.index", idx),
+			attribute.String("geo.cascade.provider", fmt.Sprintf("%T", provider)),
+		))
+		ip, err := provider.Lookup(memberCtx, address, idx != 0)
+		utils.EndSpan(span, err)
+		if _, ok := err.(*utils.ProviderUnavailableError); ok {
+			log.Debug().Err(err).Msg("provider unavailable, moving on to next provider")
+			continue
+		}
+
 		if err != nil {
 			if dpi.stopAtErrors {
 				return nil, err
@@ -38,6 +54,14 @@ func (dpi *CascadeIPProvider) Lookup(ctx context.Context, address string, asFall
 			}
 		}
 
+		if ip != nil && idx > 0 && ip.Network != "" {
+			// the providers before it may know about other addresses of the
+			// network, so the answer only holds for the address
+			fallback := *ip
+			fallback.Network = ""
+			ip = &fallback
+		}
+
 		if ip != nil {
 			return ip, nil
 		}
@@ -47,6 +71,30 @@ func (dpi *CascadeIPProvider) Lookup(ctx context.Context, address string, asFall
 	return nil, nil
 }
 
+// Patch applies the patches of the cascade on top of ip. It is kept apart
+// from Lookup so the answers can be cached without the patches, whose
+// databases usually change far more often
+func (dpi *CascadeIPProvider) Patch(ctx context.Context, ip *utils.IPInfo) *utils.IPInfo {
+	for _, patch := range dpi.patches {
+		ip = patch.Patch(ctx, ip)
+	}
+
+	return ip
+}
+
+// Health reports the cascade as ready when any of its providers is
+func (dpi *CascadeIPProvider) Health(ctx context.Context) *Health {
+	health := &Health{}
+	for _, provider := range dpi.providers {
+		if healthOf(ctx, provider).Ready {
+			health.Ready = true
+			break
+		}
+	}
+
+	return health
+}
+
 func (dpi *CascadeIPProvider) Shutdown(ctx context.Context) {
 	// these should be already shutdown
 }
@@ -58,5 +106,13 @@ func (dpi *CascadeIPProvider) Refresh(ctx context.Context) error {
 		}
 	}
 
+	for _, patch := range dpi.patches {
+		if provider, ok := patch.(IPProvider); ok {
+			if err := provider.Refresh(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
@@ -55,11 +55,11 @@ func (suite *cascadeIpProviderTestSuite) TestCascadeFlowFirstReturn() {
 	ctx := context.Background()
 
 	p1 := &mockProvider{}
-	p1.On("Lookup", ctx, "1.1.1.1", false).Return(&utils.IPInfo{Address: "1.1.1.1"}, nil)
+	p1.On("Lookup", mock.Anything, "1.1.1.1", false).Return(&utils.IPInfo{Address: "1.1.1.1"}, nil)
 	p2 := &mockProvider{}
 	p2.AssertNotCalled(suite.T(), "Lookup", mock.Anything)
 
-	provider, err := NewCascadeIPProvider(ctx, true, []IPProvider{p1, p2})
+	provider, err := NewCascadeIPProvider(ctx, true, []IPProvider{p1, p2}, nil)
 	suite.NoError(err)
 	info, err := provider.Lookup(ctx, "1.1.1.1", false)
 	suite.NoError(err)
@@ -74,11 +74,11 @@ func (suite *cascadeIpProviderTestSuite) TestCascadeFlowSecondReturn() {
 	ctx := context.Background()
 
 	p1 := &mockProvider{}
-	p1.On("Lookup", ctx, "1.1.1.1", false).Return(nil, nil)
+	p1.On("Lookup", mock.Anything, "1.1.1.1", false).Return(nil, nil)
 	p2 := &mockProvider{}
-	p2.On("Lookup", ctx, "1.1.1.1", true).Return(&utils.IPInfo{Address: "2.2.2.2"}, nil)
+	p2.On("Lookup", mock.Anything, "1.1.1.1", true).Return(&utils.IPInfo{Address: "2.2.2.2"}, nil)
 
-	provider, err := NewCascadeIPProvider(ctx, true, []IPProvider{p1, p2})
+	provider, err := NewCascadeIPProvider(ctx, true, []IPProvider{p1, p2}, nil)
 	suite.NoError(err)
 	info, err := provider.Lookup(ctx, "1.1.1.1", false)
 	suite.NoError(err)
@@ -93,11 +93,11 @@ func (suite *cascadeIpProviderTestSuite) TestCascadeFlowErrorReturn() {
 	ctx := context.Background()
 
 	p1 := &mockProvider{}
-	p1.On("Lookup", ctx, "1.1.1.1", false).Return(nil, errors.New("something broke"))
+	p1.On("Lookup", mock.Anything, "1.1.1.1", false).Return(nil, errors.New("something broke"))
 	p2 := &mockProvider{}
 	p2.AssertNotCalled(suite.T(), mock.Anything)
 
-	provider, err := NewCascadeIPProvider(ctx, true, []IPProvider{p1, p2})
+	provider, err := NewCascadeIPProvider(ctx, true, []IPProvider{p1, p2}, nil)
 	suite.NoError(err)
 	_, err = provider.Lookup(ctx, "1.1.1.1", false)
 
@@ -107,6 +107,48 @@ func (suite *cascadeIpProviderTestSuite) TestCascadeFlowErrorReturn() {
 	suite.Assert().Error(err, "something broken")
 }
 
+func (suite *cascadeIpProviderTestSuite) TestCascadeFlowSkipsUnavailable() {
+	ctx := context.Background()
+
+	p1 := &mockProvider{}
+	p1.On("Lookup", mock.Anything, "1.1.1.1", false).Return(nil, &utils.ProviderUnavailableError{Provider: "ipstack"})
+	p2 := &mockProvider{}
+	p2.On("Lookup", mock.Anything, "1.1.1.1", true).Return(&utils.IPInfo{Address: "1.1.1.1"}, nil)
+
+	// unavailable providers are skipped even when stopping at errors
+	provider, err := NewCascadeIPProvider(ctx, true, []IPProvider{p1, p2}, nil)
+	suite.NoError(err)
+	info, err := provider.Lookup(ctx, "1.1.1.1", false)
+	suite.NoError(err)
+
+	p1.AssertExpectations(suite.T())
+	p2.AssertExpectations(suite.T())
+
+	suite.EqualValues("1.1.1.1", info.Address)
+}
+
+func (suite *cascadeIpProviderTestSuite) TestCascadeFallbackNetwork() {
+	ctx := context.Background()
+
+	p1 := &mockProvider{}
+	p1.On("Lookup", mock.Anything, "1.1.1.1", false).Return(&utils.IPInfo{Address: "1.1.1.1", Network: "1.1.1.0/24"}, nil)
+	p1.On("Lookup", mock.Anything, "2.2.2.2", false).Return(nil, nil)
+	p2 := &mockProvider{}
+	p2.On("Lookup", mock.Anything, "2.2.2.2", true).Return(&utils.IPInfo{Address: "2.2.2.2", Network: "2.2.0.0/16"}, nil)
+
+	provider, err := NewCascadeIPProvider(ctx, true, []IPProvider{p1, p2}, nil)
+	suite.NoError(err)
+
+	info, err := provider.Lookup(ctx, "1.1.1.1", false)
+	suite.NoError(err)
+	suite.Equal("1.1.1.0/24", info.Network)
+
+	// the first provider may know other addresses of the network
+	info, err = provider.Lookup(ctx, "2.2.2.2", false)
+	suite.NoError(err)
+	suite.Empty(info.Network)
+}
+
 func TestCascadeIpProviderTestSuite(t *testing.T) {
 	suite.Run(t, new(cascadeIpProviderTestSuite))
 }
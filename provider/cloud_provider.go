@@ -0,0 +1,477 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+
+	"github.com/cloud66-oss/geo/utils"
+)
+
+// Formats of the published cloud range files
+const (
+	CloudFormatAWS    = "aws"
+	CloudFormatGCP    = "gcp"
+	CloudFormatAzure  = "azure"
+	CloudFormatOracle = "oracle"
+	CloudFormatFastly = "fastly"
+	// CloudFormatText is one address or network per line, as Cloudflare
+	// publishes them
+	CloudFormatText = "text"
+)
+
+// CloudSource is a range file configured under providers.cloud.sources.
+// Sources with a URL are downloaded to their file
+type CloudSource struct {
+	Name   string `mapstructure:"name"`
+	Format string `mapstructure:"format"`
+	// Cloud is reported as the cloud of the ranges. It defaults to the
+	// format, or the name for text files
+	Cloud string `mapstructure:"cloud"`
+	URL   string `mapstructure:"url"`
+	File  string `mapstructure:"file"`
+}
+
+// CloudRange is a network published by a cloud
+type CloudRange struct {
+	Prefix  netip.Prefix
+	Service string
+	Region  string
+}
+
+// cloudEntry is a range in the lookup table. specific is set when no other
+// range is more specific than it
+type cloudEntry struct {
+	cloud    *utils.Cloud
+	specific bool
+}
+
+// cloudGenericServices are the services covering all the others of a cloud.
+// A range listed under one of them and a more precise service is reported
+// with the precise one
+var cloudGenericServices = map[string]bool{
+	"":           true,
+	"AMAZON":     true,
+	"AzureCloud": true,
+}
+
+// CloudProvider answers for the networks published by cloud and CDN
+// providers, with the cloud, service and region of the address
+type CloudProvider struct {
+	sync.RWMutex
+	table   *utils.PrefixTable[*cloudEntry]
+	sources []*DatabaseInfo
+}
+
+func NewCloudProvider(ctx context.Context) (*CloudProvider, error) {
+	return &CloudProvider{
+		table: utils.NewPrefixTable[*cloudEntry](),
+	}, nil
+}
+
+func (cp *CloudProvider) Start(ctx context.Context) error {
+	log.Info().Msg("starting Cloud Provider")
+
+	// a source that can't be fetched shouldn't keep the others from serving
+	err := cp.Refresh(ctx)
+	if err != nil && cp.Health(ctx).Ready {
+		return &utils.LoadError{Err: err}
+	}
+
+	return err
+}
+
+// cloudSources returns the configured sources, with the file of downloaded
+// sources defaulting to providers.cloud.path
+func cloudSources() ([]*CloudSource, error) {
+	var sources []*CloudSource
+	if err := viper.UnmarshalKey("providers.cloud.sources", &sources); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, source := range sources {
+		if source.Name == "" {
+			return nil, errors.New("cloud range sources need a name")
+		}
+		if names[source.Name] {
+			return nil, fmt.Errorf("cloud range source %s is defined more than once", source.Name)
+		}
+		names[source.Name] = true
+
+		switch source.Format {
+		case CloudFormatAWS, CloudFormatGCP, CloudFormatAzure, CloudFormatOracle, CloudFormatFastly:
+			if source.Cloud == "" {
+				source.Cloud = source.Format
+			}
+		case CloudFormatText:
+			if source.Cloud == "" {
+				source.Cloud = source.Name
+			}
+		default:
+			return nil, fmt.Errorf("unknown format %q for cloud range source %s", source.Format, source.Name)
+		}
+
+		if source.File == "" {
+			if source.URL == "" {
+				return nil, fmt.Errorf("cloud range source %s needs a url or a file", source.Name)
+			}
+			source.File = filepath.Join(viper.GetString("providers.cloud.path"), source.Name)
+		}
+	}
+
+	return sources, nil
+}
+
+// Refresh downloads the sources with a URL and reloads all of them. Sources
+// that fail keep their previous copy, if any
+func (cp *CloudProvider) Refresh(ctx context.Context) error {
+	log.Info().Msg("refreshing Cloud Provider")
+
+	sources, err := cloudSources()
+	if err != nil {
+		return err
+	}
+
+	cp.RLock()
+	previous := cp.sources
+	cp.RUnlock()
+
+	var errs []error
+	keep := false
+	table := utils.NewPrefixTable[*cloudEntry]()
+	infos := make([]*DatabaseInfo, 0, len(sources))
+	for _, source := range sources {
+		if source.URL != "" {
+			if err := cp.download(ctx, source); err != nil {
+				errs = append(errs, fmt.Errorf("failed to download cloud ranges %s: %w", source.Name, err))
+			}
+		}
+
+		info := &DatabaseInfo{
+			Name: source.Name,
+			Path: source.File,
+		}
+		infos = append(infos, info)
+
+		stat, err := os.Stat(source.File)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load cloud ranges %s: %w", source.Name, err))
+			continue
+		}
+
+		ranges, err := ReadCloudRanges(source.File, source.Format)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load cloud ranges %s: %w", source.Name, err))
+			continue
+		}
+		if emptied(previous, source.Name, len(ranges)) {
+			errs = append(errs, fmt.Errorf("cloud ranges %s are empty, keeping the previous ranges", source.Name))
+			keep = true
+			continue
+		}
+
+		for _, r := range ranges {
+			existing, ok := table.Get(r.Prefix)
+			if ok && (existing.cloud.Provider != source.Cloud || !cloudGenericServices[existing.cloud.Service]) {
+				continue
+			}
+
+			table.Insert(r.Prefix, &cloudEntry{
+				cloud: &utils.Cloud{
+					Provider: source.Cloud,
+					Service:  r.Service,
+					Region:   r.Region,
+				},
+				specific: true,
+			})
+		}
+
+		info.Loaded = true
+		info.DatabaseType = source.Format
+		info.BuildTime = stat.ModTime().UTC()
+		info.Entries = len(ranges)
+		utils.RecordDatabaseLoad("cloud", source.Name, uint(stat.ModTime().Unix()))
+		log.Info().Str("source", source.Name).Int("ranges", len(ranges)).Msg("loaded cloud ranges")
+	}
+
+	if keep {
+		return errors.Join(errs...)
+	}
+
+	for prefix := range nestedPrefixes(table) {
+		entry, _ := table.Get(prefix)
+		entry.specific = false
+	}
+
+	cp.Lock()
+	cp.table = table
+	cp.sources = infos
+	cp.Unlock()
+
+	return errors.Join(errs...)
+}
+
+func (cp *CloudProvider) download(ctx context.Context, source *CloudSource) error {
+	log.Info().Str("source", utils.RedactURL(source.URL)).Str("dest", source.File).Msg("downloading")
+
+	if err := os.MkdirAll(filepath.Dir(source.File), 0700); err != nil {
+		return err
+	}
+
+	return utils.DownloadFileWithProgress(ctx, source.URL, source.File)
+}
+
+// Lookup returns the cloud of the most specific published network containing
+// address, or nil when no cloud publishes it
+func (cp *CloudProvider) Lookup(ctx context.Context, address string, asFallback bool) (*utils.IPInfo, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return nil, &utils.IpAddressError{}
+	}
+
+	cp.RLock()
+	prefix, entry, ok := cp.table.Lookup(addr)
+	cp.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+
+	cloud := *entry.cloud
+	info := &utils.IPInfo{
+		Address:            address,
+		Source:             "cloud",
+		IsFallback:         asFallback,
+		ASN:                &utils.ASN{},
+		Location:           &utils.Location{},
+		AnonymousIP:        &utils.AnonymousIP{IsHostingProvider: true},
+		HasAnonymousIP:     true,
+		City:               &utils.City{},
+		Continent:          &utils.Continent{},
+		Country:            &utils.Country{},
+		Postal:             &utils.Postal{},
+		RegisteredCountry:  &utils.Country{},
+		RepresentedCountry: &utils.Country{},
+		Subdivisions:       []*utils.Subdivision{},
+		Traits:             &utils.Traits{},
+		Cloud:              &cloud,
+	}
+
+	if entry.specific {
+		info.Network = prefix.String()
+	}
+
+	return info, nil
+}
+
+// Health reports the state of the range files. It is ready as soon as one of
+// them is loaded
+func (cp *CloudProvider) Health(ctx context.Context) *Health {
+	cp.RLock()
+	defer cp.RUnlock()
+
+	return databaseHealth(cp.sources)
+}
+
+func (cp *CloudProvider) Shutdown(ctx context.Context) {
+}
+
+// ReadCloudRanges reads the ranges of file, published in format
+func ReadCloudRanges(file string, format string) ([]*CloudRange, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch format {
+	case CloudFormatAWS:
+		return readAwsRanges(f)
+	case CloudFormatGCP:
+		return readGcpRanges(f)
+	case CloudFormatAzure:
+		return readAzureRanges(f)
+	case CloudFormatOracle:
+		return readOracleRanges(f)
+	case CloudFormatFastly:
+		return readFastlyRanges(f)
+	case CloudFormatText:
+		return readTextRanges(f)
+	default:
+		return nil, fmt.Errorf("unknown cloud range format %q", format)
+	}
+}
+
+// appendCloudRange adds the range of network to ranges, skipping networks
+// that can't be parsed
+func appendCloudRange(ranges []*CloudRange, network string, service string, region string) []*CloudRange {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(network))
+	if err != nil {
+		log.Debug().Str("network", network).Msg("skipping invalid cloud range")
+		return ranges
+	}
+
+	return append(ranges, &CloudRange{
+		Prefix:  prefix.Masked(),
+		Service: service,
+		Region:  region,
+	})
+}
+
+// readAwsRanges reads AWS ip-ranges.json
+func readAwsRanges(r io.Reader) ([]*CloudRange, error) {
+	var document struct {
+		Prefixes []struct {
+			IPPrefix string `json:"ip_prefix"`
+			Region   string `json:"region"`
+			Service  string `json:"service"`
+		} `json:"prefixes"`
+		IPv6Prefixes []struct {
+			IPv6Prefix string `json:"ipv6_prefix"`
+			Region     string `json:"region"`
+			Service    string `json:"service"`
+		} `json:"ipv6_prefixes"`
+	}
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	var ranges []*CloudRange
+	for _, prefix := range document.Prefixes {
+		ranges = appendCloudRange(ranges, prefix.IPPrefix, prefix.Service, prefix.Region)
+	}
+	for _, prefix := range document.IPv6Prefixes {
+		ranges = appendCloudRange(ranges, prefix.IPv6Prefix, prefix.Service, prefix.Region)
+	}
+
+	return ranges, nil
+}
+
+// readGcpRanges reads Google Cloud cloud.json
+func readGcpRanges(r io.Reader) ([]*CloudRange, error) {
+	var document struct {
+		Prefixes []struct {
+			IPv4Prefix string `json:"ipv4Prefix"`
+			IPv6Prefix string `json:"ipv6Prefix"`
+			Service    string `json:"service"`
+			Scope      string `json:"scope"`
+		} `json:"prefixes"`
+	}
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	var ranges []*CloudRange
+	for _, prefix := range document.Prefixes {
+		network := prefix.IPv4Prefix
+		if network == "" {
+			network = prefix.IPv6Prefix
+		}
+		ranges = appendCloudRange(ranges, network, prefix.Service, prefix.Scope)
+	}
+
+	return ranges, nil
+}
+
+// readAzureRanges reads the Azure service tags file. Only regional tags are
+// read, as the global ones repeat their ranges without a region
+func readAzureRanges(r io.Reader) ([]*CloudRange, error) {
+	var document struct {
+		Values []struct {
+			Name       string `json:"name"`
+			Properties struct {
+				Region          string   `json:"region"`
+				SystemService   string   `json:"systemService"`
+				AddressPrefixes []string `json:"addressPrefixes"`
+			} `json:"properties"`
+		} `json:"values"`
+	}
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	var ranges []*CloudRange
+	for _, value := range document.Values {
+		if value.Properties.Region == "" {
+			continue
+		}
+
+		service := value.Properties.SystemService
+		if service == "" {
+			service, _, _ = strings.Cut(value.Name, ".")
+		}
+		for _, network := range value.Properties.AddressPrefixes {
+			ranges = appendCloudRange(ranges, network, service, value.Properties.Region)
+		}
+	}
+
+	return ranges, nil
+}
+
+// readOracleRanges reads Oracle Cloud public_ip_ranges.json
+func readOracleRanges(r io.Reader) ([]*CloudRange, error) {
+	var document struct {
+		Regions []struct {
+			Region string `json:"region"`
+			Cidrs  []struct {
+				Cidr string   `json:"cidr"`
+				Tags []string `json:"tags"`
+			} `json:"cidrs"`
+		} `json:"regions"`
+	}
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	var ranges []*CloudRange
+	for _, region := range document.Regions {
+		for _, cidr := range region.Cidrs {
+			ranges = appendCloudRange(ranges, cidr.Cidr, strings.Join(cidr.Tags, ","), region.Region)
+		}
+	}
+
+	return ranges, nil
+}
+
+// readFastlyRanges reads the Fastly public IP list
+func readFastlyRanges(r io.Reader) ([]*CloudRange, error) {
+	var document struct {
+		Addresses     []string `json:"addresses"`
+		IPv6Addresses []string `json:"ipv6_addresses"`
+	}
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	var ranges []*CloudRange
+	for _, network := range append(document.Addresses, document.IPv6Addresses...) {
+		ranges = appendCloudRange(ranges, network, "", "")
+	}
+
+	return ranges, nil
+}
+
+// readTextRanges reads a network or address per line
+func readTextRanges(r io.Reader) ([]*CloudRange, error) {
+	prefixes, err := readNetworks(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := make([]*CloudRange, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		ranges = append(ranges, &CloudRange{Prefix: prefix})
+	}
+
+	return ranges, nil
+}
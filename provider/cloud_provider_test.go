@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+var testCloudRanges = map[string]string{
+	"aws.json": `{
+  "prefixes": [
+    {"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "AMAZON"},
+    {"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "S3"},
+    {"ip_prefix": "52.94.0.0/16", "region": "us-east-1", "service": "EC2"},
+    {"ip_prefix": "52.94.0.0/16", "region": "us-east-1", "service": "AMAZON"},
+    {"ip_prefix": "not a prefix", "region": "us-east-1", "service": "EC2"}
+  ],
+  "ipv6_prefixes": [
+    {"ipv6_prefix": "2600:1f14::/35", "region": "us-west-2", "service": "EC2"}
+  ]
+}`,
+	"gcp.json": `{
+  "prefixes": [
+    {"ipv4Prefix": "34.80.0.0/15", "service": "Google Cloud", "scope": "asia-east1"},
+    {"ipv6Prefix": "2600:1900:4000::/44", "service": "Google Cloud", "scope": "europe-west1"}
+  ]
+}`,
+	"azure.json": `{
+  "values": [
+    {"name": "AzureCloud", "properties": {"region": "", "addressPrefixes": ["20.0.0.0/8"]}},
+    {"name": "AzureCloud.westeurope", "properties": {"region": "westeurope", "systemService": "", "addressPrefixes": ["20.50.0.0/16"]}},
+    {"name": "Storage.WestEurope", "properties": {"region": "westeurope", "systemService": "AzureStorage", "addressPrefixes": ["20.50.0.0/16"]}}
+  ]
+}`,
+	"oracle.json": `{
+  "regions": [
+    {"region": "uk-london-1", "cidrs": [{"cidr": "132.145.0.0/16", "tags": ["OCI", "OSN"]}]}
+  ]
+}`,
+	"fastly.json":    `{"addresses": ["151.101.0.0/16"], "ipv6_addresses": ["2a04:4e40::/32"]}`,
+	"cloudflare.txt": "# Cloudflare\n104.16.0.0/13\n\n198.51.100.7 # single address\n",
+}
+
+type cloudProviderTestSuite struct {
+	suite.Suite
+	dir      string
+	provider *CloudProvider
+}
+
+func (suite *cloudProviderTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+	for name, content := range testCloudRanges {
+		suite.Require().NoError(os.WriteFile(filepath.Join(suite.dir, name), []byte(content), 0600))
+	}
+
+	viper.Set("providers.cloud.path", suite.dir)
+	viper.Set("providers.cloud.sources", []map[string]string{
+		{"name": "aws", "format": "aws", "file": filepath.Join(suite.dir, "aws.json")},
+		{"name": "gcp", "format": "gcp", "file": filepath.Join(suite.dir, "gcp.json")},
+		{"name": "azure", "format": "azure", "file": filepath.Join(suite.dir, "azure.json")},
+		{"name": "oracle", "format": "oracle", "file": filepath.Join(suite.dir, "oracle.json")},
+		{"name": "fastly", "format": "fastly", "file": filepath.Join(suite.dir, "fastly.json")},
+		{"name": "cloudflare", "format": "text", "file": filepath.Join(suite.dir, "cloudflare.txt")},
+	})
+
+	var err error
+	suite.provider, err = NewCloudProvider(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.provider.Start(context.Background()))
+}
+
+func (suite *cloudProviderTestSuite) lookup(address string) *utils.IPInfo {
+	info, err := suite.provider.Lookup(context.Background(), address, false)
+	suite.Require().NoError(err)
+
+	return info
+}
+
+func (suite *cloudProviderTestSuite) TestLookup() {
+	info := suite.lookup("52.94.1.1")
+	suite.Require().NotNil(info)
+	suite.Equal("cloud", info.Source)
+	suite.True(info.HasAnonymousIP)
+	suite.True(info.AnonymousIP.IsHostingProvider)
+	suite.Equal(&utils.Cloud{Provider: "aws", Service: "EC2", Region: "us-east-1"}, info.Cloud)
+	suite.Equal("52.94.0.0/16", info.Network)
+
+	// the precise service wins over AMAZON whatever the order
+	suite.Equal("S3", suite.lookup("3.5.140.1").Cloud.Service)
+	suite.Equal("us-west-2", suite.lookup("2600:1f14::1").Cloud.Region)
+
+	info = suite.lookup("34.81.0.1")
+	suite.Require().NotNil(info)
+	suite.Equal(&utils.Cloud{Provider: "gcp", Service: "Google Cloud", Region: "asia-east1"}, info.Cloud)
+
+	info = suite.lookup("132.145.1.1")
+	suite.Require().NotNil(info)
+	suite.Equal(&utils.Cloud{Provider: "oracle", Service: "OCI,OSN", Region: "uk-london-1"}, info.Cloud)
+
+	suite.Equal("fastly", suite.lookup("2a04:4e40::1").Cloud.Provider)
+	suite.Equal("cloudflare", suite.lookup("104.17.0.1").Cloud.Provider)
+
+	info = suite.lookup("198.51.100.7")
+	suite.Require().NotNil(info)
+	suite.Equal("198.51.100.7/32", info.Network)
+
+	suite.Nil(suite.lookup("198.51.100.8"))
+	suite.Nil(suite.lookup("1.1.1.1"))
+}
+
+func (suite *cloudProviderTestSuite) TestAzure() {
+	info := suite.lookup("20.50.1.1")
+	suite.Require().NotNil(info)
+	suite.Equal(&utils.Cloud{Provider: "azure", Service: "AzureStorage", Region: "westeurope"}, info.Cloud)
+
+	// the global AzureCloud tag has no region and isn't read
+	suite.Nil(suite.lookup("20.1.1.1"))
+}
+
+func (suite *cloudProviderTestSuite) TestDownload() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "ips-v4", time.Time{}, strings.NewReader("203.0.113.0/24\n"))
+	}))
+	defer server.Close()
+
+	viper.Set("providers.cloud.sources", []map[string]string{
+		{"name": "aws", "format": "aws", "file": filepath.Join(suite.dir, "aws.json")},
+		{"name": "remote", "format": "text", "cloud": "example", "url": server.URL},
+		{"name": "broken", "format": "text", "url": "http://127.0.0.1:1/ips-v4"},
+	})
+
+	// a source failing doesn't keep the others from loading
+	suite.Error(suite.provider.Refresh(context.Background()))
+	suite.FileExists(filepath.Join(suite.dir, "remote"))
+
+	info := suite.lookup("203.0.113.1")
+	suite.Require().NotNil(info)
+	suite.Equal("example", info.Cloud.Provider)
+	suite.NotNil(suite.lookup("52.94.1.1"))
+	suite.Nil(suite.lookup("104.17.0.1"))
+
+	health := suite.provider.Health(context.Background())
+	suite.True(health.Ready)
+	suite.Len(health.Databases, 3)
+	suite.False(health.Databases[2].Loaded)
+}
+
+func (suite *cloudProviderTestSuite) TestEmptiedSource() {
+	body := "203.0.113.0/24\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, len(body)))
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	viper.Set("providers.cloud.sources", []map[string]string{{"name": "remote", "format": "text", "cloud": "example", "url": server.URL}})
+	suite.Require().NoError(suite.provider.Refresh(context.Background()))
+
+	// a source that had ranges and comes back without any keeps the previous ones
+	body = "\n"
+	suite.Error(suite.provider.Refresh(context.Background()))
+	suite.Equal("example", suite.lookup("203.0.113.1").Cloud.Provider)
+}
+
+func (suite *cloudProviderTestSuite) TestInvalidSources() {
+	viper.Set("providers.cloud.sources", []map[string]string{{"name": "aws", "format": "csv", "file": "aws.csv"}})
+	suite.Error(suite.provider.Refresh(context.Background()))
+
+	viper.Set("providers.cloud.sources", []map[string]string{{"name": "aws", "format": "aws"}})
+	suite.Error(suite.provider.Refresh(context.Background()))
+}
+
+func TestCloudProviderTestSuite(t *testing.T) {
+	suite.Run(t, new(cloudProviderTestSuite))
+}
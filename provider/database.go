@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"slices"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/spf13/viper"
+)
+
+// DatabaseInfo describes a database used by a provider
+type DatabaseInfo struct {
+	Name         string    `json:"name"`
+	Path         string    `json:"path"`
+	Loaded       bool      `json:"loaded"`
+	DatabaseType string    `json:"database_type,omitempty"`
+	BuildTime    time.Time `json:"build_time,omitzero"`
+	// Entries is the number of entries of lists, feeds and ranges
+	Entries int `json:"entries,omitempty"`
+}
+
+// emptied is whether the source name had entries when it was last loaded
+// but has none now, which is more likely a bad download than an empty list
+func emptied(previous []*DatabaseInfo, name string, entries int) bool {
+	if entries > 0 {
+		return false
+	}
+
+	index := slices.IndexFunc(previous, func(info *DatabaseInfo) bool { return info.Name == name })
+
+	return index >= 0 && previous[index].Entries > 0
+}
+
+// recordMmdbLoad publishes the metrics of a freshly loaded database
+func recordMmdbLoad(provider string, database string, db *maxminddb.Reader) {
+	if db == nil {
+		return
+	}
+
+	utils.RecordDatabaseLoad(provider, database, db.Metadata.BuildEpoch)
+}
+
+// mmdbDatabaseInfo describes the database configured under
+// providers.<provider>.db.<database>. It returns nil when no path is configured
+func mmdbDatabaseInfo(provider string, database string, db *maxminddb.Reader) *DatabaseInfo {
+	var metadata *maxminddb.Metadata
+	if db != nil {
+		metadata = &db.Metadata
+	}
+
+	return databaseInfo(provider, database, metadata)
+}
+
+func databaseInfo(provider string, database string, metadata *maxminddb.Metadata) *DatabaseInfo {
+	path := viper.GetString("providers." + provider + ".db." + database)
+	if path == "" {
+		return nil
+	}
+
+	return metadataDatabaseInfo(database, path, metadata)
+}
+
+// metadataDatabaseInfo describes the mmdb database at path, loaded when
+// metadata is set
+func metadataDatabaseInfo(database string, path string, metadata *maxminddb.Metadata) *DatabaseInfo {
+	info := &DatabaseInfo{
+		Name: database,
+		Path: path,
+	}
+
+	if metadata != nil {
+		info.Loaded = true
+		info.DatabaseType = metadata.DatabaseType
+		info.BuildTime = time.Unix(int64(metadata.BuildEpoch), 0).UTC()
+	}
+
+	return info
+}
+
+// appendDatabaseInfo adds the configured databases in infos to databases
+func appendDatabaseInfo(databases []*DatabaseInfo, infos ...*DatabaseInfo) []*DatabaseInfo {
+	for _, info := range infos {
+		if info != nil {
+			databases = append(databases, info)
+		}
+	}
+
+	return databases
+}
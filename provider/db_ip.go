@@ -10,22 +10,23 @@ import (
 	"github.com/cloud66-oss/geo/utils"
 	"github.com/jinzhu/copier"
 	"github.com/oschwald/geoip2-golang"
+	"github.com/oschwald/maxminddb-golang"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 )
 
 // DbIpProvider is a provider that uses DbIP databases
 type DbIpProvider struct {
-	cityDb    *geoip2.Reader
-	countryDb *geoip2.Reader
-	asnDb     *geoip2.Reader
+	cityDb    *maxminddb.Reader
+	countryDb *maxminddb.Reader
+	asnDb     *maxminddb.Reader
 }
 
 func NewDbIpProvider(ctx context.Context) (*DbIpProvider, error) {
 	return &DbIpProvider{}, nil
 }
 
-func readDbIp(_ context.Context, file string) (*geoip2.Reader, error) {
+func readDbIp(_ context.Context, file string) (*maxminddb.Reader, error) {
 	if file == "" {
 		return nil, nil
 	}
@@ -34,7 +35,7 @@ func readDbIp(_ context.Context, file string) (*geoip2.Reader, error) {
 		return nil, fmt.Errorf("file not found %s", file)
 	}
 
-	db, err := geoip2.Open(file)
+	db, err := maxminddb.Open(file)
 	if err != nil {
 		return nil, err
 	}
@@ -49,8 +50,7 @@ func (mmp *DbIpProvider) Start(ctx context.Context) error {
 		log.Warn().Msg("DbIP Provider download is disabled, attempting to load existing databases")
 		err := mmp.loadDatabases(ctx)
 		if err != nil {
-			log.Warn().Err(err).Msg("failed to load DbIP databases")
-			return nil
+			return &utils.LoadError{Err: err}
 		}
 		log.Info().Msg("DbIP Provider loaded existing databases successfully")
 		return nil
@@ -59,7 +59,7 @@ func (mmp *DbIpProvider) Start(ctx context.Context) error {
 	return mmp.Refresh(ctx)
 }
 
-func (mmp *DbIpProvider) downloadDb(_ context.Context, dbName string) error {
+func (mmp *DbIpProvider) downloadDb(ctx context.Context, dbName string) error {
 	fileURL := viper.GetString(fmt.Sprintf("providers.dbip.download.%s", dbName))
 	if fileURL == "" {
 		log.Warn().Msg("DbIP Provider fileURL is empty")
@@ -79,7 +79,7 @@ func (mmp *DbIpProvider) downloadDb(_ context.Context, dbName string) error {
 		return err
 	}
 
-	return utils.DownloadFileWithProgress(fileURL, filePath)
+	return utils.DownloadFileWithProgress(ctx, fileURL, filePath)
 }
 
 func (mmp *DbIpProvider) Lookup(ctx context.Context, address string, asFallback bool) (*utils.IPInfo, error) {
@@ -104,29 +104,30 @@ func (mmp *DbIpProvider) Lookup(ctx context.Context, address string, asFallback
 		Subdivisions:       []*utils.Subdivision{},
 		Traits:             &utils.Traits{},
 	}
+	match := newNetworkMatch(ip)
 
 	// query the ASN database if available
 	if mmp.asnDb != nil {
-		asn, err := mmp.asnDb.ASN(ip)
+		asn, network, _, err := lookupAsnDb(mmp.asnDb, ip)
 		if err != nil {
 			return nil, err
 		}
 
-		err = copier.Copy(&info.ASN, &asn)
-		if err != nil {
-			return nil, err
-		}
+		info.ASN = asn
 		info.HasASN = true
+		match.add(network)
 	} else {
 		info.HasASN = false
 	}
 
 	if mmp.cityDb != nil {
 		// city db includes country data as well
-		city, err := mmp.cityDb.City(ip)
+		var city geoip2.City
+		network, _, err := mmp.cityDb.LookupNetwork(ip, &city)
 		if err != nil {
 			return nil, err
 		}
+		match.add(network)
 
 		err = copier.Copy(&info, &city)
 		if err != nil {
@@ -136,10 +137,12 @@ func (mmp *DbIpProvider) Lookup(ctx context.Context, address string, asFallback
 		info.HasCity = true
 	} else if mmp.countryDb != nil {
 		// fall back to country-only db when no city db is available
-		country, err := mmp.countryDb.Country(ip)
+		var country geoip2.Country
+		network, _, err := mmp.countryDb.LookupNetwork(ip, &country)
 		if err != nil {
 			return nil, err
 		}
+		match.add(network)
 
 		err = copier.Copy(&info, &country)
 		if err != nil {
@@ -151,9 +154,25 @@ func (mmp *DbIpProvider) Lookup(ctx context.Context, address string, asFallback
 
 	info.HasAnonymousIP = false
 
+	info.Network = match.String()
+
 	return info, nil
 }
 
+// LookupASN returns the ASN of address and the network it was matched in
+func (mmp *DbIpProvider) LookupASN(ctx context.Context, address string) (*utils.ASN, *net.IPNet, error) {
+	return lookupAsn(mmp.asnDb, address)
+}
+
+// Health reports the state of the DbIP databases
+func (mmp *DbIpProvider) Health(ctx context.Context) *Health {
+	return databaseHealth(appendDatabaseInfo(nil,
+		mmdbDatabaseInfo("dbip", "city", mmp.cityDb),
+		mmdbDatabaseInfo("dbip", "country", mmp.countryDb),
+		mmdbDatabaseInfo("dbip", "asn", mmp.asnDb),
+	))
+}
+
 func (mmp *DbIpProvider) Shutdown(ctx context.Context) {
 	if mmp.cityDb != nil {
 		mmp.cityDb.Close()
@@ -191,6 +210,7 @@ func (mmp *DbIpProvider) loadDatabases(ctx context.Context) error {
 		return err
 	}
 	mmp.cityDb = db
+	recordMmdbLoad("dbip", "city", db)
 
 	// load the country database
 	db, err = readDbIp(ctx, viper.GetString("providers.dbip.db.country"))
@@ -198,13 +218,15 @@ func (mmp *DbIpProvider) loadDatabases(ctx context.Context) error {
 		return err
 	}
 	mmp.countryDb = db
+	recordMmdbLoad("dbip", "country", db)
 
 	// load the ASN database
-	db, err = readDbIp(ctx, viper.GetString("providers.dbip.db.asn"))
+	asnDb, err := readAsnDb(ctx, viper.GetString("providers.dbip.db.asn"))
 	if err != nil {
 		return err
 	}
-	mmp.asnDb = db
+	mmp.asnDb = asnDb
+	recordMmdbLoad("dbip", "asn", asnDb)
 
 	return nil
 }
@@ -0,0 +1,358 @@
+package provider
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+
+	"github.com/cloud66-oss/geo/utils"
+)
+
+// geofeedRegion matches an ISO 3166-2 subdivision code such as US-CA
+var geofeedRegion = regexp.MustCompile(`^([A-Z]{2})-[A-Z0-9]{1,3}$`)
+
+// GeofeedSource is a geofeed configured under providers.geofeed.feeds. Feeds
+// with a URL are downloaded to their file
+type GeofeedSource struct {
+	Name string `mapstructure:"name"`
+	URL  string `mapstructure:"url"`
+	File string `mapstructure:"file"`
+}
+
+// GeofeedEntry is a row of an RFC 8805 geofeed
+type GeofeedEntry struct {
+	Prefix  netip.Prefix
+	Country string
+	// Region is the ISO 3166-2 code of the subdivision, such as US-CA
+	Region string
+	City   string
+	Postal string
+}
+
+// geofeedEntry is a geofeed row in the lookup table. specific is set when no
+// other row is more specific than it
+type geofeedEntry struct {
+	entry    *GeofeedEntry
+	specific bool
+}
+
+// GeofeedProvider answers from RFC 8805 self-published geofeeds, the CSV
+// files in which network operators publish the location of their prefixes
+type GeofeedProvider struct {
+	sync.RWMutex
+	table *utils.PrefixTable[*geofeedEntry]
+	feeds []*DatabaseInfo
+}
+
+func NewGeofeedProvider(ctx context.Context) (*GeofeedProvider, error) {
+	return &GeofeedProvider{
+		table: utils.NewPrefixTable[*geofeedEntry](),
+	}, nil
+}
+
+func (gp *GeofeedProvider) Start(ctx context.Context) error {
+	log.Info().Msg("starting Geofeed Provider")
+
+	// a feed that can't be fetched shouldn't keep the others from serving
+	err := gp.Refresh(ctx)
+	if err != nil && gp.Health(ctx).Ready {
+		return &utils.LoadError{Err: err}
+	}
+
+	return err
+}
+
+// geofeedSources returns the configured feeds, with the file of downloaded
+// feeds defaulting to providers.geofeed.path
+func geofeedSources() ([]*GeofeedSource, error) {
+	var sources []*GeofeedSource
+	if err := viper.UnmarshalKey("providers.geofeed.feeds", &sources); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, source := range sources {
+		if source.Name == "" {
+			return nil, errors.New("geofeeds need a name")
+		}
+		if names[source.Name] {
+			return nil, fmt.Errorf("geofeed %s is defined more than once", source.Name)
+		}
+		names[source.Name] = true
+
+		if source.File == "" {
+			if source.URL == "" {
+				return nil, fmt.Errorf("geofeed %s needs a url or a file", source.Name)
+			}
+			source.File = filepath.Join(viper.GetString("providers.geofeed.path"), source.Name+".csv")
+		}
+	}
+
+	return sources, nil
+}
+
+// Refresh downloads the feeds with a URL and reloads all of them. Feeds that
+// fail keep their previous copy, if any
+func (gp *GeofeedProvider) Refresh(ctx context.Context) error {
+	log.Info().Msg("refreshing Geofeed Provider")
+
+	sources, err := geofeedSources()
+	if err != nil {
+		return err
+	}
+
+	gp.RLock()
+	previous := gp.feeds
+	gp.RUnlock()
+
+	var errs []error
+	keep := false
+	table := utils.NewPrefixTable[*geofeedEntry]()
+	feeds := make([]*DatabaseInfo, 0, len(sources))
+	for _, source := range sources {
+		if source.URL != "" {
+			if err := gp.download(ctx, source); err != nil {
+				errs = append(errs, fmt.Errorf("failed to download geofeed %s: %w", source.Name, err))
+			}
+		}
+
+		feed := &DatabaseInfo{
+			Name: source.Name,
+			Path: source.File,
+		}
+		feeds = append(feeds, feed)
+
+		stat, err := os.Stat(source.File)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load geofeed %s: %w", source.Name, err))
+			continue
+		}
+
+		entries, rejected, err := ReadGeofeed(source.File)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load geofeed %s: %w", source.Name, err))
+			continue
+		}
+		if emptied(previous, source.Name, len(entries)) {
+			errs = append(errs, fmt.Errorf("geofeed %s is empty, keeping the previous feeds", source.Name))
+			keep = true
+			continue
+		}
+
+		// feeds listed first win for the prefixes they share
+		for _, entry := range entries {
+			if _, ok := table.Get(entry.Prefix); ok {
+				log.Debug().Str("feed", source.Name).Str("prefix", entry.Prefix.String()).Msg("prefix already in an earlier geofeed, skipping")
+				continue
+			}
+			table.Insert(entry.Prefix, &geofeedEntry{entry: entry, specific: true})
+		}
+
+		feed.Loaded = true
+		feed.DatabaseType = "geofeed"
+		feed.BuildTime = stat.ModTime().UTC()
+		feed.Entries = len(entries)
+		utils.RecordDatabaseLoad("geofeed", source.Name, uint(stat.ModTime().Unix()))
+
+		logger := log.Info()
+		if rejected > 0 {
+			logger = log.Warn()
+		}
+		logger.Str("feed", source.Name).Int("entries", len(entries)).Int("rejected", rejected).Msg("loaded geofeed")
+	}
+
+	if keep {
+		return errors.Join(errs...)
+	}
+
+	for prefix := range nestedPrefixes(table) {
+		entry, _ := table.Get(prefix)
+		entry.specific = false
+	}
+
+	gp.Lock()
+	gp.table = table
+	gp.feeds = feeds
+	gp.Unlock()
+
+	return errors.Join(errs...)
+}
+
+func (gp *GeofeedProvider) download(ctx context.Context, source *GeofeedSource) error {
+	log.Info().Str("source", utils.RedactURL(source.URL)).Str("dest", source.File).Msg("downloading")
+
+	if err := os.MkdirAll(filepath.Dir(source.File), 0700); err != nil {
+		return err
+	}
+
+	return utils.DownloadFileWithProgress(ctx, source.URL, source.File)
+}
+
+// Lookup returns the geofeed row of the most specific prefix containing
+// address. Rows without a country tell the prefix isn't geolocated, so
+// they're not found
+func (gp *GeofeedProvider) Lookup(ctx context.Context, address string, asFallback bool) (*utils.IPInfo, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return nil, &utils.IpAddressError{}
+	}
+
+	gp.RLock()
+	prefix, match, ok := gp.table.Lookup(addr)
+	gp.RUnlock()
+	if !ok || match.entry.Country == "" {
+		return nil, nil
+	}
+
+	entry := match.entry
+	info := &utils.IPInfo{
+		Address:            address,
+		Source:             "geofeed",
+		IsFallback:         asFallback,
+		ASN:                &utils.ASN{},
+		Location:           &utils.Location{},
+		AnonymousIP:        &utils.AnonymousIP{},
+		City:               &utils.City{},
+		Continent:          &utils.Continent{},
+		Country:            &utils.Country{IsoCode: entry.Country},
+		Postal:             &utils.Postal{Code: entry.Postal},
+		RegisteredCountry:  &utils.Country{},
+		RepresentedCountry: &utils.Country{},
+		Subdivisions:       []*utils.Subdivision{},
+		Traits:             &utils.Traits{},
+	}
+
+	if entry.Region != "" {
+		// subdivision codes are reported without their country, as GeoIP2 does
+		_, code, _ := strings.Cut(entry.Region, "-")
+		info.Subdivisions = append(info.Subdivisions, &utils.Subdivision{IsoCode: code})
+	}
+
+	if entry.City != "" {
+		info.City.Names = map[string]string{"en": entry.City}
+		info.HasCity = true
+	}
+
+	if match.specific {
+		info.Network = prefix.String()
+	}
+
+	return info, nil
+}
+
+// Health reports the state of the geofeeds. It is ready as soon as one of
+// them is loaded
+func (gp *GeofeedProvider) Health(ctx context.Context) *Health {
+	gp.RLock()
+	defer gp.RUnlock()
+
+	return databaseHealth(gp.feeds)
+}
+
+func (gp *GeofeedProvider) Shutdown(ctx context.Context) {
+}
+
+// ReadGeofeed reads the RFC 8805 geofeed in file. Rows that don't follow the
+// RFC are skipped and counted in rejected
+func ReadGeofeed(file string) (entries []*GeofeedEntry, rejected int, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	reader.ReuseRecord = true
+
+	seen := map[netip.Prefix]bool{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		entry, err := parseGeofeedRow(record)
+		if err == nil && seen[entry.Prefix] {
+			err = fmt.Errorf("duplicate prefix %s", entry.Prefix)
+		}
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			log.Debug().Err(err).Str("file", file).Int("line", line).Msg("skipping invalid geofeed row")
+			rejected++
+			continue
+		}
+
+		seen[entry.Prefix] = true
+		entries = append(entries, entry)
+	}
+
+	return entries, rejected, nil
+}
+
+// parseGeofeedRow validates a row of prefix, country, region, city and
+// postal code as laid out in RFC 8805
+func parseGeofeedRow(record []string) (*GeofeedEntry, error) {
+	fields := make([]string, 5)
+	for i := 0; i < len(record) && i < len(fields); i++ {
+		fields[i] = strings.TrimSpace(record[i])
+	}
+
+	prefix, err := netip.ParsePrefix(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefix %q", fields[0])
+	}
+	if prefix != prefix.Masked() {
+		return nil, fmt.Errorf("prefix %s has host bits set", prefix)
+	}
+
+	entry := &GeofeedEntry{
+		Prefix:  prefix,
+		Country: strings.ToUpper(fields[1]),
+		Region:  strings.ToUpper(fields[2]),
+		City:    fields[3],
+		Postal:  fields[4],
+	}
+
+	if entry.Country != "" && (len(entry.Country) != 2 || !isAsciiLetters(entry.Country)) {
+		return nil, fmt.Errorf("invalid country %q", fields[1])
+	}
+
+	if entry.Region != "" {
+		match := geofeedRegion.FindStringSubmatch(entry.Region)
+		if match == nil {
+			return nil, fmt.Errorf("invalid region %q", fields[2])
+		}
+		if match[1] != entry.Country {
+			return nil, fmt.Errorf("region %s isn't in country %q", entry.Region, entry.Country)
+		}
+	}
+
+	return entry, nil
+}
+
+func isAsciiLetters(value string) bool {
+	for _, r := range value {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
+	}
+
+	return true
+}
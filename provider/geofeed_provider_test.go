@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+const testGeofeed = `# prefix,country,region,city,postal
+192.0.2.0/24,US,US-CA,San Francisco,94107
+192.0.2.128/25,US,US-NY,New York,
+2001:db8::/32,gb,gb-eng,London,
+198.51.100.0/24,,,,
+# invalid rows
+198.51.100.1/24,US,,,
+203.0.113.0/24,USA,,,
+203.0.113.0/25,US,CA-ON,,
+not a prefix,US,,,
+192.0.2.0/24,FR,,,
+`
+
+type geofeedProviderTestSuite struct {
+	suite.Suite
+	dir      string
+	provider *GeofeedProvider
+}
+
+func (suite *geofeedProviderTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+	file := filepath.Join(suite.dir, "isp.csv")
+	suite.Require().NoError(os.WriteFile(file, []byte(testGeofeed), 0600))
+
+	viper.Set("providers.geofeed.path", suite.dir)
+	viper.Set("providers.geofeed.feeds", []map[string]string{{"name": "isp", "file": file}})
+
+	var err error
+	suite.provider, err = NewGeofeedProvider(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.provider.Start(context.Background()))
+}
+
+func (suite *geofeedProviderTestSuite) lookup(address string) *utils.IPInfo {
+	info, err := suite.provider.Lookup(context.Background(), address, false)
+	suite.Require().NoError(err)
+
+	return info
+}
+
+func (suite *geofeedProviderTestSuite) TestLookup() {
+	info := suite.lookup("192.0.2.1")
+	suite.Require().NotNil(info)
+	suite.Equal("geofeed", info.Source)
+	suite.Equal("US", info.Country.IsoCode)
+	suite.Equal("CA", info.Subdivisions[0].IsoCode)
+	suite.Equal("San Francisco", info.City.Names["en"])
+	suite.Equal("94107", info.Postal.Code)
+	// 192.0.2.128/25 is more specific so the /24 doesn't hold for all of it
+	suite.Empty(info.Network)
+
+	info = suite.lookup("192.0.2.200")
+	suite.Require().NotNil(info)
+	suite.Equal("New York", info.City.Names["en"])
+	suite.Equal("192.0.2.128/25", info.Network)
+
+	info = suite.lookup("2001:db8::1")
+	suite.Require().NotNil(info)
+	suite.Equal("GB", info.Country.IsoCode)
+	suite.Equal("ENG", info.Subdivisions[0].IsoCode)
+
+	// prefixes without a country aren't geolocated
+	suite.Nil(suite.lookup("198.51.100.1"))
+	suite.Nil(suite.lookup("1.1.1.1"))
+}
+
+func (suite *geofeedProviderTestSuite) TestValidation() {
+	entries, rejected, err := ReadGeofeed(filepath.Join(suite.dir, "isp.csv"))
+	suite.Require().NoError(err)
+	suite.Len(entries, 4)
+	suite.Equal(5, rejected)
+
+	suite.Nil(suite.lookup("203.0.113.1"))
+}
+
+func (suite *geofeedProviderTestSuite) TestDownload() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "geofeed.csv", time.Time{}, strings.NewReader("203.0.113.0/24,JP,JP-13,Tokyo,\n"))
+	}))
+	defer server.Close()
+
+	viper.Set("providers.geofeed.feeds", []map[string]string{
+		{"name": "isp", "file": filepath.Join(suite.dir, "isp.csv")},
+		{"name": "remote", "url": server.URL},
+		{"name": "broken", "url": "http://127.0.0.1:1/geofeed.csv"},
+	})
+
+	// a feed failing doesn't keep the others from loading
+	suite.Error(suite.provider.Refresh(context.Background()))
+	suite.FileExists(filepath.Join(suite.dir, "remote.csv"))
+
+	info := suite.lookup("203.0.113.1")
+	suite.Require().NotNil(info)
+	suite.Equal("Tokyo", info.City.Names["en"])
+	suite.NotNil(suite.lookup("192.0.2.1"))
+
+	health := suite.provider.Health(context.Background())
+	suite.True(health.Ready)
+	suite.Len(health.Databases, 3)
+	suite.False(health.Databases[2].Loaded)
+}
+
+func (suite *geofeedProviderTestSuite) TestEmptiedFeed() {
+	body := "203.0.113.0/24,JP,JP-13,Tokyo,\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, len(body)))
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	viper.Set("providers.geofeed.feeds", []map[string]string{{"name": "remote", "url": server.URL}})
+	suite.Require().NoError(suite.provider.Refresh(context.Background()))
+
+	// a feed that had entries and comes back without any keeps the previous ones
+	body = "<html>maintenance</html>\n"
+	suite.Error(suite.provider.Refresh(context.Background()))
+	suite.Equal("Tokyo", suite.lookup("203.0.113.1").City.Names["en"])
+}
+
+func TestGeofeedProviderTestSuite(t *testing.T) {
+	suite.Run(t, new(geofeedProviderTestSuite))
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/jinzhu/copier"
 	"github.com/oschwald/geoip2-golang"
+	"github.com/oschwald/maxminddb-golang"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 
@@ -17,16 +18,16 @@ import (
 
 // GlobioProvider is a provider that uses Globio databases (country, ASN, and optional anonymous IP)
 type GlobioProvider struct {
-	countryDb   *geoip2.Reader
-	asnDb       *geoip2.Reader
-	anonymousDb *geoip2.Reader
+	countryDb   *maxminddb.Reader
+	asnDb       *maxminddb.Reader
+	anonymousDb *maxminddb.Reader
 }
 
 func NewGlobioProvider(ctx context.Context) (*GlobioProvider, error) {
 	return &GlobioProvider{}, nil
 }
 
-func readGlobioDb(_ context.Context, file string) (*geoip2.Reader, error) {
+func readGlobioDb(_ context.Context, file string) (*maxminddb.Reader, error) {
 	if file == "" {
 		return nil, nil
 	}
@@ -35,7 +36,7 @@ func readGlobioDb(_ context.Context, file string) (*geoip2.Reader, error) {
 		return nil, fmt.Errorf("file not found %s", file)
 	}
 
-	db, err := geoip2.Open(file)
+	db, err := maxminddb.Open(file)
 	if err != nil {
 		return nil, err
 	}
@@ -50,8 +51,7 @@ func (gp *GlobioProvider) Start(ctx context.Context) error {
 		log.Warn().Msg("Globio Provider download is disabled, attempting to load existing databases")
 		err := gp.loadDatabases(ctx)
 		if err != nil {
-			log.Warn().Err(err).Msg("failed to load Globio databases")
-			return nil
+			return &utils.LoadError{Err: err}
 		}
 		log.Info().Msg("Globio Provider loaded existing databases successfully")
 		return nil
@@ -60,7 +60,7 @@ func (gp *GlobioProvider) Start(ctx context.Context) error {
 	return gp.Refresh(ctx)
 }
 
-func (gp *GlobioProvider) downloadDb(_ context.Context, dbName string) error {
+func (gp *GlobioProvider) downloadDb(ctx context.Context, dbName string) error {
 	fileURL := viper.GetString(fmt.Sprintf("providers.globio.download.%s", dbName))
 	if fileURL == "" {
 		log.Warn().Msg("Globio Provider fileURL is empty")
@@ -80,7 +80,7 @@ func (gp *GlobioProvider) downloadDb(_ context.Context, dbName string) error {
 		return err
 	}
 
-	return utils.DownloadFileWithProgress(fileURL, filePath)
+	return utils.DownloadFileWithProgress(ctx, fileURL, filePath)
 }
 
 func (gp *GlobioProvider) Lookup(ctx context.Context, address string, asFallback bool) (*utils.IPInfo, error) {
@@ -105,12 +105,15 @@ func (gp *GlobioProvider) Lookup(ctx context.Context, address string, asFallback
 		Subdivisions:       []*utils.Subdivision{},
 		Traits:             &utils.Traits{},
 	}
+	match := newNetworkMatch(ip)
 
 	if gp.countryDb != nil {
-		country, err := gp.countryDb.Country(ip)
+		var country geoip2.Country
+		network, _, err := gp.countryDb.LookupNetwork(ip, &country)
 		if err != nil {
 			return nil, err
 		}
+		match.add(network)
 
 		err = copier.Copy(&info, &country)
 		if err != nil {
@@ -120,16 +123,14 @@ func (gp *GlobioProvider) Lookup(ctx context.Context, address string, asFallback
 
 	// query the ASN database if available
 	if gp.asnDb != nil {
-		asn, err := gp.asnDb.ASN(ip)
+		asn, network, _, err := lookupAsnDb(gp.asnDb, ip)
 		if err != nil {
 			return nil, err
 		}
 
-		err = copier.Copy(&info.ASN, &asn)
-		if err != nil {
-			return nil, err
-		}
+		info.ASN = asn
 		info.HasASN = true
+		match.add(network)
 	}
 
 	// globio does not have city data
@@ -137,10 +138,12 @@ func (gp *GlobioProvider) Lookup(ctx context.Context, address string, asFallback
 
 	// query the anonymous IP database if available
 	if gp.anonymousDb != nil {
-		anon, err := gp.anonymousDb.AnonymousIP(ip)
+		var anon geoip2.AnonymousIP
+		network, _, err := gp.anonymousDb.LookupNetwork(ip, &anon)
 		if err != nil {
 			return nil, err
 		}
+		match.add(network)
 
 		err = copier.Copy(&info.AnonymousIP, &anon)
 		if err != nil {
@@ -150,9 +153,25 @@ func (gp *GlobioProvider) Lookup(ctx context.Context, address string, asFallback
 		info.HasAnonymousIP = true
 	}
 
+	info.Network = match.String()
+
 	return info, nil
 }
 
+// LookupASN returns the ASN of address and the network it was matched in
+func (gp *GlobioProvider) LookupASN(ctx context.Context, address string) (*utils.ASN, *net.IPNet, error) {
+	return lookupAsn(gp.asnDb, address)
+}
+
+// Health reports the state of the Globio databases
+func (gp *GlobioProvider) Health(ctx context.Context) *Health {
+	return databaseHealth(appendDatabaseInfo(nil,
+		mmdbDatabaseInfo("globio", "country", gp.countryDb),
+		mmdbDatabaseInfo("globio", "asn", gp.asnDb),
+		mmdbDatabaseInfo("globio", "anonymous", gp.anonymousDb),
+	))
+}
+
 func (gp *GlobioProvider) Shutdown(ctx context.Context) {
 	if gp.countryDb != nil {
 		gp.countryDb.Close()
@@ -191,13 +210,15 @@ func (gp *GlobioProvider) loadDatabases(ctx context.Context) error {
 		return err
 	}
 	gp.countryDb = db
+	recordMmdbLoad("globio", "country", db)
 
 	// load the ASN database
-	db, err = readGlobioDb(ctx, viper.GetString("providers.globio.db.asn"))
+	asnDb, err := readAsnDb(ctx, viper.GetString("providers.globio.db.asn"))
 	if err != nil {
 		return err
 	}
-	gp.asnDb = db
+	gp.asnDb = asnDb
+	recordMmdbLoad("globio", "asn", asnDb)
 
 	// load the anonymous IP database (optional)
 	db, err = readGlobioDb(ctx, viper.GetString("providers.globio.db.anonymous"))
@@ -205,6 +226,7 @@ func (gp *GlobioProvider) loadDatabases(ctx context.Context) error {
 		return err
 	}
 	gp.anonymousDb = db
+	recordMmdbLoad("globio", "anonymous", db)
 
 	return nil
 }
@@ -0,0 +1,52 @@
+package provider
+
+import (
+	"context"
+)
+
+// Breaker states reported in Health
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// Health is the state of a provider as reported on /_health
+type Health struct {
+	// Ready is true when the provider is able to answer lookups
+	Ready     bool            `json:"ready"`
+	Databases []*DatabaseInfo `json:"databases,omitempty"`
+	Breaker   string          `json:"breaker,omitempty"`
+	Budget    *BudgetStatus   `json:"budget,omitempty"`
+}
+
+// HealthReporter is implemented by providers that can report their state.
+// Providers that don't implement it are assumed to be ready
+type HealthReporter interface {
+	Health(ctx context.Context) *Health
+}
+
+// healthOf returns the health of provider
+func healthOf(ctx context.Context, provider IPProvider) *Health {
+	if reporter, ok := provider.(HealthReporter); ok {
+		return reporter.Health(ctx)
+	}
+
+	return &Health{Ready: true}
+}
+
+// databaseHealth is the health of a provider backed by local databases. It is
+// ready as soon as one of its databases is loaded
+func databaseHealth(databases []*DatabaseInfo) *Health {
+	health := &Health{
+		Databases: databases,
+	}
+
+	for _, database := range databases {
+		if database.Loaded {
+			health.Ready = true
+		}
+	}
+
+	return health
+}
@@ -0,0 +1,198 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testBinRow is a range of an IP2Location fixture, from its address up to
+// the next row. Fields missing from it are unknown
+type testBinRow struct {
+	from   string
+	fields map[string]string
+}
+
+// testBinWriter lays out an IP2Location BIN database: the header, the
+// strings, the indexes and the rows of each section
+type testBinWriter struct {
+	dbType  int
+	columns int
+	buffer  bytes.Buffer
+	strings map[string]uint32
+}
+
+// writeTestBin writes a database of type DB<dbType> with columns columns and
+// the rows of each section to a temporary directory and returns its path.
+// Rows are sorted and the first one starts at the first address
+func writeTestBin(t *testing.T, dbType int, columns int, v4 []testBinRow, v6 []testBinRow) string {
+	t.Helper()
+
+	w := &testBinWriter{dbType: dbType, columns: columns, strings: map[string]uint32{}}
+	w.buffer.Write(make([]byte, 64))
+
+	v4Rows := w.rows(t, v4, 4)
+	v6Rows := w.rows(t, v6, 16)
+
+	header := make([]byte, 64)
+	header[0] = byte(dbType)
+	header[1] = byte(columns)
+	header[2], header[3], header[4] = 25, 10, 1
+	header[29] = 1
+
+	if len(v4Rows) > 0 {
+		binary.LittleEndian.PutUint32(header[5:], uint32(len(v4Rows)))
+		binary.LittleEndian.PutUint32(header[21:], uint32(w.buffer.Len()+1))
+		w.index(v4Rows, 4)
+	}
+	if len(v6Rows) > 0 {
+		binary.LittleEndian.PutUint32(header[13:], uint32(len(v6Rows)))
+		binary.LittleEndian.PutUint32(header[25:], uint32(w.buffer.Len()+1))
+		w.index(v6Rows, 16)
+	}
+	if len(v4Rows) > 0 {
+		binary.LittleEndian.PutUint32(header[9:], uint32(w.buffer.Len()+1))
+		w.section(v4Rows)
+	}
+	if len(v6Rows) > 0 {
+		binary.LittleEndian.PutUint32(header[17:], uint32(w.buffer.Len()+1))
+		w.section(v6Rows)
+	}
+
+	data := w.buffer.Bytes()
+	copy(data, header)
+
+	path := filepath.Join(t.TempDir(), "IP2LOCATION-DB"+strconv.Itoa(dbType)+".BIN")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	return path
+}
+
+// str writes value as a length prefixed string and returns its offset
+func (w *testBinWriter) str(value string) uint32 {
+	if offset, ok := w.strings[value]; ok {
+		return offset
+	}
+
+	offset := uint32(w.buffer.Len())
+	w.buffer.WriteByte(byte(len(value)))
+	w.buffer.WriteString(value)
+	w.strings[value] = offset
+
+	return offset
+}
+
+// rows encodes the rows of a section, followed by the row ending the last
+// range at the last address. The strings they point to are written as they
+// are encoded
+func (w *testBinWriter) rows(t *testing.T, rows []testBinRow, ipSize int) [][]byte {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	last := "255.255.255.255"
+	if ipSize == 16 {
+		last = "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"
+	}
+	rows = append(slices.Clone(rows), testBinRow{from: last})
+
+	var encoded [][]byte
+	for i, row := range rows {
+		addr := netip.MustParseAddr(row.from).AsSlice()
+		require.Len(t, addr, ipSize)
+		if i == 0 {
+			require.Equal(t, make([]byte, ipSize), addr, "the first row starts at the first address")
+		} else {
+			require.Less(t, bytes.Compare(ip2locationAddr(encoded[i-1][:ipSize]), addr), 0, "rows are sorted")
+		}
+
+		data := ip2locationAddr(addr)
+		for position := 2; position <= w.columns; position++ {
+			data = binary.LittleEndian.AppendUint32(data, w.column(t, row, position))
+		}
+		encoded = append(encoded, data)
+	}
+
+	return encoded
+}
+
+// column returns the value of the column at position of row
+func (w *testBinWriter) column(t *testing.T, row testBinRow, position int) uint32 {
+	for field, positions := range ip2locationPositions {
+		if int(positions[w.dbType]) != position {
+			continue
+		}
+
+		value, ok := row.fields[field]
+		switch {
+		case field == ip2locationLatitude || field == ip2locationLongitude:
+			number, err := strconv.ParseFloat(value, 32)
+			require.True(t, err == nil || !ok, "invalid %s %q", field, value)
+			return math.Float32bits(float32(number))
+		case field == ip2locationCountry:
+			if !ok {
+				value = "-"
+			} else {
+				require.Len(t, value, 2, "country codes are 2 letters")
+			}
+			name, ok := row.fields["country_name"]
+			if !ok {
+				name = "-"
+			}
+			// the name follows the code
+			offset := uint32(w.buffer.Len())
+			w.buffer.WriteByte(byte(len(value)))
+			w.buffer.WriteString(value)
+			w.buffer.WriteByte(byte(len(name)))
+			w.buffer.WriteString(name)
+			return offset
+		case !ok:
+			return w.str("-")
+		default:
+			return w.str(value)
+		}
+	}
+
+	// columns the provider doesn't read
+	return w.str("-")
+}
+
+// index writes the rows to search for each value of the first 16 bits of
+// the addresses
+func (w *testBinWriter) index(rows [][]byte, ipSize int) {
+	rowOf := func(addr []byte) uint32 {
+		i, _ := slices.BinarySearchFunc(rows, addr, func(row []byte, addr []byte) int {
+			return bytes.Compare(ip2locationAddr(row[:ipSize]), addr)
+		})
+		if i == len(rows) || !bytes.Equal(ip2locationAddr(rows[i][:ipSize]), addr) {
+			i--
+		}
+		return uint32(i)
+	}
+
+	for key := 0; key < 65536; key++ {
+		low := make([]byte, ipSize)
+		high := bytes.Repeat([]byte{0xff}, ipSize)
+		binary.BigEndian.PutUint16(low, uint16(key))
+		binary.BigEndian.PutUint16(high, uint16(key))
+
+		var pair [8]byte
+		binary.LittleEndian.PutUint32(pair[:], rowOf(low))
+		binary.LittleEndian.PutUint32(pair[4:], rowOf(high))
+		w.buffer.Write(pair[:])
+	}
+}
+
+func (w *testBinWriter) section(rows [][]byte) {
+	for _, row := range rows {
+		w.buffer.Write(row)
+	}
+}
@@ -0,0 +1,639 @@
+package provider
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+
+	"github.com/cloud66-oss/geo/utils"
+)
+
+// Fields of the IP2Location databases
+const (
+	ip2locationCountry   = "country"
+	ip2locationRegion    = "region"
+	ip2locationCity      = "city"
+	ip2locationISP       = "isp"
+	ip2locationLatitude  = "latitude"
+	ip2locationLongitude = "longitude"
+	ip2locationDomain    = "domain"
+	ip2locationZipCode   = "zip_code"
+	ip2locationTimeZone  = "time_zone"
+	ip2locationUsageType = "usage_type"
+	ip2locationASN       = "asn"
+	ip2locationAS        = "as"
+)
+
+// ip2locationPositions are the columns of the fields in each database type,
+// DB1 to DB26. The first column is the start of the range, and 0 is for
+// fields the type doesn't have
+var ip2locationPositions = map[string][27]uint8{
+	ip2locationCountry:   {0, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2},
+	ip2locationRegion:    {0, 0, 0, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3},
+	ip2locationCity:      {0, 0, 0, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4},
+	ip2locationISP:       {0, 0, 3, 0, 5, 0, 7, 5, 7, 0, 8, 0, 9, 0, 9, 0, 9, 0, 9, 7, 9, 0, 9, 7, 9, 9, 9},
+	ip2locationLatitude:  {0, 0, 0, 0, 0, 5, 5, 0, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+	ip2locationLongitude: {0, 0, 0, 0, 0, 6, 6, 0, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6},
+	ip2locationDomain:    {0, 0, 0, 0, 0, 0, 0, 6, 8, 0, 9, 0, 10, 0, 10, 0, 10, 0, 10, 8, 10, 0, 10, 8, 10, 10, 10},
+	ip2locationZipCode:   {0, 0, 0, 0, 0, 0, 0, 0, 0, 7, 7, 7, 7, 0, 7, 7, 7, 0, 7, 0, 7, 7, 7, 0, 7, 7, 7},
+	ip2locationTimeZone:  {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 8, 8, 7, 8, 8, 8, 7, 8, 0, 8, 8, 8, 0, 8, 8, 8},
+	ip2locationUsageType: {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 12, 20, 20, 20},
+	ip2locationASN:       {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 24},
+	ip2locationAS:        {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 25},
+}
+
+// ip2locationHostingUsage are the usage types of data centers, hosting
+// providers and CDNs
+var ip2locationHostingUsage = []string{"DCH", "CDN"}
+
+// ip2locationHeaderSize is the size of the header of the BIN format
+const ip2locationHeaderSize = 64
+
+// Ip2LocationDb is an IP2Location database in the BIN format. Ranges are
+// sorted rows of columns of 4 bytes, strings are stored elsewhere in the
+// file, and both are read from the file as they're looked up
+type Ip2LocationDb struct {
+	file      *os.File
+	DbType    int
+	Columns   int
+	BuildTime time.Time
+	v4        ip2locationSection
+	v6        ip2locationSection
+}
+
+// ip2locationSection is the IPv4 or the IPv6 part of a database. Addresses
+// in the header are offsets in the file starting at 1
+type ip2locationSection struct {
+	count   uint32
+	base    uint32
+	index   uint32
+	ipSize  uint32
+	rowSize uint32
+}
+
+// ip2locationRecord is the row of an address
+type ip2locationRecord struct {
+	from    netip.Addr
+	to      netip.Addr
+	columns []byte
+}
+
+// OpenIp2LocationDb opens the IP2Location BIN database in file
+func OpenIp2LocationDb(file string) (*Ip2LocationDb, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := readIp2LocationHeader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("invalid IP2Location database %s: %w", file, err)
+	}
+
+	return db, nil
+}
+
+func readIp2LocationHeader(f *os.File) (*Ip2LocationDb, error) {
+	header := make([]byte, ip2locationHeaderSize)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+
+	db := &Ip2LocationDb{
+		file:    f,
+		DbType:  int(header[0]),
+		Columns: int(header[1]),
+	}
+
+	if db.DbType < 1 || db.DbType > 26 || db.Columns < 2 {
+		return nil, fmt.Errorf("unknown database type DB%d with %d columns", db.DbType, db.Columns)
+	}
+	// IP2Proxy databases share the format but not the columns. Their proxy
+	// flags are out of scope, so only the hosting flag is derived
+	if product := header[29]; product > 1 {
+		return nil, fmt.Errorf("unknown product %d", product)
+	}
+
+	db.BuildTime = time.Date(2000+int(header[2]), time.Month(header[3]), int(header[4]), 0, 0, 0, 0, time.UTC)
+	db.v4 = ip2locationSection{
+		count:   binary.LittleEndian.Uint32(header[5:]),
+		base:    binary.LittleEndian.Uint32(header[9:]),
+		index:   binary.LittleEndian.Uint32(header[21:]),
+		ipSize:  4,
+		rowSize: uint32(db.Columns) * 4,
+	}
+	db.v6 = ip2locationSection{
+		count:   binary.LittleEndian.Uint32(header[13:]),
+		base:    binary.LittleEndian.Uint32(header[17:]),
+		index:   binary.LittleEndian.Uint32(header[25:]),
+		ipSize:  16,
+		rowSize: 16 + uint32(db.Columns-1)*4,
+	}
+
+	return db, nil
+}
+
+// DatabaseType returns the type of the database, such as IP2Location DB11
+func (db *Ip2LocationDb) DatabaseType() string {
+	return fmt.Sprintf("IP2Location DB%d", db.DbType)
+}
+
+func (db *Ip2LocationDb) Close() error {
+	return db.file.Close()
+}
+
+// lookup returns the row of addr, or nil when the database doesn't have it
+func (db *Ip2LocationDb) lookup(addr netip.Addr) (*ip2locationRecord, error) {
+	section := db.v6
+	if addr.Is4() {
+		section = db.v4
+	}
+	if section.count == 0 {
+		return nil, nil
+	}
+
+	ip := addr.As16()
+	key := ip[:]
+	if addr.Is4() {
+		key = ip[12:]
+	}
+
+	low, high := uint32(0), section.count
+	if section.index > 0 {
+		pair := make([]byte, 8)
+		offset := int64(section.index-1) + int64(binary.BigEndian.Uint16(key))*8
+		if _, err := db.file.ReadAt(pair, offset); err != nil {
+			return nil, err
+		}
+		low, high = binary.LittleEndian.Uint32(pair), binary.LittleEndian.Uint32(pair[4:])
+	}
+
+	// a row holds from its address up to the address of the next row
+	row := make([]byte, section.rowSize+section.ipSize)
+	for low <= high {
+		mid := low + (high-low)/2
+		offset := int64(section.base-1) + int64(mid)*int64(section.rowSize)
+		if _, err := db.file.ReadAt(row, offset); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		from := ip2locationAddr(row[:section.ipSize])
+		to := ip2locationAddr(row[section.rowSize:])
+		switch {
+		case bytes.Compare(key, from) < 0:
+			if mid == 0 {
+				return nil, nil
+			}
+			high = mid - 1
+		case bytes.Compare(key, to) >= 0:
+			low = mid + 1
+		default:
+			return &ip2locationRecord{
+				from:    ip2locationToAddr(from),
+				to:      ip2locationToAddr(to),
+				columns: slices.Clone(row[section.ipSize:section.rowSize]),
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ip2locationAddr returns the big endian bytes of a little endian address
+func ip2locationAddr(value []byte) []byte {
+	addr := slices.Clone(value)
+	slices.Reverse(addr)
+
+	return addr
+}
+
+func ip2locationToAddr(value []byte) netip.Addr {
+	addr, _ := netip.AddrFromSlice(value)
+
+	return addr
+}
+
+// column returns the value of field in the record, and false when the
+// database type doesn't have it
+func (db *Ip2LocationDb) column(record *ip2locationRecord, field string) (uint32, bool) {
+	position := int(ip2locationPositions[field][db.DbType])
+	// the range start isn't part of the columns
+	offset := (position - 2) * 4
+	if position == 0 || offset+4 > len(record.columns) {
+		return 0, false
+	}
+
+	return binary.LittleEndian.Uint32(record.columns[offset:]), true
+}
+
+// str returns the string field of the record, with the "-" of unknown values
+// as an empty string
+func (db *Ip2LocationDb) str(record *ip2locationRecord, field string, shift uint32) (string, error) {
+	pointer, ok := db.column(record, field)
+	if !ok {
+		return "", nil
+	}
+
+	// strings are prefixed with their length and are at most 255 bytes
+	buffer := make([]byte, 256)
+	n, err := db.file.ReadAt(buffer, int64(pointer+shift))
+	if n == 0 && err != nil {
+		return "", err
+	}
+	length := int(buffer[0])
+	if length >= n {
+		return "", fmt.Errorf("truncated string at %d", pointer+shift)
+	}
+
+	value := string(buffer[1 : 1+length])
+	if value == "-" {
+		return "", nil
+	}
+
+	return value, nil
+}
+
+// float returns the float field of the record
+func (db *Ip2LocationDb) float(record *ip2locationRecord, field string) (float64, bool) {
+	value, ok := db.column(record, field)
+	if !ok {
+		return 0, false
+	}
+
+	// the shortest decimal of the float32, as 37.40599 rather than 37.405991
+	number, _ := strconv.ParseFloat(strconv.FormatFloat(float64(math.Float32frombits(value)), 'f', -1, 32), 64)
+
+	return number, true
+}
+
+// network returns the largest network containing addr within the range of
+// record, as ranges don't have to be networks
+func (record *ip2locationRecord) network(addr netip.Addr) string {
+	for bits := 0; bits <= addr.BitLen(); bits++ {
+		prefix, _ := addr.Prefix(bits)
+		if prefix.Addr().Compare(record.from) >= 0 && lastAddr(prefix).Compare(record.to) < 0 {
+			return prefix.String()
+		}
+	}
+
+	return ""
+}
+
+// lastAddr returns the last address of prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Masked().Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(addr)*8; bit++ {
+		addr[bit/8] |= 0x80 >> (bit % 8)
+	}
+
+	return ip2locationToAddr(addr)
+}
+
+// Ip2LocationProvider is a provider that uses IP2Location BIN databases,
+// DB1 to DB26 with IPv4 and IPv6 data
+type Ip2LocationProvider struct {
+	sync.RWMutex
+	db *Ip2LocationDb
+}
+
+func NewIp2LocationProvider(ctx context.Context) (*Ip2LocationProvider, error) {
+	return &Ip2LocationProvider{}, nil
+}
+
+func (ip *Ip2LocationProvider) Start(ctx context.Context) error {
+	log.Info().Msg("starting IP2Location Provider")
+
+	if !viper.GetBool("providers.ip2location.download.enabled") {
+		log.Warn().Msg("IP2Location Provider download is disabled, attempting to load the existing database")
+		err := ip.loadDatabase(ctx)
+		if err != nil {
+			return &utils.LoadError{Err: err}
+		}
+		log.Info().Msg("IP2Location Provider loaded the existing database successfully")
+		return nil
+	}
+
+	return ip.Refresh(ctx)
+}
+
+// downloadDb downloads the database. IP2Location serves it zipped, so the
+// archive is kept next to the database for its ETag and the database is
+// extracted when the archive changes
+func (ip *Ip2LocationProvider) downloadDb(ctx context.Context) error {
+	fileURL := viper.GetString("providers.ip2location.download.url")
+	if fileURL == "" {
+		log.Warn().Msg("IP2Location Provider fileURL is empty")
+		return nil
+	}
+
+	filePath := viper.GetString("providers.ip2location.db")
+	if filePath == "" {
+		return errors.New("no local path defined for the IP2Location database. Use providers.ip2location.db to define it")
+	}
+
+	log.Info().Str("source", utils.RedactURL(fileURL)).Str("dest", filePath).Msg("downloading")
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return err
+	}
+
+	archive := utils.ChangeExt(filePath, "zip")
+	if err := utils.DownloadFileWithProgress(ctx, fileURL, archive); err != nil {
+		return err
+	}
+
+	archiveStat, err := os.Stat(archive)
+	if err != nil {
+		return err
+	}
+	if stat, err := os.Stat(filePath); err == nil && !stat.ModTime().Before(archiveStat.ModTime()) {
+		return nil
+	}
+
+	return extractIp2LocationDb(archive, filePath)
+}
+
+// extractIp2LocationDb extracts the first .BIN file of the zip archive to
+// dest. Archives that aren't zipped are the database itself
+func extractIp2LocationDb(archive string, dest string) error {
+	tmpPath := dest + ".tmp"
+	defer os.Remove(tmpPath)
+
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	reader, err := zip.OpenReader(archive)
+	if errors.Is(err, zip.ErrFormat) {
+		in, err := os.Open(archive)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		if _, err := io.Copy(out, in); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else {
+		defer reader.Close()
+
+		index := slices.IndexFunc(reader.File, func(file *zip.File) bool {
+			return strings.EqualFold(filepath.Ext(file.Name), ".bin")
+		})
+		if index < 0 {
+			return errors.New("no .BIN file found in archive")
+		}
+
+		in, err := reader.File[index].Open()
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		if _, err := io.Copy(out, in); err != nil {
+			return err
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if err := checkIp2LocationDb(tmpPath); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, dest)
+}
+
+// checkIp2LocationDb returns an error when path isn't an IP2Location
+// database, so a bad download doesn't replace a good database
+func checkIp2LocationDb(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := readIp2LocationHeader(f); err != nil {
+		return fmt.Errorf("invalid IP2Location database: %w", err)
+	}
+
+	return nil
+}
+
+func (ip *Ip2LocationProvider) Lookup(ctx context.Context, address string, asFallback bool) (*utils.IPInfo, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return nil, &utils.IpAddressError{}
+	}
+	addr = addr.Unmap()
+
+	// the database is closed when replaced, so it is held for the lookup
+	ip.RLock()
+	defer ip.RUnlock()
+
+	db := ip.db
+	if db == nil {
+		return nil, nil
+	}
+
+	record, err := db.lookup(addr)
+	if err != nil || record == nil {
+		return nil, err
+	}
+
+	info := &utils.IPInfo{
+		Address:            address,
+		Source:             "ip2location",
+		IsFallback:         asFallback,
+		ASN:                &utils.ASN{},
+		Location:           &utils.Location{},
+		AnonymousIP:        &utils.AnonymousIP{},
+		City:               &utils.City{},
+		Continent:          &utils.Continent{},
+		Country:            &utils.Country{},
+		Postal:             &utils.Postal{},
+		RegisteredCountry:  &utils.Country{},
+		RepresentedCountry: &utils.Country{},
+		Subdivisions:       []*utils.Subdivision{},
+		Traits:             &utils.Traits{},
+		Network:            record.network(addr),
+	}
+
+	if err := db.read(record, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// read maps the fields of the record onto info
+func (db *Ip2LocationDb) read(record *ip2locationRecord, info *utils.IPInfo) error {
+	var err error
+	str := func(field string, shift uint32) string {
+		if err != nil {
+			return ""
+		}
+		var value string
+		value, err = db.str(record, field, shift)
+		return value
+	}
+
+	country := str(ip2locationCountry, 0)
+	// the country name follows its 2 letter code
+	countryName := str(ip2locationCountry, 3)
+	region := str(ip2locationRegion, 0)
+	city := str(ip2locationCity, 0)
+	zipCode := str(ip2locationZipCode, 0)
+	timeZone := str(ip2locationTimeZone, 0)
+	isp := str(ip2locationISP, 0)
+	domain := str(ip2locationDomain, 0)
+	usageType := str(ip2locationUsageType, 0)
+	asn := str(ip2locationASN, 0)
+	as := str(ip2locationAS, 0)
+	if err != nil {
+		return err
+	}
+
+	if country != "" {
+		info.Country.IsoCode = country
+		info.Country.Names = map[string]string{"en": countryName}
+	}
+
+	if region != "" {
+		info.Subdivisions = append(info.Subdivisions, &utils.Subdivision{Names: map[string]string{"en": region}})
+	}
+
+	_, info.HasCity = db.column(record, ip2locationCity)
+	if city != "" {
+		info.City.Names = map[string]string{"en": city}
+	}
+
+	info.Postal.Code = zipCode
+
+	latitude, hasLatitude := db.float(record, ip2locationLatitude)
+	longitude, _ := db.float(record, ip2locationLongitude)
+	if hasLatitude {
+		info.Location.Latitude = latitude
+		info.Location.Longitude = longitude
+	}
+	// time zones are UTC offsets such as -07:00
+	info.Location.TimeZone = timeZone
+
+	info.Traits.ISP = isp
+	info.Traits.Domain = domain
+	info.Traits.UsageType = usageType
+
+	if _, ok := db.column(record, ip2locationUsageType); ok {
+		info.HasAnonymousIP = true
+		for _, usage := range strings.Split(usageType, "/") {
+			if slices.Contains(ip2locationHostingUsage, usage) {
+				info.AnonymousIP.IsHostingProvider = true
+			}
+		}
+	}
+
+	if number, err := strconv.ParseUint(asn, 10, 32); err == nil {
+		info.ASN.AutonomousSystemNumber = uint(number)
+		info.ASN.AutonomousSystemOrganization = as
+		info.HasASN = true
+	}
+
+	return nil
+}
+
+// Health reports the state of the IP2Location database
+func (ip *Ip2LocationProvider) Health(ctx context.Context) *Health {
+	ip.RLock()
+	defer ip.RUnlock()
+
+	path := viper.GetString("providers.ip2location.db")
+	if path == "" {
+		return databaseHealth(nil)
+	}
+
+	info := &DatabaseInfo{
+		Name: "db",
+		Path: path,
+	}
+	if ip.db != nil {
+		info.Loaded = true
+		info.DatabaseType = ip.db.DatabaseType()
+		info.BuildTime = ip.db.BuildTime
+	}
+
+	return databaseHealth([]*DatabaseInfo{info})
+}
+
+func (ip *Ip2LocationProvider) Shutdown(ctx context.Context) {
+	ip.Lock()
+	defer ip.Unlock()
+
+	if ip.db != nil {
+		ip.db.Close()
+		ip.db = nil
+	}
+}
+
+func (ip *Ip2LocationProvider) Refresh(ctx context.Context) error {
+	log.Info().Msg("refreshing IP2Location Provider")
+	if err := ip.downloadDb(ctx); err != nil {
+		return err
+	}
+
+	return ip.loadDatabase(ctx)
+}
+
+func (ip *Ip2LocationProvider) loadDatabase(ctx context.Context) error {
+	file := viper.GetString("providers.ip2location.db")
+	if file == "" {
+		return nil
+	}
+
+	if !utils.FileExists(file) {
+		return fmt.Errorf("file not found %s", file)
+	}
+
+	db, err := OpenIp2LocationDb(file)
+	if err != nil {
+		return err
+	}
+
+	ip.Lock()
+	previous := ip.db
+	ip.db = db
+	ip.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+
+	utils.RecordDatabaseLoad("ip2location", "db", uint(db.BuildTime.Unix()))
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package provider
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+var testIp2LocationV4 = []testBinRow{
+	{from: "0.0.0.0"},
+	{from: "8.8.8.0", fields: map[string]string{
+		ip2locationCountry:   "US",
+		"country_name":       "United States of America",
+		ip2locationRegion:    "California",
+		ip2locationCity:      "Mountain View",
+		ip2locationLatitude:  "37.40599",
+		ip2locationLongitude: "-122.078514",
+		ip2locationZipCode:   "94043",
+		ip2locationTimeZone:  "-07:00",
+		ip2locationISP:       "Google LLC",
+		ip2locationDomain:    "google.com",
+		ip2locationUsageType: "DCH",
+		ip2locationASN:       "15169",
+		ip2locationAS:        "Google LLC",
+	}},
+	{from: "8.8.9.0"},
+	// ranges don't have to be networks
+	{from: "81.2.69.100", fields: map[string]string{
+		ip2locationCountry:   "GB",
+		"country_name":       "United Kingdom of Great Britain and Northern Ireland",
+		ip2locationCity:      "London",
+		ip2locationUsageType: "ISP/MOB",
+	}},
+	{from: "81.2.70.0"},
+}
+
+var testIp2LocationV6 = []testBinRow{
+	{from: "::"},
+	{from: "2001:db8::", fields: map[string]string{
+		ip2locationCountry: "DE",
+		"country_name":     "Germany",
+		ip2locationCity:    "Berlin",
+	}},
+	{from: "2001:db9::"},
+}
+
+type ip2locationProviderTestSuite struct {
+	suite.Suite
+	provider *Ip2LocationProvider
+}
+
+func (suite *ip2locationProviderTestSuite) SetupTest() {
+	viper.Set("providers.ip2location.db", writeTestBin(suite.T(), 26, 25, testIp2LocationV4, testIp2LocationV6))
+	viper.Set("providers.ip2location.download.enabled", false)
+
+	var err error
+	suite.provider, err = NewIp2LocationProvider(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.provider.Start(context.Background()))
+}
+
+func (suite *ip2locationProviderTestSuite) TearDownTest() {
+	suite.provider.Shutdown(context.Background())
+	viper.Set("providers.ip2location.db", "")
+}
+
+func (suite *ip2locationProviderTestSuite) lookup(address string) *utils.IPInfo {
+	info, err := suite.provider.Lookup(context.Background(), address, false)
+	suite.Require().NoError(err)
+
+	return info
+}
+
+func (suite *ip2locationProviderTestSuite) TestLookup() {
+	info := suite.lookup("8.8.8.8")
+	suite.Require().NotNil(info)
+	suite.Equal("ip2location", info.Source)
+	suite.Equal("US", info.Country.IsoCode)
+	suite.Equal("United States of America", info.Country.Names["en"])
+	suite.Equal("California", info.Subdivisions[0].Names["en"])
+	suite.True(info.HasCity)
+	suite.Equal("Mountain View", info.City.Names["en"])
+	suite.Equal(37.40599, info.Location.Latitude)
+	suite.Equal(-122.078514, info.Location.Longitude)
+	suite.Equal("-07:00", info.Location.TimeZone)
+	suite.Equal("94043", info.Postal.Code)
+	suite.Equal("Google LLC", info.Traits.ISP)
+	suite.Equal("google.com", info.Traits.Domain)
+	suite.Equal("DCH", info.Traits.UsageType)
+	suite.True(info.HasAnonymousIP)
+	suite.True(info.AnonymousIP.IsHostingProvider)
+	suite.True(info.HasASN)
+	suite.Equal(&utils.ASN{AutonomousSystemNumber: 15169, AutonomousSystemOrganization: "Google LLC"}, info.ASN)
+	suite.Equal("8.8.8.0/24", info.Network)
+
+	info = suite.lookup("81.2.69.160")
+	suite.Require().NotNil(info)
+	suite.Equal("GB", info.Country.IsoCode)
+	suite.Empty(info.Subdivisions)
+	suite.False(info.AnonymousIP.IsHostingProvider)
+	suite.False(info.HasASN)
+	// the largest network within 81.2.69.100 - 81.2.69.255
+	suite.Equal("81.2.69.128/25", info.Network)
+	suite.Equal("81.2.69.104/29", suite.lookup("81.2.69.107").Network)
+
+	info = suite.lookup("2001:db8::1")
+	suite.Require().NotNil(info)
+	suite.Equal("DE", info.Country.IsoCode)
+	suite.Equal("Berlin", info.City.Names["en"])
+	suite.Equal("2001:db8::/32", info.Network)
+
+	// unknown values are left empty
+	info = suite.lookup("1.1.1.1")
+	suite.Require().NotNil(info)
+	suite.Empty(info.Country.IsoCode)
+	suite.Empty(info.City.Names)
+	suite.Equal("0.0.0.0/5", info.Network)
+
+	_, err := suite.provider.Lookup(context.Background(), "nope", false)
+	suite.IsType(&utils.IpAddressError{}, err)
+}
+
+func (suite *ip2locationProviderTestSuite) TestDatabaseTypes() {
+	// DB1 only has countries, and only IPv4 here
+	viper.Set("providers.ip2location.db", writeTestBin(suite.T(), 1, 2, testIp2LocationV4, nil))
+	suite.Require().NoError(suite.provider.Refresh(context.Background()))
+
+	info := suite.lookup("8.8.8.8")
+	suite.Require().NotNil(info)
+	suite.Equal("US", info.Country.IsoCode)
+	suite.False(info.HasCity)
+	suite.False(info.HasAnonymousIP)
+	suite.Empty(info.Traits.ISP)
+	suite.Nil(suite.lookup("2001:db8::1"))
+
+	// DB11 has the location, zip code and time zone but no ISP
+	viper.Set("providers.ip2location.db", writeTestBin(suite.T(), 11, 8, testIp2LocationV4, testIp2LocationV6))
+	suite.Require().NoError(suite.provider.Refresh(context.Background()))
+
+	info = suite.lookup("8.8.8.8")
+	suite.Require().NotNil(info)
+	suite.Equal("Mountain View", info.City.Names["en"])
+	suite.Equal("94043", info.Postal.Code)
+	suite.Equal("-07:00", info.Location.TimeZone)
+	suite.Empty(info.Traits.ISP)
+	suite.NotNil(suite.lookup("2001:db8::1"))
+
+	health := suite.provider.Health(context.Background())
+	suite.True(health.Ready)
+	suite.Equal("IP2Location DB11", health.Databases[0].DatabaseType)
+	suite.Equal(time.Date(2025, time.October, 1, 0, 0, 0, 0, time.UTC), health.Databases[0].BuildTime)
+}
+
+func (suite *ip2locationProviderTestSuite) TestInvalid() {
+	file := filepath.Join(suite.T().TempDir(), "invalid.BIN")
+	suite.Require().NoError(os.WriteFile(file, make([]byte, 64), 0600))
+	viper.Set("providers.ip2location.db", file)
+	suite.Error(suite.provider.Refresh(context.Background()))
+
+	// the previous database keeps answering
+	suite.NotNil(suite.lookup("8.8.8.8"))
+}
+
+func (suite *ip2locationProviderTestSuite) TestDownload() {
+	database, err := os.ReadFile(viper.GetString("providers.ip2location.db"))
+	suite.Require().NoError(err)
+
+	archive := filepath.Join(suite.T().TempDir(), "DB26.zip")
+	f, err := os.Create(archive)
+	suite.Require().NoError(err)
+	writer := zip.NewWriter(f)
+	entry, err := writer.Create("IP2LOCATION-LITE-DB26.IPV6.BIN")
+	suite.Require().NoError(err)
+	_, err = entry.Write(database)
+	suite.Require().NoError(err)
+	suite.Require().NoError(writer.Close())
+	suite.Require().NoError(f.Close())
+
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			downloads++
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeFile(w, r, archive)
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(suite.T().TempDir(), "IP2LOCATION.BIN")
+	viper.Set("providers.ip2location.db", dest)
+	viper.Set("providers.ip2location.download.enabled", true)
+	viper.Set("providers.ip2location.download.url", server.URL)
+	defer viper.Set("providers.ip2location.download.url", "")
+
+	suite.Require().NoError(suite.provider.Refresh(context.Background()))
+	suite.FileExists(dest)
+	suite.Equal("US", suite.lookup("8.8.8.8").Country.IsoCode)
+
+	// unchanged databases aren't downloaded again
+	suite.Require().NoError(suite.provider.Refresh(context.Background()))
+	suite.Equal(1, downloads)
+}
+
+func (suite *ip2locationProviderTestSuite) TestDownloadNotDatabase() {
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, status))
+		w.WriteHeader(status)
+		fmt.Fprint(w, "<html>not a database, but long enough to have a header</html>")
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(suite.T().TempDir(), "IP2LOCATION.BIN")
+	viper.Set("providers.ip2location.db", dest)
+	viper.Set("providers.ip2location.download.enabled", true)
+	viper.Set("providers.ip2location.download.url", server.URL+"?token=secret")
+	defer viper.Set("providers.ip2location.download.url", "")
+
+	// neither error pages nor files that aren't databases replace the database
+	suite.Error(suite.provider.Refresh(context.Background()))
+	status = http.StatusOK
+	suite.Error(suite.provider.Refresh(context.Background()))
+
+	suite.NoFileExists(dest)
+	suite.NotNil(suite.lookup("8.8.8.8"))
+}
+
+func TestIp2LocationProviderTestSuite(t *testing.T) {
+	suite.Run(t, new(ip2locationProviderTestSuite))
+}
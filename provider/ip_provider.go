@@ -12,3 +12,11 @@ type IPProvider interface {
 	Shutdown(ctx context.Context)
 	Refresh(ctx context.Context) error
 }
+
+// Patcher is a provider that can add what it knows about an address to the
+// answer of another provider. Patch returns a copy of info with its network
+// narrowed or cleared so the answer still holds for all of it, or info
+// itself when there is nothing to add
+type Patcher interface {
+	Patch(ctx context.Context, info *utils.IPInfo) *utils.IPInfo
+}
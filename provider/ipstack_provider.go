@@ -11,11 +11,21 @@ import (
 )
 
 type IpStackProvider struct {
-	cli *ipstack.Client
+	cli     *ipstack.Client
+	breaker *breaker
+	budget  *budget
 }
 
 func NewIpStackProvider(ctx context.Context) (*IpStackProvider, error) {
-	return &IpStackProvider{}, nil
+	budget, err := newBudget("ipstack")
+	if err != nil {
+		return nil, err
+	}
+
+	return &IpStackProvider{
+		breaker: newBreaker(viper.GetInt("providers.ipstack.breaker.threshold"), viper.GetDuration("providers.ipstack.breaker.cooldown")),
+		budget:  budget,
+	}, nil
 }
 
 func (provider *IpStackProvider) Start(ctx context.Context) error {
@@ -37,7 +47,18 @@ func (provider *IpStackProvider) Start(ctx context.Context) error {
 }
 
 func (provider *IpStackProvider) Lookup(ctx context.Context, address string, asFallback bool) (*utils.IPInfo, error) {
+	if !provider.breaker.allow() {
+		return nil, &utils.ProviderUnavailableError{Provider: "ipstack"}
+	}
+
+	if !provider.budget.reserve() {
+		// the call isn't made, so it doesn't count for the breaker
+		provider.breaker.cancel()
+		return nil, &utils.ProviderUnavailableError{Provider: "ipstack"}
+	}
+
 	ipInfo, err := provider.cli.IP(address)
+	provider.breaker.record(err)
 
 	if err != nil {
 		log.Error().Err(err).Msg("failed to lookup IP address for IPStack")
@@ -100,8 +121,22 @@ func (provider *IpStackProvider) Lookup(ctx context.Context, address string, asF
 	return info, nil
 }
 
+// Health reports the IPStack client as ready unless its breaker is open or its
+// budget is exhausted
+func (provider *IpStackProvider) Health(ctx context.Context) *Health {
+	state := provider.breaker.state()
+	budget := provider.budget.status()
+
+	return &Health{
+		Ready:   provider.cli != nil && state != BreakerOpen && !budget.Exhausted,
+		Breaker: state,
+		Budget:  budget,
+	}
+}
+
 func (provider *IpStackProvider) Shutdown(ctx context.Context) {
 	log.Info().Msg("shutting down IpStack Provider")
+	provider.budget.close()
 }
 
 func (provider *IpStackProvider) Refresh(ctx context.Context) error {
@@ -10,22 +10,23 @@ import (
 	"github.com/cloud66-oss/geo/utils"
 	"github.com/jinzhu/copier"
 	"github.com/oschwald/geoip2-golang"
+	"github.com/oschwald/maxminddb-golang"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 )
 
 // MaxMindProvider is a provider that uses MaxMind databases
 type MaxMindProvider struct {
-	cityDb      *geoip2.Reader
-	asnDb       *geoip2.Reader
-	anonymousDb *geoip2.Reader
+	cityDb      *maxminddb.Reader
+	asnDb       *maxminddb.Reader
+	anonymousDb *maxminddb.Reader
 }
 
 func NewMaxMindProvider(ctx context.Context) (*MaxMindProvider, error) {
 	return &MaxMindProvider{}, nil
 }
 
-func readMaxMindDb(_ context.Context, file string) (*geoip2.Reader, error) {
+func readMaxMindDb(_ context.Context, file string) (*maxminddb.Reader, error) {
 	if file == "" {
 		return nil, nil
 	}
@@ -34,7 +35,7 @@ func readMaxMindDb(_ context.Context, file string) (*geoip2.Reader, error) {
 		return nil, fmt.Errorf("file not found %s", file)
 	}
 
-	db, err := geoip2.Open(file)
+	db, err := maxminddb.Open(file)
 	if err != nil {
 		return nil, err
 	}
@@ -49,8 +50,7 @@ func (mmp *MaxMindProvider) Start(ctx context.Context) error {
 		log.Warn().Msg("MaxMind Provider download is disabled, attempting to load existing databases")
 		err := mmp.loadDatabases(ctx)
 		if err != nil {
-			log.Warn().Err(err).Msg("failed to load MaxMind databases")
-			return nil
+			return &utils.LoadError{Err: err}
 		}
 		log.Info().Msg("MaxMind Provider loaded existing databases successfully")
 		return nil
@@ -59,7 +59,7 @@ func (mmp *MaxMindProvider) Start(ctx context.Context) error {
 	return mmp.Refresh(ctx)
 }
 
-func (mmp *MaxMindProvider) downloadDb(_ context.Context, dbName string) error {
+func (mmp *MaxMindProvider) downloadDb(ctx context.Context, dbName string) error {
 	filePath := viper.GetString(fmt.Sprintf("providers.maxmind.db.%s", dbName))
 	if filePath == "" {
 		log.Debug().Str("db", dbName).Msg("no local path defined, skipping download")
@@ -83,7 +83,7 @@ func (mmp *MaxMindProvider) downloadDb(_ context.Context, dbName string) error {
 		}
 
 		log.Info().Str("edition", editionID).Str("dest", filePath).Msg("downloading from MaxMind")
-		return utils.DownloadMaxMindDb(accountID, licenseKey, editionID, filePath)
+		return utils.DownloadMaxMindDb(ctx, accountID, licenseKey, editionID, filePath)
 	}
 
 	// Fallback: download from configured URL (e.g. GCS mirror)
@@ -94,7 +94,7 @@ func (mmp *MaxMindProvider) downloadDb(_ context.Context, dbName string) error {
 	}
 
 	log.Info().Str("source", fileURL).Str("dest", filePath).Msg("downloading")
-	return utils.DownloadFileWithProgress(fileURL, filePath)
+	return utils.DownloadFileWithProgress(ctx, fileURL, filePath)
 }
 
 func (mmp *MaxMindProvider) Lookup(ctx context.Context, address string, asFallback bool) (*utils.IPInfo, error) {
@@ -119,25 +119,26 @@ func (mmp *MaxMindProvider) Lookup(ctx context.Context, address string, asFallba
 		Subdivisions:       []*utils.Subdivision{},
 		Traits:             &utils.Traits{},
 	}
+	match := newNetworkMatch(ip)
 
 	if mmp.asnDb != nil {
-		asn, err := mmp.asnDb.ASN(ip)
+		asn, network, _, err := lookupAsnDb(mmp.asnDb, ip)
 		if err != nil {
 			return nil, err
 		}
 
-		err = copier.Copy(&info.ASN, &asn)
-		if err != nil {
-			return nil, err
-		}
+		info.ASN = asn
 		info.HasASN = true
+		match.add(network)
 	}
 
 	if mmp.cityDb != nil {
-		city, err := mmp.cityDb.City(ip)
+		var city geoip2.City
+		network, _, err := mmp.cityDb.LookupNetwork(ip, &city)
 		if err != nil {
 			return nil, err
 		}
+		match.add(network)
 
 		err = copier.Copy(&info, &city)
 		if err != nil {
@@ -148,10 +149,12 @@ func (mmp *MaxMindProvider) Lookup(ctx context.Context, address string, asFallba
 	}
 
 	if mmp.anonymousDb != nil {
-		anon, err := mmp.anonymousDb.AnonymousIP(ip)
+		var anon geoip2.AnonymousIP
+		network, _, err := mmp.anonymousDb.LookupNetwork(ip, &anon)
 		if err != nil {
 			return nil, err
 		}
+		match.add(network)
 
 		err = copier.Copy(&info.AnonymousIP, &anon)
 		if err != nil {
@@ -161,9 +164,25 @@ func (mmp *MaxMindProvider) Lookup(ctx context.Context, address string, asFallba
 		info.HasAnonymousIP = true
 	}
 
+	info.Network = match.String()
+
 	return info, nil
 }
 
+// LookupASN returns the ASN of address and the network it was matched in
+func (mmp *MaxMindProvider) LookupASN(ctx context.Context, address string) (*utils.ASN, *net.IPNet, error) {
+	return lookupAsn(mmp.asnDb, address)
+}
+
+// Health reports the state of the MaxMind databases
+func (mmp *MaxMindProvider) Health(ctx context.Context) *Health {
+	return databaseHealth(appendDatabaseInfo(nil,
+		mmdbDatabaseInfo("maxmind", "city", mmp.cityDb),
+		mmdbDatabaseInfo("maxmind", "asn", mmp.asnDb),
+		mmdbDatabaseInfo("maxmind", "anonymous", mmp.anonymousDb),
+	))
+}
+
 func (mmp *MaxMindProvider) Shutdown(ctx context.Context) {
 	if mmp.cityDb != nil {
 		mmp.cityDb.Close()
@@ -196,18 +215,21 @@ func (mmp *MaxMindProvider) loadDatabases(ctx context.Context) error {
 		return err
 	}
 	mmp.cityDb = db
+	recordMmdbLoad("maxmind", "city", db)
 
-	db, err = readMaxMindDb(ctx, viper.GetString("providers.maxmind.db.asn"))
+	asnDb, err := readAsnDb(ctx, viper.GetString("providers.maxmind.db.asn"))
 	if err != nil {
 		return err
 	}
-	mmp.asnDb = db
+	mmp.asnDb = asnDb
+	recordMmdbLoad("maxmind", "asn", asnDb)
 
 	db, err = readMaxMindDb(ctx, viper.GetString("providers.maxmind.db.anonymous"))
 	if err != nil {
 		return err
 	}
 	mmp.anonymousDb = db
+	recordMmdbLoad("maxmind", "anonymous", db)
 
 	return nil
 }
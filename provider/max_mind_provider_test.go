@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type maxMindProviderTestSuite struct {
+	suite.Suite
+	provider *MaxMindProvider
+}
+
+func (suite *maxMindProviderTestSuite) SetupTest() {
+	ctx := context.Background()
+
+	viper.Set("providers.maxmind.download.enabled", false)
+	viper.Set("providers.maxmind.db.city", writeTestMmdb(suite.T(), "GeoIP2-City", map[string]mmdbtype.Map{
+		"81.2.69.0/24": {
+			"city":    mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String("London")}},
+			"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB")},
+		},
+	}))
+	viper.Set("providers.maxmind.db.asn", writeTestMmdb(suite.T(), "GeoLite2-ASN", map[string]mmdbtype.Map{
+		"81.2.0.0/16": {
+			"autonomous_system_number":       mmdbtype.Uint32(20712),
+			"autonomous_system_organization": mmdbtype.String("Andrews & Arnold Ltd"),
+		},
+	}))
+	viper.Set("providers.maxmind.db.anonymous", "")
+
+	var err error
+	suite.provider, err = NewMaxMindProvider(ctx)
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.provider.Start(ctx))
+}
+
+func (suite *maxMindProviderTestSuite) TearDownTest() {
+	suite.provider.Shutdown(context.Background())
+}
+
+func (suite *maxMindProviderTestSuite) TestLookup() {
+	info, err := suite.provider.Lookup(context.Background(), "81.2.69.160", false)
+	suite.Require().NoError(err)
+
+	suite.Equal("London", info.City.Names["en"])
+	suite.Equal("GB", info.Country.IsoCode)
+	suite.EqualValues(20712, info.ASN.AutonomousSystemNumber)
+}
+
+func (suite *maxMindProviderTestSuite) TestNetwork() {
+	// the most specific network of all databases
+	info, err := suite.provider.Lookup(context.Background(), "81.2.69.160", false)
+	suite.Require().NoError(err)
+	suite.Equal("81.2.69.0/24", info.Network)
+
+	info, err = suite.provider.Lookup(context.Background(), "81.2.70.1", false)
+	suite.Require().NoError(err)
+	suite.Empty(info.City.Names)
+	network := netip.MustParsePrefix(info.Network)
+	suite.True(network.Contains(netip.MustParseAddr("81.2.70.1")))
+	suite.False(network.Contains(netip.MustParseAddr("81.2.69.160")))
+}
+
+func TestMaxMindProviderTestSuite(t *testing.T) {
+	suite.Run(t, new(maxMindProviderTestSuite))
+}
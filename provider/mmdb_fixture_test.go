@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestMmdb writes a database of databaseType with records by network to
+// a temporary directory and returns its path
+func writeTestMmdb(t *testing.T, databaseType string, records map[string]mmdbtype.Map) string {
+	t.Helper()
+
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType: databaseType,
+		RecordSize:   24,
+		BuildEpoch:   1760000000,
+	})
+	require.NoError(t, err)
+
+	for network, record := range records {
+		_, ipNet, err := net.ParseCIDR(network)
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(ipNet, record))
+	}
+
+	path := filepath.Join(t.TempDir(), databaseType+".mmdb")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, err = tree.WriteTo(file)
+	require.NoError(t, err)
+
+	return path
+}
@@ -0,0 +1,537 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+
+	"github.com/cloud66-oss/geo/utils"
+)
+
+// MmdbSource is an mmdb database configured under providers.mmdb.databases.
+// Databases with a URL are downloaded to their file
+type MmdbSource struct {
+	Name string `mapstructure:"name"`
+	URL  string `mapstructure:"url"`
+	File string `mapstructure:"file"`
+	// Fields maps IPInfo fields, such as country.iso_code, to the paths of
+	// the records they're read from, such as country. They are keyed by
+	// field as configuration keys aren't case sensitive
+	Fields map[string]string `mapstructure:"fields"`
+}
+
+// mmdbField is an IPInfo field read from a path of the records
+type mmdbField struct {
+	name   string
+	field  []string
+	record []string
+}
+
+// mmdbDatabase is a loaded database with the fields read from it
+type mmdbDatabase struct {
+	name   string
+	db     *maxminddb.Reader
+	fields []*mmdbField
+}
+
+// MmdbProvider answers from mmdb databases of any schema, such as the ones
+// of ipinfo, IPtoASN or Cloudflare, with the fields of their records mapped
+// to IPInfo in the configuration. Databases are queried in order, and a
+// field answered by a database isn't overwritten by the ones after it
+type MmdbProvider struct {
+	sync.RWMutex
+	databases []*mmdbDatabase
+	infos     []*DatabaseInfo
+}
+
+func NewMmdbProvider(ctx context.Context) (*MmdbProvider, error) {
+	return &MmdbProvider{}, nil
+}
+
+func (mp *MmdbProvider) Start(ctx context.Context) error {
+	log.Info().Msg("starting Mmdb Provider")
+
+	// a database that can't be fetched shouldn't keep the others from serving
+	err := mp.Refresh(ctx)
+	if err != nil && mp.Health(ctx).Ready {
+		return &utils.LoadError{Err: err}
+	}
+
+	return err
+}
+
+// mmdbSources returns the configured databases, with the file of downloaded
+// databases defaulting to providers.mmdb.path
+func mmdbSources() ([]*MmdbSource, error) {
+	var sources []*MmdbSource
+	if err := viper.UnmarshalKey("providers.mmdb.databases", &sources); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, source := range sources {
+		if source.Name == "" {
+			return nil, errors.New("mmdb databases need a name")
+		}
+		if names[source.Name] {
+			return nil, fmt.Errorf("mmdb database %s is defined more than once", source.Name)
+		}
+		names[source.Name] = true
+
+		if len(source.Fields) == 0 {
+			return nil, fmt.Errorf("mmdb database %s has no fields", source.Name)
+		}
+
+		if source.File == "" {
+			if source.URL == "" {
+				return nil, fmt.Errorf("mmdb database %s needs a url or a file", source.Name)
+			}
+			source.File = filepath.Join(viper.GetString("providers.mmdb.path"), source.Name+".mmdb")
+		}
+	}
+
+	return sources, nil
+}
+
+// mmdbFields returns the fields of source, checking they exist in IPInfo
+func mmdbFields(source *MmdbSource) ([]*mmdbField, error) {
+	var fields []*mmdbField
+	for name, record := range source.Fields {
+		if record == "" {
+			return nil, fmt.Errorf("mmdb database %s: no record path for %s", source.Name, name)
+		}
+
+		field := &mmdbField{
+			name:   name,
+			field:  strings.Split(name, "."),
+			record: strings.Split(record, "."),
+		}
+		if err := setIPInfoField(&utils.IPInfo{}, field.field, nil); err != nil {
+			return nil, fmt.Errorf("mmdb database %s: %w", source.Name, err)
+		}
+
+		fields = append(fields, field)
+	}
+
+	slices.SortFunc(fields, func(a, b *mmdbField) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	return fields, nil
+}
+
+// Refresh downloads the databases with a URL and reloads all of them.
+// Databases that fail keep their previous copy, if any
+func (mp *MmdbProvider) Refresh(ctx context.Context) error {
+	log.Info().Msg("refreshing Mmdb Provider")
+
+	sources, err := mmdbSources()
+	if err != nil {
+		return err
+	}
+
+	// mappings are checked before anything is downloaded
+	fields := make([][]*mmdbField, len(sources))
+	for i, source := range sources {
+		if fields[i], err = mmdbFields(source); err != nil {
+			return err
+		}
+	}
+
+	mp.RLock()
+	previous := map[string]*maxminddb.Reader{}
+	for _, database := range mp.databases {
+		previous[database.name] = database.db
+	}
+	mp.RUnlock()
+
+	var errs []error
+	kept := map[*maxminddb.Reader]bool{}
+	databases := make([]*mmdbDatabase, 0, len(sources))
+	infos := make([]*DatabaseInfo, 0, len(sources))
+	for i, source := range sources {
+		if source.URL != "" {
+			if err := mp.download(ctx, source); err != nil {
+				errs = append(errs, fmt.Errorf("failed to download mmdb database %s: %w", source.Name, err))
+			}
+		}
+
+		db, err := maxminddb.Open(source.File)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load mmdb database %s: %w", source.Name, err))
+			if db = previous[source.Name]; db == nil {
+				infos = append(infos, metadataDatabaseInfo(source.Name, source.File, nil))
+				continue
+			}
+		} else {
+			recordMmdbLoad("mmdb", source.Name, db)
+		}
+
+		kept[db] = true
+		databases = append(databases, &mmdbDatabase{
+			name:   source.Name,
+			db:     db,
+			fields: fields[i],
+		})
+		infos = append(infos, metadataDatabaseInfo(source.Name, source.File, &db.Metadata))
+	}
+
+	mp.Lock()
+	replaced := mp.databases
+	mp.databases = databases
+	mp.infos = infos
+	mp.Unlock()
+
+	for _, database := range replaced {
+		if !kept[database.db] {
+			database.db.Close()
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (mp *MmdbProvider) download(ctx context.Context, source *MmdbSource) error {
+	log.Info().Str("source", utils.RedactURL(source.URL)).Str("dest", source.File).Msg("downloading")
+
+	if err := os.MkdirAll(filepath.Dir(source.File), 0700); err != nil {
+		return err
+	}
+
+	return utils.DownloadFileWithProgress(ctx, source.URL, source.File)
+}
+
+// Lookup returns the fields of address in the databases, or nil when none of
+// them has it
+func (mp *MmdbProvider) Lookup(ctx context.Context, address string, asFallback bool) (*utils.IPInfo, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, &utils.IpAddressError{}
+	}
+
+	info := &utils.IPInfo{
+		Address:            address,
+		Source:             "mmdb",
+		IsFallback:         asFallback,
+		ASN:                &utils.ASN{},
+		Location:           &utils.Location{},
+		AnonymousIP:        &utils.AnonymousIP{},
+		City:               &utils.City{},
+		Continent:          &utils.Continent{},
+		Country:            &utils.Country{},
+		Postal:             &utils.Postal{},
+		RegisteredCountry:  &utils.Country{},
+		RepresentedCountry: &utils.Country{},
+		Subdivisions:       []*utils.Subdivision{},
+		Traits:             &utils.Traits{},
+	}
+	match := newNetworkMatch(ip)
+
+	mp.RLock()
+	defer mp.RUnlock()
+
+	found := false
+	answered := map[string]bool{}
+	for _, database := range mp.databases {
+		var record any
+		network, ok, err := database.db.LookupNetwork(ip, &record)
+		if err != nil {
+			return nil, err
+		}
+		match.add(network)
+
+		if ok {
+			found = true
+			database.read(record, info, answered)
+		}
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	info.Network = match.String()
+
+	return info, nil
+}
+
+// read sets the fields of info not answered yet from record
+func (md *mmdbDatabase) read(record any, info *utils.IPInfo, answered map[string]bool) {
+	for _, field := range md.fields {
+		if answered[field.name] {
+			continue
+		}
+
+		value, ok := mmdbRecordValue(record, field.record)
+		if !ok {
+			continue
+		}
+
+		if err := setIPInfoField(info, field.field, value); err != nil {
+			log.Warn().Err(err).Str("database", md.name).Str("address", info.Address).Msg("skipping mmdb field")
+			continue
+		}
+		answered[field.name] = true
+
+		switch field.field[0] {
+		case "city":
+			info.HasCity = true
+		case "asn":
+			info.HasASN = true
+		case "anonymous_ip":
+			info.HasAnonymousIP = true
+		}
+	}
+}
+
+// mmdbRecordValue returns the value at path in record, following map keys
+// and array indexes
+func mmdbRecordValue(record any, path []string) (any, bool) {
+	for _, name := range path {
+		switch value := record.(type) {
+		case map[string]any:
+			record = value[name]
+		case []any:
+			index, err := strconv.Atoi(name)
+			if err != nil || index < 0 || index >= len(value) {
+				return nil, false
+			}
+			record = value[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return record, record != nil
+}
+
+// setIPInfoField sets the field of info at path, made of the JSON names of
+// the IPInfo fields, to value. Missing structs are added on the way, and
+// subdivisions are indexed, the first one being used when no index is given.
+// A nil value only checks the field exists
+func setIPInfoField(info *utils.IPInfo, path []string, value any) error {
+	field := reflect.ValueOf(info).Elem()
+	for i := 0; i < len(path); {
+		name := path[i]
+		switch field.Kind() {
+		case reflect.Pointer:
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			field = field.Elem()
+		case reflect.Struct:
+			next, ok := jsonField(field, name)
+			// the address, source and flags of an answer aren't read from records
+			if !ok || (i == 0 && next.Kind() != reflect.Pointer && next.Kind() != reflect.Slice) {
+				return fmt.Errorf("unknown field %s", strings.Join(path, "."))
+			}
+			field = next
+			i++
+		case reflect.Slice:
+			index, err := strconv.Atoi(name)
+			if err == nil {
+				i++
+			}
+			if index < 0 || field.Type().Elem().Kind() != reflect.Pointer {
+				return fmt.Errorf("unknown field %s", strings.Join(path, "."))
+			}
+			for field.Len() <= index {
+				field.Set(reflect.Append(field, reflect.New(field.Type().Elem().Elem())))
+			}
+			field = field.Index(index)
+		case reflect.Map:
+			if i != len(path)-1 {
+				return fmt.Errorf("unknown field %s", strings.Join(path, "."))
+			}
+			if value == nil {
+				return nil
+			}
+
+			s, err := mmdbString(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", strings.Join(path, "."), err)
+			}
+			if field.IsNil() {
+				field.Set(reflect.MakeMap(field.Type()))
+			}
+			field.SetMapIndex(reflect.ValueOf(name), reflect.ValueOf(s))
+			return nil
+		default:
+			return fmt.Errorf("unknown field %s", strings.Join(path, "."))
+		}
+	}
+
+	if value == nil {
+		switch field.Kind() {
+		case reflect.String, reflect.Bool, reflect.Map, reflect.Float32, reflect.Float64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return nil
+		default:
+			return fmt.Errorf("%s isn't a value", strings.Join(path, "."))
+		}
+	}
+
+	if err := setMmdbValue(field, value); err != nil {
+		return fmt.Errorf("%s: %w", strings.Join(path, "."), err)
+	}
+
+	return nil
+}
+
+// jsonField returns the field of structure with the JSON name name
+func jsonField(structure reflect.Value, name string) (reflect.Value, bool) {
+	for i := 0; i < structure.NumField(); i++ {
+		tag, _, _ := strings.Cut(structure.Type().Field(i).Tag.Get("json"), ",")
+		if tag == name {
+			return structure.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// setMmdbValue sets field to value, converting between strings, numbers and
+// booleans. Numbers can be prefixed with AS, as ASNs often are. Maps of
+// names are copied as a whole
+func setMmdbValue(field reflect.Value, value any) error {
+	v := reflect.ValueOf(value)
+	switch field.Kind() {
+	case reflect.String:
+		s, err := mmdbString(value)
+		if err != nil {
+			return err
+		}
+		field.SetString(s)
+	case reflect.Bool:
+		switch v.Kind() {
+		case reflect.Bool:
+			field.SetBool(v.Bool())
+		case reflect.String:
+			b, err := strconv.ParseBool(v.String())
+			if err != nil {
+				return err
+			}
+			field.SetBool(b)
+		default:
+			n, err := mmdbFloat(value)
+			if err != nil {
+				return err
+			}
+			field.SetBool(n != 0)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := mmdbUint(value)
+		if err != nil {
+			return err
+		}
+		if field.OverflowUint(n) {
+			return fmt.Errorf("%d is out of range", n)
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := mmdbFloat(value)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case reflect.Map:
+		names, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("a %T isn't a map", value)
+		}
+		if field.IsNil() {
+			field.Set(reflect.MakeMap(field.Type()))
+		}
+		for key, name := range names {
+			s, err := mmdbString(name)
+			if err != nil {
+				return err
+			}
+			field.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(s))
+		}
+	default:
+		return fmt.Errorf("%s fields aren't supported", field.Kind())
+	}
+
+	return nil
+}
+
+func mmdbString(value any) (string, error) {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(value), nil
+	default:
+		return "", fmt.Errorf("a %T isn't a string", value)
+	}
+}
+
+func mmdbUint(value any) (uint64, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 {
+			return 0, fmt.Errorf("%d is out of range", v.Int())
+		}
+		return uint64(v.Int()), nil
+	case reflect.String:
+		s := strings.TrimSpace(v.String())
+		if len(s) > 2 && strings.EqualFold(s[:2], "AS") {
+			s = s[2:]
+		}
+		return strconv.ParseUint(s, 10, 64)
+	default:
+		return 0, fmt.Errorf("a %T isn't a number", value)
+	}
+}
+
+func mmdbFloat(value any) (float64, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.String:
+		return strconv.ParseFloat(strings.TrimSpace(v.String()), 64)
+	default:
+		return 0, fmt.Errorf("a %T isn't a number", value)
+	}
+}
+
+// Health reports the state of the databases. It is ready as soon as one of
+// them is loaded
+func (mp *MmdbProvider) Health(ctx context.Context) *Health {
+	mp.RLock()
+	defer mp.RUnlock()
+
+	return databaseHealth(mp.infos)
+}
+
+func (mp *MmdbProvider) Shutdown(ctx context.Context) {
+	mp.Lock()
+	defer mp.Unlock()
+
+	for _, database := range mp.databases {
+		database.db.Close()
+	}
+	mp.databases = nil
+}
@@ -0,0 +1,235 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/cloud66-oss/geo/utils"
+)
+
+// ipinfo style records, flat with ASNs as strings
+var testIpinfoRecords = map[string]mmdbtype.Map{
+	"8.8.8.0/24": {
+		"country":        mmdbtype.String("US"),
+		"country_name":   mmdbtype.String("United States"),
+		"continent":      mmdbtype.String("NA"),
+		"continent_name": mmdbtype.String("North America"),
+		"asn":            mmdbtype.String("AS15169"),
+		"as_name":        mmdbtype.String("Google LLC"),
+		"as_domain":      mmdbtype.String("google.com"),
+	},
+	"81.2.69.0/24": {
+		"country": mmdbtype.String("GB"),
+		"asn":     mmdbtype.String("not an ASN"),
+	},
+}
+
+// nested records, with arrays and numbers
+var testGeoRecords = map[string]mmdbtype.Map{
+	"8.8.0.0/16": {
+		"country": mmdbtype.Map{"iso_code": mmdbtype.String("CA")},
+		"city": mmdbtype.Map{"names": mmdbtype.Map{
+			"en": mmdbtype.String("Mountain View"),
+			"de": mmdbtype.String("Mountain View"),
+		}},
+		"location": mmdbtype.Map{
+			"latitude":  mmdbtype.Float64(37.386),
+			"longitude": mmdbtype.Float64(-122.0838),
+		},
+		"regions": mmdbtype.Slice{
+			mmdbtype.Map{"code": mmdbtype.String("CA"), "name": mmdbtype.String("California")},
+		},
+		"hosting": mmdbtype.Bool(true),
+	},
+}
+
+type mmdbProviderTestSuite struct {
+	suite.Suite
+	provider *MmdbProvider
+}
+
+func (suite *mmdbProviderTestSuite) SetupTest() {
+	viper.Set("providers.mmdb.path", suite.T().TempDir())
+	viper.Set("providers.mmdb.databases", []map[string]any{
+		{
+			"name": "ipinfo",
+			"file": writeTestMmdb(suite.T(), "ipinfo", testIpinfoRecords),
+			"fields": map[string]string{
+				"country.iso_code":                   "country",
+				"country.names.en":                   "country_name",
+				"continent.code":                     "continent",
+				"continent.names.en":                 "continent_name",
+				"asn.autonomous_system_number":       "asn",
+				"asn.autonomous_system_organization": "as_name",
+				"traits.domain":                      "as_domain",
+			},
+		},
+		{
+			"name": "geo",
+			"file": writeTestMmdb(suite.T(), "geo", testGeoRecords),
+			"fields": map[string]string{
+				"country.iso_code":                 "country.iso_code",
+				"city.names":                       "city.names",
+				"location.latitude":                "location.latitude",
+				"location.longitude":               "location.longitude",
+				"subdivisions.iso_code":            "regions.0.code",
+				"subdivisions.names.en":            "regions.0.name",
+				"anonymous_ip.is_hosting_provider": "hosting",
+			},
+		},
+	})
+
+	var err error
+	suite.provider, err = NewMmdbProvider(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.provider.Start(context.Background()))
+}
+
+func (suite *mmdbProviderTestSuite) TearDownTest() {
+	suite.provider.Shutdown(context.Background())
+	viper.Set("providers.mmdb.databases", nil)
+}
+
+func (suite *mmdbProviderTestSuite) lookup(address string) *utils.IPInfo {
+	info, err := suite.provider.Lookup(context.Background(), address, false)
+	suite.Require().NoError(err)
+
+	return info
+}
+
+func (suite *mmdbProviderTestSuite) TestLookup() {
+	info := suite.lookup("8.8.8.8")
+	suite.Require().NotNil(info)
+	suite.Equal("mmdb", info.Source)
+	// the first database answering a field wins
+	suite.Equal("US", info.Country.IsoCode)
+	suite.Equal("United States", info.Country.Names["en"])
+	suite.Equal("NA", info.Continent.Code)
+	suite.Equal("North America", info.Continent.Names["en"])
+	suite.True(info.HasASN)
+	suite.Equal(&utils.ASN{AutonomousSystemNumber: 15169, AutonomousSystemOrganization: "Google LLC"}, info.ASN)
+	suite.Equal("google.com", info.Traits.Domain)
+	suite.True(info.HasCity)
+	suite.Equal(map[string]string{"en": "Mountain View", "de": "Mountain View"}, info.City.Names)
+	suite.Equal(37.386, info.Location.Latitude)
+	suite.Equal(-122.0838, info.Location.Longitude)
+	suite.Require().Len(info.Subdivisions, 1)
+	suite.Equal("CA", info.Subdivisions[0].IsoCode)
+	suite.Equal("California", info.Subdivisions[0].Names["en"])
+	suite.True(info.HasAnonymousIP)
+	suite.True(info.AnonymousIP.IsHostingProvider)
+	// the most specific network of all databases
+	suite.Equal("8.8.8.0/24", info.Network)
+
+	info = suite.lookup("8.8.4.4")
+	suite.Require().NotNil(info)
+	suite.Equal("CA", info.Country.IsoCode)
+	suite.False(info.HasASN)
+	suite.NotEmpty(info.Network)
+	suite.NotEqual("8.8.0.0/16", info.Network)
+
+	// values that don't convert are skipped
+	info = suite.lookup("81.2.69.160")
+	suite.Require().NotNil(info)
+	suite.Equal("GB", info.Country.IsoCode)
+	suite.False(info.HasASN)
+	suite.False(info.HasCity)
+
+	suite.Nil(suite.lookup("1.1.1.1"))
+
+	_, err := suite.provider.Lookup(context.Background(), "nope", false)
+	suite.IsType(&utils.IpAddressError{}, err)
+}
+
+func (suite *mmdbProviderTestSuite) TestInvalidFields() {
+	for _, field := range []string{"country.nope", "address", "has_asn", "country", "subdivisions", "tags", "city.names.en.x"} {
+		viper.Set("providers.mmdb.databases", []map[string]any{
+			{"name": "ipinfo", "file": "ipinfo.mmdb", "fields": map[string]string{field: "country"}},
+		})
+		suite.Error(suite.provider.Refresh(context.Background()), field)
+	}
+
+	viper.Set("providers.mmdb.databases", []map[string]any{{"name": "ipinfo", "file": "ipinfo.mmdb"}})
+	suite.Error(suite.provider.Refresh(context.Background()))
+
+	// the databases loaded keep answering
+	suite.NotNil(suite.lookup("8.8.8.8"))
+}
+
+func (suite *mmdbProviderTestSuite) TestRefresh() {
+	file := filepath.Join(suite.T().TempDir(), "broken.mmdb")
+	suite.Require().NoError(os.WriteFile(file, []byte("not a database"), 0600))
+
+	viper.Set("providers.mmdb.databases", []map[string]any{
+		{"name": "ipinfo", "file": file, "fields": map[string]string{"country.iso_code": "country"}},
+		{"name": "missing", "file": filepath.Join(suite.T().TempDir(), "missing.mmdb"), "fields": map[string]string{"country.iso_code": "country"}},
+	})
+
+	// a database failing keeps its previous copy
+	suite.Error(suite.provider.Refresh(context.Background()))
+	info := suite.lookup("8.8.8.8")
+	suite.Require().NotNil(info)
+	suite.Equal("US", info.Country.IsoCode)
+	suite.Empty(info.Continent.Code)
+
+	health := suite.provider.Health(context.Background())
+	suite.True(health.Ready)
+	suite.Require().Len(health.Databases, 2)
+	suite.Equal("ipinfo", health.Databases[0].DatabaseType)
+	suite.False(health.Databases[1].Loaded)
+}
+
+func (suite *mmdbProviderTestSuite) TestStartLoadError() {
+	viper.Set("providers.mmdb.databases", []map[string]any{
+		{"name": "ipinfo", "file": writeTestMmdb(suite.T(), "ipinfo", testIpinfoRecords), "fields": map[string]string{"country.iso_code": "country"}},
+		{"name": "missing", "file": filepath.Join(suite.T().TempDir(), "missing.mmdb"), "fields": map[string]string{"country.iso_code": "country"}},
+	})
+
+	// the provider serves the databases it loaded and reports the others
+	provider, err := NewMmdbProvider(context.Background())
+	suite.Require().NoError(err)
+	defer provider.Shutdown(context.Background())
+	err = provider.Start(context.Background())
+	suite.IsType(&utils.LoadError{}, err)
+	suite.True(provider.Health(context.Background()).Ready)
+}
+
+func (suite *mmdbProviderTestSuite) TestDownload() {
+	database := writeTestMmdb(suite.T(), "asn", map[string]mmdbtype.Map{
+		"1.1.1.0/24": {"as_number": mmdbtype.Uint32(13335), "as_description": mmdbtype.String("CLOUDFLARENET")},
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeFile(w, r, database)
+	}))
+	defer server.Close()
+
+	viper.Set("providers.mmdb.databases", []map[string]any{
+		{"name": "iptoasn", "url": server.URL, "fields": map[string]string{
+			"asn.autonomous_system_number":       "as_number",
+			"asn.autonomous_system_organization": "as_description",
+		}},
+	})
+
+	suite.Require().NoError(suite.provider.Refresh(context.Background()))
+	suite.FileExists(filepath.Join(viper.GetString("providers.mmdb.path"), "iptoasn.mmdb"))
+
+	info := suite.lookup("1.1.1.1")
+	suite.Require().NotNil(info)
+	suite.Equal(&utils.ASN{AutonomousSystemNumber: 13335, AutonomousSystemOrganization: "CLOUDFLARENET"}, info.ASN)
+	suite.Equal("1.1.1.0/24", info.Network)
+	suite.Nil(suite.lookup("8.8.8.8"))
+}
+
+func TestMmdbProviderTestSuite(t *testing.T) {
+	suite.Run(t, new(mmdbProviderTestSuite))
+}
@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/cloud66-oss/geo/utils"
+)
+
+// networkMatch narrows down the network an answer holds for as the databases
+// it is built from are queried. Every database matches the address in one of
+// its networks, and as they all contain the address the answer holds for the
+// most specific of them
+type networkMatch struct {
+	addr    netip.Addr
+	prefix  netip.Prefix
+	unknown bool
+}
+
+func newNetworkMatch(ip net.IP) *networkMatch {
+	addr, _ := netip.AddrFromSlice(ip)
+	return &networkMatch{addr: addr.Unmap()}
+}
+
+// add narrows the match to network, the network a database matched the
+// address in
+func (nm *networkMatch) add(network *net.IPNet) {
+	if network == nil {
+		nm.unknown = true
+		return
+	}
+
+	addr, ok := netip.AddrFromSlice(network.IP)
+	ones, _ := network.Mask.Size()
+	prefix := netip.PrefixFrom(addr, ones)
+	if addr.Is4In6() && ones >= 96 {
+		prefix = netip.PrefixFrom(addr.Unmap(), ones-96)
+	}
+
+	// some databases report the IPv4 subtree as an IPv6 network, which
+	// doesn't say anything about the neighbours of the address
+	if !ok || !prefix.Contains(nm.addr) {
+		nm.unknown = true
+		return
+	}
+
+	if !nm.prefix.IsValid() || prefix.Bits() > nm.prefix.Bits() {
+		nm.prefix = prefix.Masked()
+	}
+}
+
+// String returns the network the answer holds for, or an empty string when
+// it isn't known
+func (nm *networkMatch) String() string {
+	if nm.unknown || !nm.prefix.IsValid() {
+		return ""
+	}
+
+	return nm.prefix.String()
+}
+
+// nestedPrefixes returns the prefixes of table with more specific prefixes
+// inside them. An answer from one of them doesn't hold for its whole network
+func nestedPrefixes[V any](table *utils.PrefixTable[V]) map[netip.Prefix]bool {
+	nested := map[netip.Prefix]bool{}
+	table.Range(func(prefix netip.Prefix, _ V) bool {
+		for bits := prefix.Bits() - 1; bits >= 0; bits-- {
+			parent, _ := prefix.Addr().Prefix(bits)
+			if _, ok := table.Get(parent); ok {
+				nested[parent] = true
+			}
+		}
+		return true
+	})
+
+	return nested
+}
+
+// tablePrefixes returns the prefixes of table
+func tablePrefixes[V any](table *utils.PrefixTable[V]) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, table.Len())
+	table.Range(func(prefix netip.Prefix, _ V) bool {
+		prefixes = append(prefixes, prefix)
+		return true
+	})
+
+	return prefixes
+}
+
+// prefixStarts are the sorted first addresses of a set of prefixes, to tell
+// whether a network has any of them inside it
+type prefixStarts []netip.Addr
+
+func newPrefixStarts(prefixes []netip.Prefix) prefixStarts {
+	starts := make(prefixStarts, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		starts = append(starts, prefix.Masked().Addr())
+	}
+	slices.SortFunc(starts, netip.Addr.Compare)
+
+	return starts
+}
+
+// within tells whether one of the prefixes starts inside network. As
+// prefixes are either nested or apart, the ones starting before network
+// contain all of it
+func (ps prefixStarts) within(network netip.Prefix) bool {
+	i, _ := slices.BinarySearchFunc(ps, network.Masked().Addr(), netip.Addr.Compare)
+
+	return i < len(ps) && network.Contains(ps[i])
+}
+
+// patchedNetwork returns the network an answer for network still holds for
+// once patched with a match in matched, the network a patch matched the
+// address in, if any. specific tells whether matched has other matches
+// inside it. It is empty when the patch may answer differently for other
+// addresses of the network
+func patchedNetwork(network string, matched netip.Prefix, specific bool, starts prefixStarts) string {
+	answer, err := netip.ParsePrefix(network)
+	if err != nil {
+		return ""
+	}
+
+	if !matched.IsValid() {
+		if starts.within(answer) {
+			return ""
+		}
+		return network
+	}
+
+	if !specific {
+		return ""
+	}
+	if matched.Bits() > answer.Bits() {
+		return matched.String()
+	}
+
+	return network
+}
+
+// readNetworks reads a network or address per line, as plain text blocklists
+// and range files are published. Blank lines and comments starting with # or
+// ; are skipped, as is anything after the network on its line
+func readNetworks(r io.Reader) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line, _, _ = strings.Cut(line, ";")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(fields[0]); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			log.Debug().Str("network", fields[0]).Msg("skipping invalid network")
+			continue
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, scanner.Err()
+}
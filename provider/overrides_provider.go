@@ -0,0 +1,676 @@
+package provider
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+
+	"github.com/cloud66-oss/geo/utils"
+)
+
+// Override is the information configured for a network in the overrides
+// file. Empty fields are left to the provider it patches
+type Override struct {
+	Network      string   `mapstructure:"network" json:"network"`
+	Country      string   `mapstructure:"country" json:"country,omitempty"`
+	City         string   `mapstructure:"city" json:"city,omitempty"`
+	Latitude     *float64 `mapstructure:"latitude" json:"latitude,omitempty"`
+	Longitude    *float64 `mapstructure:"longitude" json:"longitude,omitempty"`
+	ASN          uint     `mapstructure:"asn" json:"asn,omitempty"`
+	Organization string   `mapstructure:"organization" json:"organization,omitempty"`
+	Tags         []string `mapstructure:"tags" json:"tags,omitempty"`
+}
+
+// overrideEntry is an override in the lookup table. specific is set when no
+// other override is more specific than it, so its answer holds for its whole
+// network. stored is set for overrides from the store
+type overrideEntry struct {
+	override *Override
+	specific bool
+	stored   bool
+}
+
+// OverridesProvider answers from a file of networks with hand maintained
+// information, such as office and VPN ranges. It can be a cascade member or
+// patch the answers of other providers. Overrides edited at runtime are kept
+// in a store file and take precedence over the ones of the file
+type OverridesProvider struct {
+	sync.RWMutex
+	table    *utils.PrefixTable[*overrideEntry]
+	starts   prefixStarts
+	file     []*Override
+	stored   map[netip.Prefix]*Override
+	loadedAt time.Time
+	watcher  *fsnotify.Watcher
+	// edits serializes the changes to the overrides
+	edits sync.Mutex
+}
+
+func NewOverridesProvider(ctx context.Context) (*OverridesProvider, error) {
+	return &OverridesProvider{
+		table:  utils.NewPrefixTable[*overrideEntry](),
+		stored: map[netip.Prefix]*Override{},
+	}, nil
+}
+
+func (op *OverridesProvider) Start(ctx context.Context) error {
+	log.Info().Msg("starting Overrides Provider")
+
+	if err := op.Refresh(ctx); err != nil {
+		return err
+	}
+
+	if viper.GetBool("providers.overrides.watch") {
+		return op.watch(viper.GetString("providers.overrides.file"))
+	}
+
+	return nil
+}
+
+// Refresh reloads the overrides file and the store. When a store is set, a
+// missing file has no overrides so the store can be used on its own
+func (op *OverridesProvider) Refresh(ctx context.Context) error {
+	file := viper.GetString("providers.overrides.file")
+	store := viper.GetString("providers.overrides.store")
+
+	// the store is read under the lock so an edit saved meanwhile isn't lost
+	op.edits.Lock()
+	defer op.edits.Unlock()
+
+	var overrides []*Override
+	var loadedAt time.Time
+	if utils.FileExists(file) {
+		stat, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+
+		overrides, err = ReadOverrides(file)
+		if err != nil {
+			return err
+		}
+		loadedAt = stat.ModTime()
+	} else if store == "" {
+		return fmt.Errorf("file not found %s", file)
+	} else {
+		loadedAt = storeModTime(store)
+	}
+
+	stored, err := readOverridesStore(store)
+	if err != nil {
+		return err
+	}
+
+	if err := op.load(overrides, stored); err != nil {
+		return err
+	}
+
+	op.Lock()
+	op.loadedAt = loadedAt
+	op.Unlock()
+
+	utils.RecordDatabaseLoad("overrides", "file", uint(loadedAt.Unix()))
+	log.Info().Str("file", file).Int("overrides", len(overrides)).Int("stored", len(stored)).Msg("loaded overrides")
+
+	return nil
+}
+
+// load replaces the overrides with the ones of the file and the store
+func (op *OverridesProvider) load(file []*Override, stored map[netip.Prefix]*Override) error {
+	table, err := newOverridesTable(file, stored)
+	if err != nil {
+		return err
+	}
+
+	starts := newPrefixStarts(tablePrefixes(table))
+
+	op.Lock()
+	op.table = table
+	op.starts = starts
+	op.file = file
+	op.stored = stored
+	op.Unlock()
+
+	return nil
+}
+
+// Get returns the override of exactly network and whether it comes from the
+// store, or nil when there isn't any
+func (op *OverridesProvider) Get(network netip.Prefix) (*Override, bool) {
+	op.RLock()
+	defer op.RUnlock()
+
+	entry, ok := op.table.Get(network)
+	if !ok {
+		return nil, false
+	}
+
+	return entry.override, entry.stored
+}
+
+// Put saves override in the store, where it takes precedence over the file.
+// It returns the override it replaces, if any
+func (op *OverridesProvider) Put(ctx context.Context, override *Override) (*Override, error) {
+	network, err := override.Prefix()
+	if err != nil {
+		return nil, err
+	}
+	override.Network = network.String()
+
+	op.edits.Lock()
+	defer op.edits.Unlock()
+
+	previous, _ := op.Get(network)
+
+	op.RLock()
+	file, stored := op.file, maps.Clone(op.stored)
+	op.RUnlock()
+
+	stored[network] = override
+	if err := op.save(file, stored); err != nil {
+		return nil, err
+	}
+
+	return previous, nil
+}
+
+// Delete removes the override of network from the store, which brings back
+// the override of the file if there is one. It returns the removed override,
+// or nil when the store had none
+func (op *OverridesProvider) Delete(ctx context.Context, network netip.Prefix) (*Override, error) {
+	network = network.Masked()
+
+	op.edits.Lock()
+	defer op.edits.Unlock()
+
+	op.RLock()
+	file, stored := op.file, maps.Clone(op.stored)
+	op.RUnlock()
+
+	previous, ok := stored[network]
+	if !ok {
+		return nil, nil
+	}
+
+	delete(stored, network)
+	if err := op.save(file, stored); err != nil {
+		return nil, err
+	}
+
+	return previous, nil
+}
+
+// save writes stored to the store file and applies it
+func (op *OverridesProvider) save(file []*Override, stored map[netip.Prefix]*Override) error {
+	// the table is built first so the store never holds overrides that
+	// can't be loaded
+	table, err := newOverridesTable(file, stored)
+	if err != nil {
+		return err
+	}
+
+	if err := writeOverridesStore(viper.GetString("providers.overrides.store"), stored); err != nil {
+		return err
+	}
+
+	starts := newPrefixStarts(tablePrefixes(table))
+
+	op.Lock()
+	op.table = table
+	op.starts = starts
+	op.stored = stored
+	op.Unlock()
+
+	return nil
+}
+
+// watch reloads the overrides when file changes. The directory is watched as
+// editors and config management usually replace the file instead of writing
+// to it
+func (op *OverridesProvider) watch(file string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		return err
+	}
+	op.watcher = watcher
+
+	target := filepath.Clean(file)
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != target || !event.Has(fsnotify.Write|fsnotify.Create) {
+					continue
+				}
+
+				log.Info().Str("file", event.Name).Msg("reloading overrides")
+				if err := op.Refresh(context.Background()); err != nil {
+					log.Error().Err(err).Msg("failed to reload overrides, keeping the previous ones")
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Error().Err(err).Str("file", file).Msg("failed to watch overrides file")
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (op *OverridesProvider) match(address string) (netip.Prefix, *overrideEntry, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Prefix{}, nil, &utils.IpAddressError{}
+	}
+
+	op.RLock()
+	defer op.RUnlock()
+
+	prefix, entry, _ := op.table.Lookup(addr)
+
+	return prefix, entry, nil
+}
+
+// Lookup returns the override of the most specific network containing
+// address, or nil when there isn't any
+func (op *OverridesProvider) Lookup(ctx context.Context, address string, asFallback bool) (*utils.IPInfo, error) {
+	prefix, entry, err := op.match(address)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	info := &utils.IPInfo{
+		Address:            address,
+		Source:             "overrides",
+		IsFallback:         asFallback,
+		ASN:                &utils.ASN{},
+		Location:           &utils.Location{},
+		AnonymousIP:        &utils.AnonymousIP{},
+		City:               &utils.City{},
+		Continent:          &utils.Continent{},
+		Country:            &utils.Country{},
+		Postal:             &utils.Postal{},
+		RegisteredCountry:  &utils.Country{},
+		RepresentedCountry: &utils.Country{},
+		Subdivisions:       []*utils.Subdivision{},
+		Traits:             &utils.Traits{},
+	}
+	entry.override.apply(info)
+
+	if entry.specific {
+		info.Network = prefix.String()
+	}
+
+	return info, nil
+}
+
+// Patch returns a copy of info with the override of its address applied on
+// top, or info itself when there isn't any
+func (op *OverridesProvider) Patch(ctx context.Context, info *utils.IPInfo) *utils.IPInfo {
+	if info == nil {
+		return nil
+	}
+
+	prefix, entry, err := op.match(info.Address)
+	if err != nil {
+		return info
+	}
+
+	op.RLock()
+	starts := op.starts
+	op.RUnlock()
+
+	if entry == nil {
+		network := patchedNetwork(info.Network, netip.Prefix{}, false, starts)
+		if network == info.Network {
+			return info
+		}
+
+		// neighbours of the address in its network are overridden
+		patched := *info
+		patched.Network = network
+		return &patched
+	}
+
+	patched := *info
+	entry.override.apply(&patched)
+	patched.Network = patchedNetwork(info.Network, prefix, entry.specific, starts)
+
+	return &patched
+}
+
+// apply sets the fields of the override on info. Structs are replaced rather
+// than updated as info may be shared
+func (o *Override) apply(info *utils.IPInfo) {
+	if o.Country != "" {
+		info.Country = &utils.Country{IsoCode: o.Country}
+	}
+
+	if o.City != "" {
+		info.City = &utils.City{Names: map[string]string{"en": o.City}}
+		info.HasCity = true
+		// whatever the provider knew about the city no longer applies
+		info.Postal = &utils.Postal{}
+		info.Subdivisions = []*utils.Subdivision{}
+	}
+
+	if o.Latitude != nil && o.Longitude != nil {
+		location := utils.Location{}
+		if info.Location != nil {
+			location = *info.Location
+		}
+		location.Latitude = *o.Latitude
+		location.Longitude = *o.Longitude
+		location.AccuracyRadius = 0
+		info.Location = &location
+	}
+
+	if o.ASN != 0 {
+		info.ASN = &utils.ASN{
+			AutonomousSystemNumber:       o.ASN,
+			AutonomousSystemOrganization: o.Organization,
+		}
+		info.HasASN = true
+	}
+
+	if len(o.Tags) > 0 {
+		tags := slices.Clone(info.Tags)
+		for _, tag := range o.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		info.Tags = tags
+	}
+}
+
+// readOverridesStore reads the overrides saved in the store. A missing store
+// has none
+func readOverridesStore(path string) (map[netip.Prefix]*Override, error) {
+	stored := map[netip.Prefix]*Override{}
+	if path == "" || !utils.FileExists(path) {
+		return stored, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides []*Override
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to read overrides store %s: %w", path, err)
+	}
+
+	for _, override := range overrides {
+		network, err := override.Prefix()
+		if err != nil {
+			return nil, err
+		}
+		stored[network] = override
+	}
+
+	return stored, nil
+}
+
+// storeModTime is when the store was last written, or now when it doesn't
+// exist yet
+func storeModTime(path string) time.Time {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return time.Now()
+	}
+
+	return stat.ModTime()
+}
+
+func writeOverridesStore(path string, stored map[netip.Prefix]*Override) error {
+	if path == "" {
+		return errors.New("no overrides store configured. Use providers.overrides.store to define it")
+	}
+
+	overrides := slices.SortedFunc(maps.Values(stored), func(a *Override, b *Override) int {
+		return strings.Compare(a.Network, b.Network)
+	})
+	data, err := json.MarshalIndent(overrides, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// Health reports the state of the overrides file
+func (op *OverridesProvider) Health(ctx context.Context) *Health {
+	op.RLock()
+	defer op.RUnlock()
+
+	database := &DatabaseInfo{
+		Name:   "file",
+		Path:   viper.GetString("providers.overrides.file"),
+		Loaded: !op.loadedAt.IsZero(),
+	}
+	if database.Loaded {
+		database.DatabaseType = "overrides"
+		database.BuildTime = op.loadedAt.UTC()
+	}
+
+	return databaseHealth([]*DatabaseInfo{database})
+}
+
+func (op *OverridesProvider) Shutdown(ctx context.Context) {
+	if op.watcher != nil {
+		op.watcher.Close()
+	}
+}
+
+// ReadOverrides reads the overrides in file. CSV files need a header naming
+// their columns, with tags separated by semicolons. Other files are read as
+// configuration files with an overrides list
+func ReadOverrides(file string) ([]*Override, error) {
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		return readOverridesCsv(file)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	var overrides []*Override
+	if err := v.UnmarshalKey("overrides", &overrides); err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
+
+func readOverridesCsv(file string) ([]*Override, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the header of %s: %w", file, err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	if !slices.Contains(header, "network") {
+		return nil, fmt.Errorf("%s has no network column", file)
+	}
+
+	var overrides []*Override
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		override := &Override{}
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if i >= len(header) || value == "" {
+				continue
+			}
+
+			if err := override.set(header[i], value); err != nil {
+				line, _ := reader.FieldPos(i)
+				return nil, fmt.Errorf("%s line %d: %w", file, line, err)
+			}
+		}
+		overrides = append(overrides, override)
+	}
+
+	return overrides, nil
+}
+
+// set sets the field of a CSV column
+func (o *Override) set(column string, value string) error {
+	switch column {
+	case "network":
+		o.Network = value
+	case "country":
+		o.Country = value
+	case "city":
+		o.City = value
+	case "latitude", "longitude":
+		coordinate, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %s", column, value)
+		}
+		if column == "latitude" {
+			o.Latitude = &coordinate
+		} else {
+			o.Longitude = &coordinate
+		}
+	case "asn":
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(value), "AS"), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid asn %s", value)
+		}
+		o.ASN = uint(asn)
+	case "organization":
+		o.Organization = value
+	case "tags":
+		for _, tag := range strings.Split(value, ";") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				o.Tags = append(o.Tags, tag)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParseOverrideNetwork parses the network of an override. A single address
+// is taken as a network of its own
+func ParseOverrideNetwork(network string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(network)
+	if err != nil {
+		addr, addrErr := netip.ParseAddr(network)
+		if addrErr != nil || addr.Zone() != "" {
+			return netip.Prefix{}, fmt.Errorf("invalid network %q", network)
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	return prefix.Masked(), nil
+}
+
+// Prefix validates the override and returns its network
+func (o *Override) Prefix() (netip.Prefix, error) {
+	prefix, err := ParseOverrideNetwork(o.Network)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	if o.Country != "" && len(o.Country) != 2 {
+		return netip.Prefix{}, fmt.Errorf("invalid country %q for %s", o.Country, o.Network)
+	}
+	if (o.Latitude == nil) != (o.Longitude == nil) {
+		return netip.Prefix{}, fmt.Errorf("latitude and longitude of %s need to be set together", o.Network)
+	}
+	if o.Latitude != nil && (*o.Latitude < -90 || *o.Latitude > 90 || *o.Longitude < -180 || *o.Longitude > 180) {
+		return netip.Prefix{}, fmt.Errorf("invalid location for %s", o.Network)
+	}
+
+	o.Country = strings.ToUpper(o.Country)
+
+	return prefix, nil
+}
+
+// newOverridesTable indexes the overrides of the file and the store by
+// network
+func newOverridesTable(file []*Override, stored map[netip.Prefix]*Override) (*utils.PrefixTable[*overrideEntry], error) {
+	table := utils.NewPrefixTable[*overrideEntry]()
+	for _, override := range file {
+		prefix, err := override.Prefix()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := table.Get(prefix); ok {
+			return nil, fmt.Errorf("network %s is overridden more than once", prefix)
+		}
+
+		table.Insert(prefix, &overrideEntry{override: override, specific: true})
+	}
+
+	for prefix, override := range stored {
+		table.Insert(prefix, &overrideEntry{override: override, specific: true, stored: true})
+	}
+
+	for prefix := range nestedPrefixes(table) {
+		entry, _ := table.Get(prefix)
+		entry.specific = false
+	}
+
+	return table, nil
+}
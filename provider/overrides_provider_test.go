@@ -0,0 +1,251 @@
+package provider
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloud66-oss/geo/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+const testOverrides = `overrides:
+  - network: 10.0.0.0/8
+    country: gb
+    city: London
+    latitude: 51.5
+    longitude: -0.12
+    tags: [internal]
+  - network: 10.1.0.0/16
+    city: Manchester
+    asn: 64512
+    organization: Example VPN
+    tags: [vpn]
+  - network: 2001:db8::1
+    country: DE
+`
+
+type overridesProviderTestSuite struct {
+	suite.Suite
+	file     string
+	provider *OverridesProvider
+}
+
+func (suite *overridesProviderTestSuite) SetupTest() {
+	suite.file = filepath.Join(suite.T().TempDir(), "overrides.yml")
+	suite.write(suite.file, testOverrides)
+
+	viper.Set("providers.overrides.file", suite.file)
+	viper.Set("providers.overrides.store", filepath.Join(suite.T().TempDir(), "store.json"))
+	viper.Set("providers.overrides.watch", false)
+
+	suite.provider = suite.start()
+}
+
+func (suite *overridesProviderTestSuite) TearDownTest() {
+	suite.provider.Shutdown(context.Background())
+}
+
+func (suite *overridesProviderTestSuite) write(file string, content string) {
+	suite.Require().NoError(os.WriteFile(file, []byte(content), 0600))
+}
+
+func (suite *overridesProviderTestSuite) start() *OverridesProvider {
+	provider, err := NewOverridesProvider(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().NoError(provider.Start(context.Background()))
+
+	return provider
+}
+
+func (suite *overridesProviderTestSuite) lookup(address string) *utils.IPInfo {
+	info, err := suite.provider.Lookup(context.Background(), address, false)
+	suite.Require().NoError(err)
+
+	return info
+}
+
+func (suite *overridesProviderTestSuite) TestLookup() {
+	info := suite.lookup("10.2.3.4")
+	suite.Require().NotNil(info)
+	suite.Equal("overrides", info.Source)
+	suite.Equal("GB", info.Country.IsoCode)
+	suite.Equal("London", info.City.Names["en"])
+	suite.True(info.HasCity)
+	suite.Equal(51.5, info.Location.Latitude)
+	suite.Equal([]string{"internal"}, info.Tags)
+	suite.False(info.HasASN)
+
+	info = suite.lookup("2001:db8::1")
+	suite.Require().NotNil(info)
+	suite.Equal("DE", info.Country.IsoCode)
+	suite.Equal("2001:db8::1/128", info.Network)
+
+	suite.Nil(suite.lookup("1.1.1.1"))
+
+	_, err := suite.provider.Lookup(context.Background(), "not an address", false)
+	suite.IsType(&utils.IpAddressError{}, err)
+}
+
+func (suite *overridesProviderTestSuite) TestLongestPrefixMatch() {
+	info := suite.lookup("10.1.2.3")
+	suite.Require().NotNil(info)
+	suite.Equal("Manchester", info.City.Names["en"])
+	suite.EqualValues(64512, info.ASN.AutonomousSystemNumber)
+	suite.Equal("10.1.0.0/16", info.Network)
+
+	// 10.0.0.0/8 has a more specific override so it doesn't hold for all of it
+	suite.Empty(suite.lookup("10.2.3.4").Network)
+}
+
+func (suite *overridesProviderTestSuite) TestPatch() {
+	info := &utils.IPInfo{
+		Address: "10.1.2.3",
+		Network: "10.0.0.0/8",
+		Source:  "maxmind",
+		Country: &utils.Country{IsoCode: "US"},
+		City:    &utils.City{Names: map[string]string{"en": "Ashburn"}},
+		Postal:  &utils.Postal{Code: "20147"},
+		ASN:     &utils.ASN{AutonomousSystemNumber: 1},
+		Tags:    []string{"vpn"},
+	}
+
+	patched := suite.provider.Patch(context.Background(), info)
+	suite.Equal("maxmind", patched.Source)
+	suite.Equal("US", patched.Country.IsoCode)
+	suite.Equal("Manchester", patched.City.Names["en"])
+	suite.Empty(patched.Postal.Code)
+	suite.EqualValues(64512, patched.ASN.AutonomousSystemNumber)
+	suite.Equal([]string{"vpn"}, patched.Tags)
+	suite.Equal("10.1.0.0/16", patched.Network)
+
+	// the original is left alone
+	suite.Equal("Ashburn", info.City.Names["en"])
+	suite.EqualValues(1, info.ASN.AutonomousSystemNumber)
+
+	other := &utils.IPInfo{Address: "1.1.1.1"}
+	suite.Same(other, suite.provider.Patch(context.Background(), other))
+
+	// the answer doesn't hold for the overridden neighbours of the address
+	// in its network, nor for the whole of 10.0.0.0/8 which has overrides in it
+	suite.Empty(suite.provider.Patch(context.Background(), &utils.IPInfo{Address: "2001:db8::2", Network: "2001:db8::/32"}).Network)
+	suite.Empty(suite.provider.Patch(context.Background(), &utils.IPInfo{Address: "10.2.0.1", Network: "10.0.0.0/8"}).Network)
+}
+
+func (suite *overridesProviderTestSuite) TestCsv() {
+	file := filepath.Join(suite.T().TempDir(), "overrides.csv")
+	suite.write(file, "network,country,city,latitude,longitude,asn,organization,tags\n"+
+		"# offices\n"+
+		"192.168.0.0/16,FR,Paris,48.85,2.35,AS64513,Example,office;wifi\n"+
+		"192.168.1.0/24,,,,,,,printers\n")
+	viper.Set("providers.overrides.file", file)
+	suite.Require().NoError(suite.provider.Refresh(context.Background()))
+
+	info := suite.lookup("192.168.1.10")
+	suite.Require().NotNil(info)
+	suite.Empty(info.Country.IsoCode)
+	suite.Equal([]string{"printers"}, info.Tags)
+
+	info = suite.lookup("192.168.2.10")
+	suite.Require().NotNil(info)
+	suite.Equal("FR", info.Country.IsoCode)
+	suite.Equal(2.35, info.Location.Longitude)
+	suite.EqualValues(64513, info.ASN.AutonomousSystemNumber)
+	suite.Equal([]string{"office", "wifi"}, info.Tags)
+
+	suite.Nil(suite.lookup("10.1.2.3"))
+}
+
+func (suite *overridesProviderTestSuite) TestInvalid() {
+	for _, content := range []string{
+		"overrides:\n  - network: 10.0.0.0/33\n",
+		"overrides:\n  - network: 10.0.0.0/8\n    latitude: 10\n",
+		"overrides:\n  - network: 10.0.0.0/8\n  - network: 10.1.2.3/8\n",
+	} {
+		suite.write(suite.file, content)
+		suite.Error(suite.provider.Refresh(context.Background()), content)
+	}
+
+	// the previous overrides are kept
+	suite.NotNil(suite.lookup("10.1.2.3"))
+}
+
+func (suite *overridesProviderTestSuite) TestWatch() {
+	viper.Set("providers.overrides.watch", true)
+	suite.provider.Shutdown(context.Background())
+	suite.provider = suite.start()
+
+	suite.write(suite.file, "overrides:\n  - network: 1.1.1.0/24\n    country: AU\n")
+	suite.Eventually(func() bool {
+		return suite.lookup("1.1.1.1") != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	suite.Nil(suite.lookup("10.1.2.3"))
+}
+
+func (suite *overridesProviderTestSuite) TestStore() {
+	ctx := context.Background()
+	network := netip.MustParsePrefix("10.1.0.0/16")
+
+	previous, err := suite.provider.Put(ctx, &Override{Network: "10.1.2.3/16", Country: "ie", City: "Dublin"})
+	suite.Require().NoError(err)
+	suite.Equal("Manchester", previous.City)
+
+	override, stored := suite.provider.Get(network)
+	suite.True(stored)
+	suite.Equal("IE", override.Country)
+	suite.Equal("Dublin", suite.lookup("10.1.2.3").City.Names["en"])
+
+	// stored overrides survive a restart and win over the file
+	suite.provider.Shutdown(ctx)
+	suite.provider = suite.start()
+	suite.Equal("Dublin", suite.lookup("10.1.2.3").City.Names["en"])
+
+	_, err = suite.provider.Put(ctx, &Override{Network: "10.1.0.0/16", Latitude: new(float64)})
+	suite.Error(err)
+
+	previous, err = suite.provider.Delete(ctx, network)
+	suite.Require().NoError(err)
+	suite.Equal("Dublin", previous.City)
+	suite.Equal("Manchester", suite.lookup("10.1.2.3").City.Names["en"])
+
+	// the file's overrides can't be deleted
+	previous, err = suite.provider.Delete(ctx, network)
+	suite.Require().NoError(err)
+	suite.Nil(previous)
+	override, stored = suite.provider.Get(network)
+	suite.False(stored)
+	suite.Equal("Manchester", override.City)
+}
+
+func (suite *overridesProviderTestSuite) TestMissingFile() {
+	ctx := context.Background()
+	viper.Set("providers.overrides.file", filepath.Join(suite.T().TempDir(), "missing.yml"))
+	defer viper.Set("providers.overrides.file", suite.file)
+
+	// with a store, a missing file has no overrides
+	suite.provider.Shutdown(ctx)
+	suite.provider = suite.start()
+	suite.Nil(suite.lookup("10.1.2.3"))
+	suite.True(suite.provider.Health(ctx).Ready)
+
+	_, err := suite.provider.Put(ctx, &Override{Network: "10.1.0.0/16", Country: "IE"})
+	suite.Require().NoError(err)
+	suite.Equal("IE", suite.lookup("10.1.2.3").Country.IsoCode)
+
+	viper.Set("providers.overrides.store", "")
+	suite.Error(suite.provider.Refresh(ctx))
+}
+
+func (suite *overridesProviderTestSuite) TestHealth() {
+	health := suite.provider.Health(context.Background())
+	suite.True(health.Ready)
+	suite.Equal(suite.file, health.Databases[0].Path)
+}
+
+func TestOverridesProviderTestSuite(t *testing.T) {
+	suite.Run(t, new(overridesProviderTestSuite))
+}
@@ -0,0 +1,134 @@
+package rpc
+
+import (
+	"github.com/cloud66-oss/geo/utils"
+)
+
+// FromIPInfo converts a utils.IPInfo into its protobuf counterpart
+func FromIPInfo(info *utils.IPInfo) *IPInfo {
+	if info == nil {
+		return nil
+	}
+
+	result := &IPInfo{
+		Address:            info.Address,
+		OriginalAddress:    info.OriginalAddress,
+		Network:            info.Network,
+		Source:             info.Source,
+		IsFallback:         info.IsFallback,
+		HasCity:            info.HasCity,
+		Continent:          fromContinent(info.Continent),
+		Country:            fromCountry(info.Country),
+		RegisteredCountry:  fromCountry(info.RegisteredCountry),
+		RepresentedCountry: fromCountry(info.RepresentedCountry),
+		HasAsn:             info.HasASN,
+		HasAnonymousIp:     info.HasAnonymousIP,
+		Tags:               info.Tags,
+	}
+
+	if info.City != nil {
+		result.City = &City{
+			GeonameId: uint32(info.City.GeoNameID),
+			Names:     info.City.Names,
+		}
+	}
+
+	if info.Location != nil {
+		result.Location = &Location{
+			AccuracyRadius: uint32(info.Location.AccuracyRadius),
+			Latitude:       info.Location.Latitude,
+			Longitude:      info.Location.Longitude,
+			MetroCode:      uint32(info.Location.MetroCode),
+			TimeZone:       info.Location.TimeZone,
+		}
+	}
+
+	if info.Postal != nil {
+		result.Postal = &Postal{
+			Code: info.Postal.Code,
+		}
+	}
+
+	for _, subdivision := range info.Subdivisions {
+		if subdivision == nil {
+			continue
+		}
+
+		result.Subdivisions = append(result.Subdivisions, &Subdivision{
+			GeonameId: uint32(subdivision.GeoNameID),
+			IsoCode:   subdivision.IsoCode,
+			Names:     subdivision.Names,
+		})
+	}
+
+	if info.Traits != nil {
+		result.Traits = &Traits{
+			IsAnonymousProxy:    info.Traits.IsAnonymousProxy,
+			IsSatelliteProvider: info.Traits.IsSatelliteProvider,
+			Isp:                 info.Traits.ISP,
+			Domain:              info.Traits.Domain,
+			UsageType:           info.Traits.UsageType,
+		}
+	}
+
+	if info.ASN != nil {
+		result.Asn = &ASN{
+			AutonomousSystemNumber:       uint32(info.ASN.AutonomousSystemNumber),
+			AutonomousSystemOrganization: info.ASN.AutonomousSystemOrganization,
+		}
+	}
+
+	if info.AnonymousIP != nil {
+		result.AnonymousIp = &AnonymousIP{
+			IsAnonymous:       info.AnonymousIP.IsAnonymous,
+			IsAnonymousVpn:    info.AnonymousIP.IsAnonymousVPN,
+			IsHostingProvider: info.AnonymousIP.IsHostingProvider,
+			IsPublicProxy:     info.AnonymousIP.IsPublicProxy,
+			IsTorExitNode:     info.AnonymousIP.IsTorExitNode,
+		}
+	}
+
+	if info.Reserved != nil {
+		result.Reserved = &Reserved{
+			Network: info.Reserved.Network,
+			Name:    info.Reserved.Name,
+			Rfc:     info.Reserved.RFC,
+		}
+	}
+
+	if info.Cloud != nil {
+		result.Cloud = &Cloud{
+			Provider: info.Cloud.Provider,
+			Service:  info.Cloud.Service,
+			Region:   info.Cloud.Region,
+		}
+	}
+
+	return result
+}
+
+func fromContinent(continent *utils.Continent) *Continent {
+	if continent == nil {
+		return nil
+	}
+
+	return &Continent{
+		Code:      continent.Code,
+		GeonameId: uint32(continent.GeoNameID),
+		Names:     continent.Names,
+	}
+}
+
+func fromCountry(country *utils.Country) *Country {
+	if country == nil {
+		return nil
+	}
+
+	return &Country{
+		GeonameId:         uint32(country.GeoNameID),
+		IsInEuropeanUnion: country.IsInEuropeanUnion,
+		IsoCode:           country.IsoCode,
+		Names:             country.Names,
+		Type:              country.Type,
+	}
+}
@@ -0,0 +1,3 @@
+package rpc
+
+//go:generate buf generate
@@ -0,0 +1,1397 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: geo.proto
+
+package rpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type LookupRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Address string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	// provider to use. the configured default is used when empty
+	Provider      string `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupRequest) Reset() {
+	*x = LookupRequest{}
+	mi := &file_geo_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupRequest) ProtoMessage() {}
+
+func (x *LookupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupRequest.ProtoReflect.Descriptor instead.
+func (*LookupRequest) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LookupRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *LookupRequest) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+type LookupResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Info          *IPInfo                `protobuf:"bytes,1,opt,name=info,proto3" json:"info,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupResponse) Reset() {
+	*x = LookupResponse{}
+	mi := &file_geo_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupResponse) ProtoMessage() {}
+
+func (x *LookupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupResponse.ProtoReflect.Descriptor instead.
+func (*LookupResponse) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *LookupResponse) GetInfo() *IPInfo {
+	if x != nil {
+		return x.Info
+	}
+	return nil
+}
+
+type BatchLookupRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Addresses []string               `protobuf:"bytes,1,rep,name=addresses,proto3" json:"addresses,omitempty"`
+	// provider to use. the configured default is used when empty
+	Provider      string `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchLookupRequest) Reset() {
+	*x = BatchLookupRequest{}
+	mi := &file_geo_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchLookupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchLookupRequest) ProtoMessage() {}
+
+func (x *BatchLookupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchLookupRequest.ProtoReflect.Descriptor instead.
+func (*BatchLookupRequest) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BatchLookupRequest) GetAddresses() []string {
+	if x != nil {
+		return x.Addresses
+	}
+	return nil
+}
+
+func (x *BatchLookupRequest) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+type BatchLookupResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*LookupResult        `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchLookupResponse) Reset() {
+	*x = BatchLookupResponse{}
+	mi := &file_geo_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchLookupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchLookupResponse) ProtoMessage() {}
+
+func (x *BatchLookupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchLookupResponse.ProtoReflect.Descriptor instead.
+func (*BatchLookupResponse) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BatchLookupResponse) GetResults() []*LookupResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+// LookupResult holds the outcome for a single address of a batch
+type LookupResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Info          *IPInfo                `protobuf:"bytes,2,opt,name=info,proto3" json:"info,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LookupResult) Reset() {
+	*x = LookupResult{}
+	mi := &file_geo_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LookupResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LookupResult) ProtoMessage() {}
+
+func (x *LookupResult) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LookupResult.ProtoReflect.Descriptor instead.
+func (*LookupResult) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *LookupResult) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *LookupResult) GetInfo() *IPInfo {
+	if x != nil {
+		return x.Info
+	}
+	return nil
+}
+
+func (x *LookupResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type Subdivision struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	GeonameId     uint32                 `protobuf:"varint,1,opt,name=geoname_id,json=geonameId,proto3" json:"geoname_id,omitempty"`
+	IsoCode       string                 `protobuf:"bytes,2,opt,name=iso_code,json=isoCode,proto3" json:"iso_code,omitempty"`
+	Names         map[string]string      `protobuf:"bytes,3,rep,name=names,proto3" json:"names,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Subdivision) Reset() {
+	*x = Subdivision{}
+	mi := &file_geo_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subdivision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subdivision) ProtoMessage() {}
+
+func (x *Subdivision) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subdivision.ProtoReflect.Descriptor instead.
+func (*Subdivision) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Subdivision) GetGeonameId() uint32 {
+	if x != nil {
+		return x.GeonameId
+	}
+	return 0
+}
+
+func (x *Subdivision) GetIsoCode() string {
+	if x != nil {
+		return x.IsoCode
+	}
+	return ""
+}
+
+func (x *Subdivision) GetNames() map[string]string {
+	if x != nil {
+		return x.Names
+	}
+	return nil
+}
+
+type City struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	GeonameId     uint32                 `protobuf:"varint,1,opt,name=geoname_id,json=geonameId,proto3" json:"geoname_id,omitempty"`
+	Names         map[string]string      `protobuf:"bytes,2,rep,name=names,proto3" json:"names,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *City) Reset() {
+	*x = City{}
+	mi := &file_geo_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *City) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*City) ProtoMessage() {}
+
+func (x *City) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use City.ProtoReflect.Descriptor instead.
+func (*City) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *City) GetGeonameId() uint32 {
+	if x != nil {
+		return x.GeonameId
+	}
+	return 0
+}
+
+func (x *City) GetNames() map[string]string {
+	if x != nil {
+		return x.Names
+	}
+	return nil
+}
+
+type Continent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	GeonameId     uint32                 `protobuf:"varint,2,opt,name=geoname_id,json=geonameId,proto3" json:"geoname_id,omitempty"`
+	Names         map[string]string      `protobuf:"bytes,3,rep,name=names,proto3" json:"names,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Continent) Reset() {
+	*x = Continent{}
+	mi := &file_geo_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Continent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Continent) ProtoMessage() {}
+
+func (x *Continent) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Continent.ProtoReflect.Descriptor instead.
+func (*Continent) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Continent) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Continent) GetGeonameId() uint32 {
+	if x != nil {
+		return x.GeonameId
+	}
+	return 0
+}
+
+func (x *Continent) GetNames() map[string]string {
+	if x != nil {
+		return x.Names
+	}
+	return nil
+}
+
+type Country struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	GeonameId         uint32                 `protobuf:"varint,1,opt,name=geoname_id,json=geonameId,proto3" json:"geoname_id,omitempty"`
+	IsInEuropeanUnion bool                   `protobuf:"varint,2,opt,name=is_in_european_union,json=isInEuropeanUnion,proto3" json:"is_in_european_union,omitempty"`
+	IsoCode           string                 `protobuf:"bytes,3,opt,name=iso_code,json=isoCode,proto3" json:"iso_code,omitempty"`
+	Names             map[string]string      `protobuf:"bytes,4,rep,name=names,proto3" json:"names,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Type              string                 `protobuf:"bytes,5,opt,name=type,proto3" json:"type,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Country) Reset() {
+	*x = Country{}
+	mi := &file_geo_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Country) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Country) ProtoMessage() {}
+
+func (x *Country) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Country.ProtoReflect.Descriptor instead.
+func (*Country) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Country) GetGeonameId() uint32 {
+	if x != nil {
+		return x.GeonameId
+	}
+	return 0
+}
+
+func (x *Country) GetIsInEuropeanUnion() bool {
+	if x != nil {
+		return x.IsInEuropeanUnion
+	}
+	return false
+}
+
+func (x *Country) GetIsoCode() string {
+	if x != nil {
+		return x.IsoCode
+	}
+	return ""
+}
+
+func (x *Country) GetNames() map[string]string {
+	if x != nil {
+		return x.Names
+	}
+	return nil
+}
+
+func (x *Country) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+type Location struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	AccuracyRadius uint32                 `protobuf:"varint,1,opt,name=accuracy_radius,json=accuracyRadius,proto3" json:"accuracy_radius,omitempty"`
+	Latitude       float64                `protobuf:"fixed64,2,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude      float64                `protobuf:"fixed64,3,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	MetroCode      uint32                 `protobuf:"varint,4,opt,name=metro_code,json=metroCode,proto3" json:"metro_code,omitempty"`
+	TimeZone       string                 `protobuf:"bytes,5,opt,name=time_zone,json=timeZone,proto3" json:"time_zone,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Location) Reset() {
+	*x = Location{}
+	mi := &file_geo_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Location) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Location) ProtoMessage() {}
+
+func (x *Location) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Location.ProtoReflect.Descriptor instead.
+func (*Location) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Location) GetAccuracyRadius() uint32 {
+	if x != nil {
+		return x.AccuracyRadius
+	}
+	return 0
+}
+
+func (x *Location) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *Location) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+func (x *Location) GetMetroCode() uint32 {
+	if x != nil {
+		return x.MetroCode
+	}
+	return 0
+}
+
+func (x *Location) GetTimeZone() string {
+	if x != nil {
+		return x.TimeZone
+	}
+	return ""
+}
+
+type Traits struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	IsAnonymousProxy    bool                   `protobuf:"varint,1,opt,name=is_anonymous_proxy,json=isAnonymousProxy,proto3" json:"is_anonymous_proxy,omitempty"`
+	IsSatelliteProvider bool                   `protobuf:"varint,2,opt,name=is_satellite_provider,json=isSatelliteProvider,proto3" json:"is_satellite_provider,omitempty"`
+	Isp                 string                 `protobuf:"bytes,3,opt,name=isp,proto3" json:"isp,omitempty"`
+	Domain              string                 `protobuf:"bytes,4,opt,name=domain,proto3" json:"domain,omitempty"`
+	UsageType           string                 `protobuf:"bytes,5,opt,name=usage_type,json=usageType,proto3" json:"usage_type,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *Traits) Reset() {
+	*x = Traits{}
+	mi := &file_geo_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Traits) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Traits) ProtoMessage() {}
+
+func (x *Traits) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Traits.ProtoReflect.Descriptor instead.
+func (*Traits) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Traits) GetIsAnonymousProxy() bool {
+	if x != nil {
+		return x.IsAnonymousProxy
+	}
+	return false
+}
+
+func (x *Traits) GetIsSatelliteProvider() bool {
+	if x != nil {
+		return x.IsSatelliteProvider
+	}
+	return false
+}
+
+func (x *Traits) GetIsp() string {
+	if x != nil {
+		return x.Isp
+	}
+	return ""
+}
+
+func (x *Traits) GetDomain() string {
+	if x != nil {
+		return x.Domain
+	}
+	return ""
+}
+
+func (x *Traits) GetUsageType() string {
+	if x != nil {
+		return x.UsageType
+	}
+	return ""
+}
+
+type Postal struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Postal) Reset() {
+	*x = Postal{}
+	mi := &file_geo_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Postal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Postal) ProtoMessage() {}
+
+func (x *Postal) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Postal.ProtoReflect.Descriptor instead.
+func (*Postal) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Postal) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+type ASN struct {
+	state                        protoimpl.MessageState `protogen:"open.v1"`
+	AutonomousSystemNumber       uint32                 `protobuf:"varint,1,opt,name=autonomous_system_number,json=autonomousSystemNumber,proto3" json:"autonomous_system_number,omitempty"`
+	AutonomousSystemOrganization string                 `protobuf:"bytes,2,opt,name=autonomous_system_organization,json=autonomousSystemOrganization,proto3" json:"autonomous_system_organization,omitempty"`
+	unknownFields                protoimpl.UnknownFields
+	sizeCache                    protoimpl.SizeCache
+}
+
+func (x *ASN) Reset() {
+	*x = ASN{}
+	mi := &file_geo_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ASN) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ASN) ProtoMessage() {}
+
+func (x *ASN) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ASN.ProtoReflect.Descriptor instead.
+func (*ASN) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ASN) GetAutonomousSystemNumber() uint32 {
+	if x != nil {
+		return x.AutonomousSystemNumber
+	}
+	return 0
+}
+
+func (x *ASN) GetAutonomousSystemOrganization() string {
+	if x != nil {
+		return x.AutonomousSystemOrganization
+	}
+	return ""
+}
+
+type AnonymousIP struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	IsAnonymous       bool                   `protobuf:"varint,1,opt,name=is_anonymous,json=isAnonymous,proto3" json:"is_anonymous,omitempty"`
+	IsAnonymousVpn    bool                   `protobuf:"varint,2,opt,name=is_anonymous_vpn,json=isAnonymousVpn,proto3" json:"is_anonymous_vpn,omitempty"`
+	IsHostingProvider bool                   `protobuf:"varint,3,opt,name=is_hosting_provider,json=isHostingProvider,proto3" json:"is_hosting_provider,omitempty"`
+	IsPublicProxy     bool                   `protobuf:"varint,4,opt,name=is_public_proxy,json=isPublicProxy,proto3" json:"is_public_proxy,omitempty"`
+	IsTorExitNode     bool                   `protobuf:"varint,5,opt,name=is_tor_exit_node,json=isTorExitNode,proto3" json:"is_tor_exit_node,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *AnonymousIP) Reset() {
+	*x = AnonymousIP{}
+	mi := &file_geo_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnonymousIP) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnonymousIP) ProtoMessage() {}
+
+func (x *AnonymousIP) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnonymousIP.ProtoReflect.Descriptor instead.
+func (*AnonymousIP) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *AnonymousIP) GetIsAnonymous() bool {
+	if x != nil {
+		return x.IsAnonymous
+	}
+	return false
+}
+
+func (x *AnonymousIP) GetIsAnonymousVpn() bool {
+	if x != nil {
+		return x.IsAnonymousVpn
+	}
+	return false
+}
+
+func (x *AnonymousIP) GetIsHostingProvider() bool {
+	if x != nil {
+		return x.IsHostingProvider
+	}
+	return false
+}
+
+func (x *AnonymousIP) GetIsPublicProxy() bool {
+	if x != nil {
+		return x.IsPublicProxy
+	}
+	return false
+}
+
+func (x *AnonymousIP) GetIsTorExitNode() bool {
+	if x != nil {
+		return x.IsTorExitNode
+	}
+	return false
+}
+
+// Reserved describes the special-purpose range of a reserved address
+type Reserved struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Network       string                 `protobuf:"bytes,1,opt,name=network,proto3" json:"network,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Rfc           string                 `protobuf:"bytes,3,opt,name=rfc,proto3" json:"rfc,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Reserved) Reset() {
+	*x = Reserved{}
+	mi := &file_geo_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Reserved) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reserved) ProtoMessage() {}
+
+func (x *Reserved) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reserved.ProtoReflect.Descriptor instead.
+func (*Reserved) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Reserved) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *Reserved) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Reserved) GetRfc() string {
+	if x != nil {
+		return x.Rfc
+	}
+	return ""
+}
+
+type Cloud struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Provider      string                 `protobuf:"bytes,1,opt,name=provider,proto3" json:"provider,omitempty"`
+	Service       string                 `protobuf:"bytes,2,opt,name=service,proto3" json:"service,omitempty"`
+	Region        string                 `protobuf:"bytes,3,opt,name=region,proto3" json:"region,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Cloud) Reset() {
+	*x = Cloud{}
+	mi := &file_geo_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Cloud) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Cloud) ProtoMessage() {}
+
+func (x *Cloud) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Cloud.ProtoReflect.Descriptor instead.
+func (*Cloud) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Cloud) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *Cloud) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *Cloud) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+// IPInfo mirrors utils.IPInfo
+type IPInfo struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Address            string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Source             string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	IsFallback         bool                   `protobuf:"varint,3,opt,name=is_fallback,json=isFallback,proto3" json:"is_fallback,omitempty"`
+	HasCity            bool                   `protobuf:"varint,4,opt,name=has_city,json=hasCity,proto3" json:"has_city,omitempty"`
+	City               *City                  `protobuf:"bytes,5,opt,name=city,proto3" json:"city,omitempty"`
+	Continent          *Continent             `protobuf:"bytes,6,opt,name=continent,proto3" json:"continent,omitempty"`
+	Country            *Country               `protobuf:"bytes,7,opt,name=country,proto3" json:"country,omitempty"`
+	Location           *Location              `protobuf:"bytes,8,opt,name=location,proto3" json:"location,omitempty"`
+	Postal             *Postal                `protobuf:"bytes,9,opt,name=postal,proto3" json:"postal,omitempty"`
+	RegisteredCountry  *Country               `protobuf:"bytes,10,opt,name=registered_country,json=registeredCountry,proto3" json:"registered_country,omitempty"`
+	RepresentedCountry *Country               `protobuf:"bytes,11,opt,name=represented_country,json=representedCountry,proto3" json:"represented_country,omitempty"`
+	Subdivisions       []*Subdivision         `protobuf:"bytes,12,rep,name=subdivisions,proto3" json:"subdivisions,omitempty"`
+	Traits             *Traits                `protobuf:"bytes,13,opt,name=traits,proto3" json:"traits,omitempty"`
+	HasAsn             bool                   `protobuf:"varint,14,opt,name=has_asn,json=hasAsn,proto3" json:"has_asn,omitempty"`
+	Asn                *ASN                   `protobuf:"bytes,15,opt,name=asn,proto3" json:"asn,omitempty"`
+	HasAnonymousIp     bool                   `protobuf:"varint,16,opt,name=has_anonymous_ip,json=hasAnonymousIp,proto3" json:"has_anonymous_ip,omitempty"`
+	AnonymousIp        *AnonymousIP           `protobuf:"bytes,17,opt,name=anonymous_ip,json=anonymousIp,proto3" json:"anonymous_ip,omitempty"`
+	OriginalAddress    string                 `protobuf:"bytes,18,opt,name=original_address,json=originalAddress,proto3" json:"original_address,omitempty"`
+	Network            string                 `protobuf:"bytes,19,opt,name=network,proto3" json:"network,omitempty"`
+	Tags               []string               `protobuf:"bytes,20,rep,name=tags,proto3" json:"tags,omitempty"`
+	Cloud              *Cloud                 `protobuf:"bytes,21,opt,name=cloud,proto3" json:"cloud,omitempty"`
+	Reserved           *Reserved              `protobuf:"bytes,22,opt,name=reserved,proto3" json:"reserved,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *IPInfo) Reset() {
+	*x = IPInfo{}
+	mi := &file_geo_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IPInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IPInfo) ProtoMessage() {}
+
+func (x *IPInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_geo_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IPInfo.ProtoReflect.Descriptor instead.
+func (*IPInfo) Descriptor() ([]byte, []int) {
+	return file_geo_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *IPInfo) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *IPInfo) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *IPInfo) GetIsFallback() bool {
+	if x != nil {
+		return x.IsFallback
+	}
+	return false
+}
+
+func (x *IPInfo) GetHasCity() bool {
+	if x != nil {
+		return x.HasCity
+	}
+	return false
+}
+
+func (x *IPInfo) GetCity() *City {
+	if x != nil {
+		return x.City
+	}
+	return nil
+}
+
+func (x *IPInfo) GetContinent() *Continent {
+	if x != nil {
+		return x.Continent
+	}
+	return nil
+}
+
+func (x *IPInfo) GetCountry() *Country {
+	if x != nil {
+		return x.Country
+	}
+	return nil
+}
+
+func (x *IPInfo) GetLocation() *Location {
+	if x != nil {
+		return x.Location
+	}
+	return nil
+}
+
+func (x *IPInfo) GetPostal() *Postal {
+	if x != nil {
+		return x.Postal
+	}
+	return nil
+}
+
+func (x *IPInfo) GetRegisteredCountry() *Country {
+	if x != nil {
+		return x.RegisteredCountry
+	}
+	return nil
+}
+
+func (x *IPInfo) GetRepresentedCountry() *Country {
+	if x != nil {
+		return x.RepresentedCountry
+	}
+	return nil
+}
+
+func (x *IPInfo) GetSubdivisions() []*Subdivision {
+	if x != nil {
+		return x.Subdivisions
+	}
+	return nil
+}
+
+func (x *IPInfo) GetTraits() *Traits {
+	if x != nil {
+		return x.Traits
+	}
+	return nil
+}
+
+func (x *IPInfo) GetHasAsn() bool {
+	if x != nil {
+		return x.HasAsn
+	}
+	return false
+}
+
+func (x *IPInfo) GetAsn() *ASN {
+	if x != nil {
+		return x.Asn
+	}
+	return nil
+}
+
+func (x *IPInfo) GetHasAnonymousIp() bool {
+	if x != nil {
+		return x.HasAnonymousIp
+	}
+	return false
+}
+
+func (x *IPInfo) GetAnonymousIp() *AnonymousIP {
+	if x != nil {
+		return x.AnonymousIp
+	}
+	return nil
+}
+
+func (x *IPInfo) GetOriginalAddress() string {
+	if x != nil {
+		return x.OriginalAddress
+	}
+	return ""
+}
+
+func (x *IPInfo) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *IPInfo) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *IPInfo) GetCloud() *Cloud {
+	if x != nil {
+		return x.Cloud
+	}
+	return nil
+}
+
+func (x *IPInfo) GetReserved() *Reserved {
+	if x != nil {
+		return x.Reserved
+	}
+	return nil
+}
+
+var File_geo_proto protoreflect.FileDescriptor
+
+const file_geo_proto_rawDesc = "" +
+	"\n" +
+	"\tgeo.proto\x12\x06geo.v1\"E\n" +
+	"\rLookupRequest\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x1a\n" +
+	"\bprovider\x18\x02 \x01(\tR\bprovider\"4\n" +
+	"\x0eLookupResponse\x12\"\n" +
+	"\x04info\x18\x01 \x01(\v2\x0e.geo.v1.IPInfoR\x04info\"N\n" +
+	"\x12BatchLookupRequest\x12\x1c\n" +
+	"\taddresses\x18\x01 \x03(\tR\taddresses\x12\x1a\n" +
+	"\bprovider\x18\x02 \x01(\tR\bprovider\"E\n" +
+	"\x13BatchLookupResponse\x12.\n" +
+	"\aresults\x18\x01 \x03(\v2\x14.geo.v1.LookupResultR\aresults\"b\n" +
+	"\fLookupResult\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\"\n" +
+	"\x04info\x18\x02 \x01(\v2\x0e.geo.v1.IPInfoR\x04info\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"\xb7\x01\n" +
+	"\vSubdivision\x12\x1d\n" +
+	"\n" +
+	"geoname_id\x18\x01 \x01(\rR\tgeonameId\x12\x19\n" +
+	"\biso_code\x18\x02 \x01(\tR\aisoCode\x124\n" +
+	"\x05names\x18\x03 \x03(\v2\x1e.geo.v1.Subdivision.NamesEntryR\x05names\x1a8\n" +
+	"\n" +
+	"NamesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x8e\x01\n" +
+	"\x04City\x12\x1d\n" +
+	"\n" +
+	"geoname_id\x18\x01 \x01(\rR\tgeonameId\x12-\n" +
+	"\x05names\x18\x02 \x03(\v2\x17.geo.v1.City.NamesEntryR\x05names\x1a8\n" +
+	"\n" +
+	"NamesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xac\x01\n" +
+	"\tContinent\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x1d\n" +
+	"\n" +
+	"geoname_id\x18\x02 \x01(\rR\tgeonameId\x122\n" +
+	"\x05names\x18\x03 \x03(\v2\x1c.geo.v1.Continent.NamesEntryR\x05names\x1a8\n" +
+	"\n" +
+	"NamesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xf4\x01\n" +
+	"\aCountry\x12\x1d\n" +
+	"\n" +
+	"geoname_id\x18\x01 \x01(\rR\tgeonameId\x12/\n" +
+	"\x14is_in_european_union\x18\x02 \x01(\bR\x11isInEuropeanUnion\x12\x19\n" +
+	"\biso_code\x18\x03 \x01(\tR\aisoCode\x120\n" +
+	"\x05names\x18\x04 \x03(\v2\x1a.geo.v1.Country.NamesEntryR\x05names\x12\x12\n" +
+	"\x04type\x18\x05 \x01(\tR\x04type\x1a8\n" +
+	"\n" +
+	"NamesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xa9\x01\n" +
+	"\bLocation\x12'\n" +
+	"\x0faccuracy_radius\x18\x01 \x01(\rR\x0eaccuracyRadius\x12\x1a\n" +
+	"\blatitude\x18\x02 \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\x03 \x01(\x01R\tlongitude\x12\x1d\n" +
+	"\n" +
+	"metro_code\x18\x04 \x01(\rR\tmetroCode\x12\x1b\n" +
+	"\ttime_zone\x18\x05 \x01(\tR\btimeZone\"\xb3\x01\n" +
+	"\x06Traits\x12,\n" +
+	"\x12is_anonymous_proxy\x18\x01 \x01(\bR\x10isAnonymousProxy\x122\n" +
+	"\x15is_satellite_provider\x18\x02 \x01(\bR\x13isSatelliteProvider\x12\x10\n" +
+	"\x03isp\x18\x03 \x01(\tR\x03isp\x12\x16\n" +
+	"\x06domain\x18\x04 \x01(\tR\x06domain\x12\x1d\n" +
+	"\n" +
+	"usage_type\x18\x05 \x01(\tR\tusageType\"\x1c\n" +
+	"\x06Postal\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\"\x85\x01\n" +
+	"\x03ASN\x128\n" +
+	"\x18autonomous_system_number\x18\x01 \x01(\rR\x16autonomousSystemNumber\x12D\n" +
+	"\x1eautonomous_system_organization\x18\x02 \x01(\tR\x1cautonomousSystemOrganization\"\xdb\x01\n" +
+	"\vAnonymousIP\x12!\n" +
+	"\fis_anonymous\x18\x01 \x01(\bR\visAnonymous\x12(\n" +
+	"\x10is_anonymous_vpn\x18\x02 \x01(\bR\x0eisAnonymousVpn\x12.\n" +
+	"\x13is_hosting_provider\x18\x03 \x01(\bR\x11isHostingProvider\x12&\n" +
+	"\x0fis_public_proxy\x18\x04 \x01(\bR\risPublicProxy\x12'\n" +
+	"\x10is_tor_exit_node\x18\x05 \x01(\bR\risTorExitNode\"J\n" +
+	"\bReserved\x12\x18\n" +
+	"\anetwork\x18\x01 \x01(\tR\anetwork\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x10\n" +
+	"\x03rfc\x18\x03 \x01(\tR\x03rfc\"U\n" +
+	"\x05Cloud\x12\x1a\n" +
+	"\bprovider\x18\x01 \x01(\tR\bprovider\x12\x18\n" +
+	"\aservice\x18\x02 \x01(\tR\aservice\x12\x16\n" +
+	"\x06region\x18\x03 \x01(\tR\x06region\"\xf3\x06\n" +
+	"\x06IPInfo\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12\x1f\n" +
+	"\vis_fallback\x18\x03 \x01(\bR\n" +
+	"isFallback\x12\x19\n" +
+	"\bhas_city\x18\x04 \x01(\bR\ahasCity\x12 \n" +
+	"\x04city\x18\x05 \x01(\v2\f.geo.v1.CityR\x04city\x12/\n" +
+	"\tcontinent\x18\x06 \x01(\v2\x11.geo.v1.ContinentR\tcontinent\x12)\n" +
+	"\acountry\x18\a \x01(\v2\x0f.geo.v1.CountryR\acountry\x12,\n" +
+	"\blocation\x18\b \x01(\v2\x10.geo.v1.LocationR\blocation\x12&\n" +
+	"\x06postal\x18\t \x01(\v2\x0e.geo.v1.PostalR\x06postal\x12>\n" +
+	"\x12registered_country\x18\n" +
+	" \x01(\v2\x0f.geo.v1.CountryR\x11registeredCountry\x12@\n" +
+	"\x13represented_country\x18\v \x01(\v2\x0f.geo.v1.CountryR\x12representedCountry\x127\n" +
+	"\fsubdivisions\x18\f \x03(\v2\x13.geo.v1.SubdivisionR\fsubdivisions\x12&\n" +
+	"\x06traits\x18\r \x01(\v2\x0e.geo.v1.TraitsR\x06traits\x12\x17\n" +
+	"\ahas_asn\x18\x0e \x01(\bR\x06hasAsn\x12\x1d\n" +
+	"\x03asn\x18\x0f \x01(\v2\v.geo.v1.ASNR\x03asn\x12(\n" +
+	"\x10has_anonymous_ip\x18\x10 \x01(\bR\x0ehasAnonymousIp\x126\n" +
+	"\fanonymous_ip\x18\x11 \x01(\v2\x13.geo.v1.AnonymousIPR\vanonymousIp\x12)\n" +
+	"\x10original_address\x18\x12 \x01(\tR\x0foriginalAddress\x12\x18\n" +
+	"\anetwork\x18\x13 \x01(\tR\anetwork\x12\x12\n" +
+	"\x04tags\x18\x14 \x03(\tR\x04tags\x12#\n" +
+	"\x05cloud\x18\x15 \x01(\v2\r.geo.v1.CloudR\x05cloud\x12,\n" +
+	"\breserved\x18\x16 \x01(\v2\x10.geo.v1.ReservedR\breserved2\xd1\x01\n" +
+	"\n" +
+	"GeoService\x127\n" +
+	"\x06Lookup\x12\x15.geo.v1.LookupRequest\x1a\x16.geo.v1.LookupResponse\x12F\n" +
+	"\vBatchLookup\x12\x1a.geo.v1.BatchLookupRequest\x1a\x1b.geo.v1.BatchLookupResponse\x12B\n" +
+	"\fStreamLookup\x12\x1a.geo.v1.BatchLookupRequest\x1a\x14.geo.v1.LookupResult0\x01B Z\x1egithub.com/cloud66-oss/geo/rpcb\x06proto3"
+
+var (
+	file_geo_proto_rawDescOnce sync.Once
+	file_geo_proto_rawDescData []byte
+)
+
+func file_geo_proto_rawDescGZIP() []byte {
+	file_geo_proto_rawDescOnce.Do(func() {
+		file_geo_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_geo_proto_rawDesc), len(file_geo_proto_rawDesc)))
+	})
+	return file_geo_proto_rawDescData
+}
+
+var file_geo_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
+var file_geo_proto_goTypes = []any{
+	(*LookupRequest)(nil),       // 0: geo.v1.LookupRequest
+	(*LookupResponse)(nil),      // 1: geo.v1.LookupResponse
+	(*BatchLookupRequest)(nil),  // 2: geo.v1.BatchLookupRequest
+	(*BatchLookupResponse)(nil), // 3: geo.v1.BatchLookupResponse
+	(*LookupResult)(nil),        // 4: geo.v1.LookupResult
+	(*Subdivision)(nil),         // 5: geo.v1.Subdivision
+	(*City)(nil),                // 6: geo.v1.City
+	(*Continent)(nil),           // 7: geo.v1.Continent
+	(*Country)(nil),             // 8: geo.v1.Country
+	(*Location)(nil),            // 9: geo.v1.Location
+	(*Traits)(nil),              // 10: geo.v1.Traits
+	(*Postal)(nil),              // 11: geo.v1.Postal
+	(*ASN)(nil),                 // 12: geo.v1.ASN
+	(*AnonymousIP)(nil),         // 13: geo.v1.AnonymousIP
+	(*Reserved)(nil),            // 14: geo.v1.Reserved
+	(*Cloud)(nil),               // 15: geo.v1.Cloud
+	(*IPInfo)(nil),              // 16: geo.v1.IPInfo
+	nil,                         // 17: geo.v1.Subdivision.NamesEntry
+	nil,                         // 18: geo.v1.City.NamesEntry
+	nil,                         // 19: geo.v1.Continent.NamesEntry
+	nil,                         // 20: geo.v1.Country.NamesEntry
+}
+var file_geo_proto_depIdxs = []int32{
+	16, // 0: geo.v1.LookupResponse.info:type_name -> geo.v1.IPInfo
+	4,  // 1: geo.v1.BatchLookupResponse.results:type_name -> geo.v1.LookupResult
+	16, // 2: geo.v1.LookupResult.info:type_name -> geo.v1.IPInfo
+	17, // 3: geo.v1.Subdivision.names:type_name -> geo.v1.Subdivision.NamesEntry
+	18, // 4: geo.v1.City.names:type_name -> geo.v1.City.NamesEntry
+	19, // 5: geo.v1.Continent.names:type_name -> geo.v1.Continent.NamesEntry
+	20, // 6: geo.v1.Country.names:type_name -> geo.v1.Country.NamesEntry
+	6,  // 7: geo.v1.IPInfo.city:type_name -> geo.v1.City
+	7,  // 8: geo.v1.IPInfo.continent:type_name -> geo.v1.Continent
+	8,  // 9: geo.v1.IPInfo.country:type_name -> geo.v1.Country
+	9,  // 10: geo.v1.IPInfo.location:type_name -> geo.v1.Location
+	11, // 11: geo.v1.IPInfo.postal:type_name -> geo.v1.Postal
+	8,  // 12: geo.v1.IPInfo.registered_country:type_name -> geo.v1.Country
+	8,  // 13: geo.v1.IPInfo.represented_country:type_name -> geo.v1.Country
+	5,  // 14: geo.v1.IPInfo.subdivisions:type_name -> geo.v1.Subdivision
+	10, // 15: geo.v1.IPInfo.traits:type_name -> geo.v1.Traits
+	12, // 16: geo.v1.IPInfo.asn:type_name -> geo.v1.ASN
+	13, // 17: geo.v1.IPInfo.anonymous_ip:type_name -> geo.v1.AnonymousIP
+	15, // 18: geo.v1.IPInfo.cloud:type_name -> geo.v1.Cloud
+	14, // 19: geo.v1.IPInfo.reserved:type_name -> geo.v1.Reserved
+	0,  // 20: geo.v1.GeoService.Lookup:input_type -> geo.v1.LookupRequest
+	2,  // 21: geo.v1.GeoService.BatchLookup:input_type -> geo.v1.BatchLookupRequest
+	2,  // 22: geo.v1.GeoService.StreamLookup:input_type -> geo.v1.BatchLookupRequest
+	1,  // 23: geo.v1.GeoService.Lookup:output_type -> geo.v1.LookupResponse
+	3,  // 24: geo.v1.GeoService.BatchLookup:output_type -> geo.v1.BatchLookupResponse
+	4,  // 25: geo.v1.GeoService.StreamLookup:output_type -> geo.v1.LookupResult
+	23, // [23:26] is the sub-list for method output_type
+	20, // [20:23] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
+}
+
+func init() { file_geo_proto_init() }
+func file_geo_proto_init() {
+	if File_geo_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_geo_proto_rawDesc), len(file_geo_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   21,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_geo_proto_goTypes,
+		DependencyIndexes: file_geo_proto_depIdxs,
+		MessageInfos:      file_geo_proto_msgTypes,
+	}.Build()
+	File_geo_proto = out.File
+	file_geo_proto_goTypes = nil
+	file_geo_proto_depIdxs = nil
+}
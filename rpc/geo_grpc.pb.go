@@ -0,0 +1,211 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: geo.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	GeoService_Lookup_FullMethodName       = "/geo.v1.GeoService/Lookup"
+	GeoService_BatchLookup_FullMethodName  = "/geo.v1.GeoService/BatchLookup"
+	GeoService_StreamLookup_FullMethodName = "/geo.v1.GeoService/StreamLookup"
+)
+
+// GeoServiceClient is the client API for GeoService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// GeoService exposes the same lookups as the HTTP API over gRPC
+type GeoServiceClient interface {
+	// Lookup returns the information for a single address
+	Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error)
+	// BatchLookup returns the information for several addresses in one response
+	BatchLookup(ctx context.Context, in *BatchLookupRequest, opts ...grpc.CallOption) (*BatchLookupResponse, error)
+	// StreamLookup streams a result back for each requested address
+	StreamLookup(ctx context.Context, in *BatchLookupRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LookupResult], error)
+}
+
+type geoServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGeoServiceClient(cc grpc.ClientConnInterface) GeoServiceClient {
+	return &geoServiceClient{cc}
+}
+
+func (c *geoServiceClient) Lookup(ctx context.Context, in *LookupRequest, opts ...grpc.CallOption) (*LookupResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LookupResponse)
+	err := c.cc.Invoke(ctx, GeoService_Lookup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *geoServiceClient) BatchLookup(ctx context.Context, in *BatchLookupRequest, opts ...grpc.CallOption) (*BatchLookupResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchLookupResponse)
+	err := c.cc.Invoke(ctx, GeoService_BatchLookup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *geoServiceClient) StreamLookup(ctx context.Context, in *BatchLookupRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LookupResult], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &GeoService_ServiceDesc.Streams[0], GeoService_StreamLookup_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BatchLookupRequest, LookupResult]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type GeoService_StreamLookupClient = grpc.ServerStreamingClient[LookupResult]
+
+// GeoServiceServer is the server API for GeoService service.
+// All implementations must embed UnimplementedGeoServiceServer
+// for forward compatibility.
+//
+// GeoService exposes the same lookups as the HTTP API over gRPC
+type GeoServiceServer interface {
+	// Lookup returns the information for a single address
+	Lookup(context.Context, *LookupRequest) (*LookupResponse, error)
+	// BatchLookup returns the information for several addresses in one response
+	BatchLookup(context.Context, *BatchLookupRequest) (*BatchLookupResponse, error)
+	// StreamLookup streams a result back for each requested address
+	StreamLookup(*BatchLookupRequest, grpc.ServerStreamingServer[LookupResult]) error
+	mustEmbedUnimplementedGeoServiceServer()
+}
+
+// UnimplementedGeoServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedGeoServiceServer struct{}
+
+func (UnimplementedGeoServiceServer) Lookup(context.Context, *LookupRequest) (*LookupResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Lookup not implemented")
+}
+func (UnimplementedGeoServiceServer) BatchLookup(context.Context, *BatchLookupRequest) (*BatchLookupResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method BatchLookup not implemented")
+}
+func (UnimplementedGeoServiceServer) StreamLookup(*BatchLookupRequest, grpc.ServerStreamingServer[LookupResult]) error {
+	return status.Error(codes.Unimplemented, "method StreamLookup not implemented")
+}
+func (UnimplementedGeoServiceServer) mustEmbedUnimplementedGeoServiceServer() {}
+func (UnimplementedGeoServiceServer) testEmbeddedByValue()                    {}
+
+// UnsafeGeoServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GeoServiceServer will
+// result in compilation errors.
+type UnsafeGeoServiceServer interface {
+	mustEmbedUnimplementedGeoServiceServer()
+}
+
+func RegisterGeoServiceServer(s grpc.ServiceRegistrar, srv GeoServiceServer) {
+	// If the following call panics, it indicates UnimplementedGeoServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&GeoService_ServiceDesc, srv)
+}
+
+func _GeoService_Lookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeoServiceServer).Lookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GeoService_Lookup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeoServiceServer).Lookup(ctx, req.(*LookupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GeoService_BatchLookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchLookupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeoServiceServer).BatchLookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GeoService_BatchLookup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeoServiceServer).BatchLookup(ctx, req.(*BatchLookupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GeoService_StreamLookup_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BatchLookupRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GeoServiceServer).StreamLookup(m, &grpc.GenericServerStream[BatchLookupRequest, LookupResult]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type GeoService_StreamLookupServer = grpc.ServerStreamingServer[LookupResult]
+
+// GeoService_ServiceDesc is the grpc.ServiceDesc for GeoService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var GeoService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "geo.v1.GeoService",
+	HandlerType: (*GeoServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Lookup",
+			Handler:    _GeoService_Lookup_Handler,
+		},
+		{
+			MethodName: "BatchLookup",
+			Handler:    _GeoService_BatchLookup_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamLookup",
+			Handler:       _GeoService_StreamLookup_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "geo.proto",
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Embedded IPv4 address mechanisms for address.embedded_ipv4
+const (
+	SixToFour = "6to4"
+	Teredo    = "teredo"
+	NAT64     = "nat64"
+)
+
+var (
+	sixToFourPrefix = netip.MustParsePrefix("2002::/16")
+	teredoPrefix    = netip.MustParsePrefix("2001::/32")
+	nat64Prefix     = netip.MustParsePrefix("64:ff9b::/96")
+)
+
+// NormalizeAddress returns the canonical form of address that is looked up
+// and cached: IPv4 mapped addresses are unmapped and IPv6 addresses are lower
+// case and compressed. Addresses with a zone are rejected. When enabled in
+// address.embedded_ipv4, the IPv4 address embedded in 6to4, Teredo and NAT64
+// addresses is returned instead
+func NormalizeAddress(address string) (string, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil || addr.Zone() != "" {
+		return "", &IpAddressError{}
+	}
+
+	addr = addr.Unmap()
+	if addr.Is6() {
+		for _, mechanism := range viper.GetStringSlice("address.embedded_ipv4") {
+			if embedded, ok := EmbeddedIPv4(addr, strings.ToLower(mechanism)); ok {
+				addr = embedded
+				break
+			}
+		}
+	}
+
+	return addr.String(), nil
+}
+
+// EmbeddedIPv4 returns the IPv4 address addr carries with mechanism, if it
+// is an address of that mechanism
+func EmbeddedIPv4(addr netip.Addr, mechanism string) (netip.Addr, bool) {
+	bytes := addr.As16()
+
+	switch mechanism {
+	case SixToFour:
+		if sixToFourPrefix.Contains(addr) {
+			return netip.AddrFrom4([4]byte(bytes[2:6])), true
+		}
+	case Teredo:
+		// the client address is stored inverted in the last 32 bits
+		if teredoPrefix.Contains(addr) {
+			return netip.AddrFrom4([4]byte{^bytes[12], ^bytes[13], ^bytes[14], ^bytes[15]}), true
+		}
+	case NAT64:
+		if nat64Prefix.Contains(addr) {
+			return netip.AddrFrom4([4]byte(bytes[12:16])), true
+		}
+	}
+
+	return netip.Addr{}, false
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type addressTestSuite struct {
+	suite.Suite
+}
+
+func (suite *addressTestSuite) SetupTest() {
+	viper.Set("address.embedded_ipv4", []string{})
+}
+
+func (suite *addressTestSuite) normalize(address string) string {
+	normalized, err := NormalizeAddress(address)
+	suite.Require().NoError(err, address)
+
+	return normalized
+}
+
+func (suite *addressTestSuite) TestCanonicalForm() {
+	suite.Equal("8.8.8.8", suite.normalize("8.8.8.8"))
+	suite.Equal("8.8.8.8", suite.normalize("::ffff:8.8.8.8"))
+	suite.Equal("2001:db8::1", suite.normalize("2001:DB8:0:0:0:0:0:1"))
+	suite.Equal("2002:808:808::1", suite.normalize("2002:0808:0808::1"))
+}
+
+func (suite *addressTestSuite) TestInvalid() {
+	for _, address := range []string{"", "nope", "1.2.3", "fe80::1%eth0", "1.2.3.4/24"} {
+		_, err := NormalizeAddress(address)
+		suite.IsType(&IpAddressError{}, err, address)
+	}
+}
+
+func (suite *addressTestSuite) TestEmbeddedIPv4() {
+	viper.Set("address.embedded_ipv4", []string{SixToFour, Teredo, NAT64})
+
+	suite.Equal("8.8.8.8", suite.normalize("2002:808:808::1"))
+	suite.Equal("8.8.4.4", suite.normalize("64:ff9b::808:404"))
+	// 2001:0:4136:e378:8000:63bf:3fff:fdd2 is the Teredo example of RFC 4380
+	suite.Equal("192.0.2.45", suite.normalize("2001:0:4136:e378:8000:63bf:3fff:fdd2"))
+	suite.Equal("2001:db8::1", suite.normalize("2001:db8::1"))
+}
+
+func (suite *addressTestSuite) TestEmbeddedIPv4PerMechanism() {
+	viper.Set("address.embedded_ipv4", []string{NAT64})
+
+	suite.Equal("2002:808:808::1", suite.normalize("2002:808:808::1"))
+	suite.Equal("8.8.4.4", suite.normalize("64:ff9b::808:404"))
+}
+
+func TestAddressTestSuite(t *testing.T) {
+	suite.Run(t, new(addressTestSuite))
+}
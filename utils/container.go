@@ -10,11 +10,18 @@ import (
 type ObjectID string
 
 var (
-	Cache           = ObjectID("cache")
-	MaxMindProvider = ObjectID("maxmind-provider")
-	DbIpProvider    = ObjectID("dbip-provider")
-	IpStackProvider = ObjectID("ipstack-provider")
-	GlobioProvider  = ObjectID("globio-provider")
+	Cache               = ObjectID("cache")
+	MaxMindProvider     = ObjectID("maxmind-provider")
+	DbIpProvider        = ObjectID("dbip-provider")
+	IpStackProvider     = ObjectID("ipstack-provider")
+	GlobioProvider      = ObjectID("globio-provider")
+	CascadeProvider     = ObjectID("cascade-provider")
+	OverridesProvider   = ObjectID("overrides-provider")
+	GeofeedProvider     = ObjectID("geofeed-provider")
+	CloudProvider       = ObjectID("cloud-provider")
+	BlocklistProvider   = ObjectID("blocklist-provider")
+	Ip2LocationProvider = ObjectID("ip2location-provider")
+	MmdbProvider        = ObjectID("mmdb-provider")
 )
 
 type IoCContainer struct {
@@ -54,6 +61,17 @@ func (c *IoCContainer) Assign(ctx context.Context, name ObjectID, obj interface{
 	return nil
 }
 
+// Replace assigns obj to name and returns the object it replaces, if any
+func (c *IoCContainer) Replace(ctx context.Context, name ObjectID, obj interface{}) interface{} {
+	c.Lock()
+	defer c.Unlock()
+
+	previous := c.objects[name]
+	c.objects[name] = obj
+
+	return previous
+}
+
 func (c *IoCContainer) Clear(ctx context.Context) {
 	c.Lock()
 	defer c.Unlock()
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var databaseVersions = struct {
+	sync.RWMutex
+	epochs  map[string]map[string]uint
+	members map[string][]string
+}{
+	epochs:  map[string]map[string]uint{},
+	members: map[string][]string{},
+}
+
+func recordDatabaseVersion(provider string, database string, buildEpoch uint) {
+	databaseVersions.Lock()
+	defer databaseVersions.Unlock()
+
+	if databaseVersions.epochs[provider] == nil {
+		databaseVersions.epochs[provider] = map[string]uint{}
+	}
+	databaseVersions.epochs[provider][database] = buildEpoch
+}
+
+// SetProviderMembers records the providers a provider such as cascade
+// answers from, so its database version follows theirs
+func SetProviderMembers(provider string, members []string) {
+	databaseVersions.Lock()
+	defer databaseVersions.Unlock()
+
+	databaseVersions.members[provider] = members
+}
+
+// DatabaseVersion returns a short identifier of the databases provider
+// answers from. It changes whenever one of them is loaded with a different
+// build, and is "0" for providers without databases
+func DatabaseVersion(provider string) string {
+	databaseVersions.RLock()
+	defer databaseVersions.RUnlock()
+
+	var builds []string
+	collectDatabaseBuilds(provider, &builds, map[string]bool{})
+	if len(builds) == 0 {
+		return "0"
+	}
+
+	slices.Sort(builds)
+	hash := fnv.New64a()
+	hash.Write([]byte(strings.Join(builds, ",")))
+
+	return fmt.Sprintf("%x", hash.Sum64())
+}
+
+func collectDatabaseBuilds(provider string, builds *[]string, seen map[string]bool) {
+	if seen[provider] {
+		return
+	}
+	seen[provider] = true
+
+	for database, epoch := range databaseVersions.epochs[provider] {
+		*builds = append(*builds, fmt.Sprintf("%s/%s=%d", provider, database, epoch))
+	}
+
+	for _, member := range databaseVersions.members[provider] {
+		collectDatabaseBuilds(member, builds, seen)
+	}
+}
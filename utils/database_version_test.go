@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type databaseVersionTestSuite struct {
+	suite.Suite
+}
+
+func (suite *databaseVersionTestSuite) TestVersionFollowsBuilds() {
+	suite.Equal("0", DatabaseVersion("test-remote"))
+
+	RecordDatabaseLoad("test-local", "city", 1000)
+	RecordDatabaseLoad("test-local", "asn", 2000)
+	version := DatabaseVersion("test-local")
+	suite.NotEqual("0", version)
+
+	// reloading the same builds keeps the version
+	RecordDatabaseLoad("test-local", "city", 1000)
+	suite.Equal(version, DatabaseVersion("test-local"))
+
+	RecordDatabaseLoad("test-local", "asn", 3000)
+	suite.NotEqual(version, DatabaseVersion("test-local"))
+}
+
+func (suite *databaseVersionTestSuite) TestMembers() {
+	SetProviderMembers("test-cascade", []string{"test-member", "test-remote"})
+	RecordDatabaseLoad("test-member", "city", 1000)
+	version := DatabaseVersion("test-cascade")
+	suite.NotEqual("0", version)
+
+	RecordDatabaseLoad("test-member", "city", 2000)
+	suite.NotEqual(version, DatabaseVersion("test-cascade"))
+}
+
+func TestDatabaseVersionTestSuite(t *testing.T) {
+	suite.Run(t, new(databaseVersionTestSuite))
+}
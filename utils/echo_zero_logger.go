@@ -1,12 +1,20 @@
 package utils
 
 import (
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/labstack/echo"
 	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
 )
 
+// ProviderContextKey is the echo context key handlers store the name of the
+// provider they used under, for request logs and metrics. It must only be
+// set to known providers, as every value is a metric series
+const ProviderContextKey = "provider"
+
 func ZeroLogger(log *zerolog.Logger) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -38,17 +46,49 @@ func ZeroLogger(log *zerolog.Logger) echo.MiddlewareFunc {
 				level = zerolog.DebugLevel
 			}
 
+			latency := time.Since(start)
+
+			route := c.Path()
+			if route == "" {
+				route = "unknown"
+			}
+			// handlers that look up addresses set the provider they used
+			provider, _ := c.Get(ProviderContextKey).(string)
+			if provider == "" {
+				provider = "unknown"
+			}
+			status := strconv.Itoa(res.Status)
+			RequestsTotal.WithLabelValues(route, provider, status).Inc()
+			RequestDuration.WithLabelValues(route, provider, status).Observe(latency.Seconds())
+
 			log.WithLevel(level).
 				Int("status", res.Status).
-				Str("latency", time.Since(start).String()).
+				Str("latency", latency.String()).
 				Str("id", id).
 				Str("method", req.Method).
-				Str("uri", req.RequestURI).
+				Str("uri", logURI(req)).
 				Str("host", req.Host).
 				Str("remote_ip", c.RealIP()).
+				Str("provider", provider).
 				Msg("request")
 
 			return nil
 		}
 	}
 }
+
+// logURI returns the URI of req with the API key passed in the auth.query
+// parameter redacted
+func logURI(req *http.Request) string {
+	name := viper.GetString("auth.query")
+	query := req.URL.Query()
+	if name == "" || !query.Has(name) {
+		return req.RequestURI
+	}
+
+	query.Set(name, Redacted)
+	uri := *req.URL
+	uri.RawQuery = query.Encode()
+
+	return uri.RequestURI()
+}
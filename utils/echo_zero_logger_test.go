@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type zeroLoggerTestSuite struct {
+	suite.Suite
+}
+
+func (suite *zeroLoggerTestSuite) request(target string) string {
+	var out bytes.Buffer
+	logger := zerolog.New(&out).Level(zerolog.DebugLevel)
+
+	e := echo.New()
+	e.Use(ZeroLogger(&logger))
+	e.GET("/v1/ip/:address", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+
+	return out.String()
+}
+
+func (suite *zeroLoggerTestSuite) TestRedactsApiKey() {
+	viper.Set("auth.query", "api_key")
+	defer viper.Set("auth.query", nil)
+
+	line := suite.request("/v1/ip/1.1.1.1?api_key=secret&provider=dbip")
+	suite.NotContains(line, "secret")
+	suite.Contains(line, `"uri":"/v1/ip/1.1.1.1?api_key=REDACTED&provider=dbip"`)
+
+	suite.Contains(suite.request("/v1/ip/1.1.1.1?provider=dbip"), `"uri":"/v1/ip/1.1.1.1?provider=dbip"`)
+}
+
+func TestZeroLoggerTestSuite(t *testing.T) {
+	suite.Run(t, new(zeroLoggerTestSuite))
+}
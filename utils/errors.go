@@ -3,8 +3,30 @@ package utils
 type IpAddressError struct{}
 type UnknownProviderError struct{}
 
+// ProviderUnavailableError is returned by providers that can't take lookups
+// at the moment, such as remote providers with an open circuit breaker
+type ProviderUnavailableError struct {
+	Provider string
+}
+
+// ReservedAddressError is returned for addresses in a reserved range when
+// reserved.response is set to error
+type ReservedAddressError struct {
+	Address string
+	Range   *ReservedRange
+}
+
+// LoadError is returned by Start when a provider starts without some or all
+// of its databases. The provider keeps serving what it has loaded and Err is
+// why the rest failed
+type LoadError struct {
+	Err error
+}
+
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error    string         `json:"error"`
+	Code     string         `json:"code,omitempty"`
+	Reserved *ReservedRange `json:"reserved,omitempty"`
 }
 
 func (e IpAddressError) Error() string {
@@ -14,3 +36,19 @@ func (e IpAddressError) Error() string {
 func (e UnknownProviderError) Error() string {
 	return "unknown provider"
 }
+
+func (e ProviderUnavailableError) Error() string {
+	return e.Provider + " provider is unavailable"
+}
+
+func (e LoadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e LoadError) Unwrap() error {
+	return e.Err
+}
+
+func (e ReservedAddressError) Error() string {
+	return e.Address + " is a reserved address (" + e.Range.Name + ")"
+}
@@ -3,9 +3,11 @@ package utils
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -13,6 +15,8 @@ import (
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // type ProgressReporter func(done chan int64, path string, total int64)
@@ -24,6 +28,10 @@ func DownloadFile(filepath string, url string) error {
 	}
 	defer resp.Body.Close()
 
+	if err := checkDownloadStatus(url, resp); err != nil {
+		return err
+	}
+
 	// write to a tmp file first to avoid leaving a corrupt file on failure
 	tmpPath := filepath + ".tmp"
 	out, err := os.Create(tmpPath)
@@ -84,14 +92,36 @@ func PrintDownloadPercent(done chan int64, path string, total int64) {
 	}
 }
 
-func DownloadFileWithProgress(url string, dest string) error {
-	headResp, err := http.Head(url)
+// DownloadFileWithProgress downloads url to dest unless the ETag of url
+// matches the one stored next to dest from the previous download
+func DownloadFileWithProgress(ctx context.Context, url string, dest string) error {
+	ctx, span := startDownloadSpan(ctx, url, dest)
+	err := downloadFileWithProgress(ctx, url, dest)
+	EndSpan(span, err)
+	if err != nil {
+		DownloadFailures.WithLabelValues(filepath.Base(dest)).Inc()
+	}
+
+	return err
+}
+
+func downloadFileWithProgress(ctx context.Context, url string, dest string) error {
+	headReq, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return err
+	}
+
+	headResp, err := http.DefaultClient.Do(headReq)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get head")
 		return err
 	}
 	defer headResp.Body.Close()
 
+	if err := checkDownloadStatus(url, headResp); err != nil {
+		return err
+	}
+
 	_, err = strconv.Atoi(headResp.Header.Get("Content-Length"))
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get content length")
@@ -123,7 +153,13 @@ func DownloadFileWithProgress(url string, dest string) error {
 	}
 	defer out.Close()
 
-	resp, err := http.Get(url)
+	getReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(getReq)
 	if err != nil {
 		// clean up the partial tmp file
 		os.Remove(tmpPath)
@@ -132,7 +168,14 @@ func DownloadFileWithProgress(url string, dest string) error {
 	}
 	defer resp.Body.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	// error pages aren't databases
+	if err := checkDownloadStatus(url, resp); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	n, err := io.Copy(out, resp.Body)
+	DownloadBytes.WithLabelValues(filepath.Base(dest)).Add(float64(n))
 	if err != nil {
 		// clean up the partial tmp file
 		os.Remove(tmpPath)
@@ -168,10 +211,19 @@ func DownloadFileWithProgress(url string, dest string) error {
 	return nil
 }
 
+// checkDownloadStatus returns an error for responses that aren't a success
+func checkDownloadStatus(url string, resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s failed with status %d", resp.Request.Method, RedactURL(url), resp.StatusCode)
+	}
+
+	return nil
+}
+
 // DownloadMaxMindDb downloads a database directly from MaxMind's API using
 // HTTP Basic Auth. The response is a tar.gz archive containing the .mmdb file.
 // It uses ETag-based caching to skip re-downloads when the database hasn't changed.
-func DownloadMaxMindDb(accountID, licenseKey, editionID, dest string) error {
+func DownloadMaxMindDb(ctx context.Context, accountID, licenseKey, editionID, dest string) error {
 	if licenseKey == "" {
 		return fmt.Errorf("MaxMind license_key is required for direct download")
 	}
@@ -183,12 +235,20 @@ func DownloadMaxMindDb(accountID, licenseKey, editionID, dest string) error {
 	}
 
 	baseURL := fmt.Sprintf("https://download.maxmind.com/geoip/databases/%s/download?suffix=tar.gz", editionID)
-	return downloadMaxMindDbFromURL(baseURL, accountID, licenseKey, editionID, dest)
+	ctx, span := startDownloadSpan(ctx, baseURL, dest)
+	span.SetAttributes(attribute.String("geo.download.edition", editionID))
+	err := downloadMaxMindDbFromURL(ctx, baseURL, accountID, licenseKey, editionID, dest)
+	EndSpan(span, err)
+	if err != nil {
+		DownloadFailures.WithLabelValues(filepath.Base(dest)).Inc()
+	}
+
+	return err
 }
 
 // downloadMaxMindDbFromURL performs the actual download from a given URL.
 // Separated from DownloadMaxMindDb to allow testing with httptest servers.
-func downloadMaxMindDbFromURL(url, accountID, licenseKey, editionID, dest string) error {
+func downloadMaxMindDbFromURL(ctx context.Context, url, accountID, licenseKey, editionID, dest string) error {
 	// Use a non-redirect client for HEAD so we get the ETag directly from
 	// MaxMind without following the redirect to R2 (which strips auth headers).
 	noRedirectClient := &http.Client{
@@ -197,7 +257,7 @@ func downloadMaxMindDbFromURL(url, accountID, licenseKey, editionID, dest string
 		},
 	}
 
-	headReq, err := http.NewRequest("HEAD", url, nil)
+	headReq, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create HEAD request: %w", err)
 	}
@@ -231,7 +291,7 @@ func downloadMaxMindDbFromURL(url, accountID, licenseKey, editionID, dest string
 	// GET request to download. MaxMind redirects to a Cloudflare R2 presigned
 	// URL, so we need to follow redirects. The presigned URL contains auth in
 	// query params, so no need to forward Basic Auth to the redirect target.
-	getReq, err := http.NewRequest("GET", url, nil)
+	getReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create GET request: %w", err)
 	}
@@ -254,8 +314,9 @@ func downloadMaxMindDbFromURL(url, accountID, licenseKey, editionID, dest string
 		return fmt.Errorf("failed to create temp tar.gz file: %w", err)
 	}
 
-	_, err = io.Copy(tarGzFile, getResp.Body)
+	n, err := io.Copy(tarGzFile, getResp.Body)
 	tarGzFile.Close()
+	DownloadBytes.WithLabelValues(filepath.Base(dest)).Add(float64(n))
 	if err != nil {
 		os.Remove(tarGzTmp)
 		return fmt.Errorf("failed to download tar.gz: %w", err)
@@ -287,6 +348,20 @@ func downloadMaxMindDbFromURL(url, accountID, licenseKey, editionID, dest string
 	return nil
 }
 
+// startDownloadSpan starts the span of a database download. Only the host of
+// url is recorded as download URLs can carry credentials
+func startDownloadSpan(ctx context.Context, url string, dest string) (context.Context, trace.Span) {
+	host := url
+	if parsed, err := neturl.Parse(url); err == nil {
+		host = parsed.Host
+	}
+
+	return Tracer().Start(ctx, "download", trace.WithAttributes(
+		attribute.String("geo.download.host", host),
+		attribute.String("geo.download.dest", dest),
+	))
+}
+
 // extractMmdbFromTarGz opens a tar.gz archive and extracts the first .mmdb file to destPath.
 func extractMmdbFromTarGz(tarGzPath, destPath string) error {
 	f, err := os.Open(tarGzPath)
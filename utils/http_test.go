@@ -4,10 +4,12 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -130,7 +132,7 @@ func TestDownloadMaxMindDb(t *testing.T) {
 	// Test with the mock server - we need to call the internal logic
 	// Since we can't override the URL in DownloadMaxMindDb, test the pieces
 	t.Run("full flow with mock server", func(t *testing.T) {
-		err := downloadMaxMindDbFromURL(server.URL, expectedAccountID, expectedLicenseKey, "GeoLite2-City", dest)
+		err := downloadMaxMindDbFromURL(context.Background(), server.URL, expectedAccountID, expectedLicenseKey, "GeoLite2-City", dest)
 		if err != nil {
 			t.Fatalf("download failed: %v", err)
 		}
@@ -157,7 +159,7 @@ func TestDownloadMaxMindDb(t *testing.T) {
 	t.Run("skip download on matching etag", func(t *testing.T) {
 		// The etag file already exists from the previous test run
 		// Running again should skip the download
-		err := downloadMaxMindDbFromURL(server.URL, expectedAccountID, expectedLicenseKey, "GeoLite2-City", dest)
+		err := downloadMaxMindDbFromURL(context.Background(), server.URL, expectedAccountID, expectedLicenseKey, "GeoLite2-City", dest)
 		if err != nil {
 			t.Fatalf("second download failed: %v", err)
 		}
@@ -165,7 +167,7 @@ func TestDownloadMaxMindDb(t *testing.T) {
 
 	t.Run("bad credentials", func(t *testing.T) {
 		badDest := filepath.Join(tmpDir, "bad.mmdb")
-		err := downloadMaxMindDbFromURL(server.URL, "wrong", "wrong", "GeoLite2-City", badDest)
+		err := downloadMaxMindDbFromURL(context.Background(), server.URL, "wrong", "wrong", "GeoLite2-City", badDest)
 		if err == nil {
 			t.Fatal("expected error with bad credentials")
 		}
@@ -186,10 +188,31 @@ func TestDownloadMaxMindDb_ValidationErrors(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := DownloadMaxMindDb(tt.accountID, tt.licenseKey, tt.editionID, "/tmp/test.mmdb")
+			err := DownloadMaxMindDb(context.Background(), tt.accountID, tt.licenseKey, tt.editionID, "/tmp/test.mmdb")
 			if err == nil {
 				t.Fatal("expected validation error")
 			}
 		})
 	}
 }
+
+func TestDownloadFileWithProgress_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"error"`)
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("token expired"))
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "db.mmdb")
+	err := DownloadFileWithProgress(context.Background(), server.URL+"/db?token=secret", dest)
+	if err == nil {
+		t.Fatal("expected error for a 403 response")
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("error leaks the token: %v", err)
+	}
+	if FileExists(dest) || FileExists(ChangeExt(dest, "etag")) {
+		t.Error("error response was saved")
+	}
+}
@@ -37,8 +37,11 @@ type Location struct {
 }
 
 type Traits struct {
-	IsAnonymousProxy    bool `json:"is_anonymous_proxy"`
-	IsSatelliteProvider bool `json:"is_satellite_provider"`
+	IsAnonymousProxy    bool   `json:"is_anonymous_proxy"`
+	IsSatelliteProvider bool   `json:"is_satellite_provider"`
+	ISP                 string `json:"isp,omitempty"`
+	Domain              string `json:"domain,omitempty"`
+	UsageType           string `json:"usage_type,omitempty"`
 }
 
 type Postal struct {
@@ -58,8 +61,17 @@ type AnonymousIP struct {
 	IsTorExitNode     bool `json:"is_tor_exit_node"`
 }
 
+// Cloud is the cloud or CDN publishing the network of an address
+type Cloud struct {
+	Provider string `json:"provider"`
+	Service  string `json:"service,omitempty"`
+	Region   string `json:"region,omitempty"`
+}
+
 type IPInfo struct {
 	Address            string         `json:"address"`
+	OriginalAddress    string         `json:"original_address,omitempty"`
+	Network            string         `json:"network,omitempty"`
 	Source             string         `json:"source"`
 	IsFallback         bool           `json:"is_fallback"`
 	HasCity            bool           `json:"has_city"`
@@ -76,4 +88,7 @@ type IPInfo struct {
 	ASN                *ASN           `json:"asn"`
 	HasAnonymousIP     bool           `json:"has_anonymous_ip"`
 	AnonymousIP        *AnonymousIP   `json:"anonymous_ip"`
+	Reserved           *ReservedRange `json:"reserved,omitempty"`
+	Tags               []string       `json:"tags,omitempty"`
+	Cloud              *Cloud         `json:"cloud,omitempty"`
 }
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics exposed on /metrics
+var (
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "geo",
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests by route, provider and status",
+	}, []string{"route", "provider", "status"})
+
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "geo",
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route, provider and status",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "provider", "status"})
+
+	APIKeyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "geo",
+		Subsystem: "api_key",
+		Name:      "requests_total",
+		Help:      "Number of requests by API key name and outcome",
+	}, []string{"key", "outcome"})
+
+	ReservedLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "geo",
+		Subsystem: "lookup",
+		Name:      "reserved_total",
+		Help:      "Number of lookups of reserved addresses by range",
+	}, []string{"range"})
+
+	CacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "geo",
+		Subsystem: "cache",
+		Name:      "hits_total",
+		Help:      "Number of cache hits by tier",
+	}, []string{"tier"})
+
+	CacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "geo",
+		Subsystem: "cache",
+		Name:      "misses_total",
+		Help:      "Number of cache misses by tier",
+	}, []string{"tier"})
+
+	CacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "geo",
+		Subsystem: "cache",
+		Name:      "evictions_total",
+		Help:      "Number of entries evicted from the cache by tier",
+	}, []string{"tier"})
+
+	CacheSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "geo",
+		Subsystem: "cache",
+		Name:      "size",
+		Help:      "Number of entries in the cache by tier",
+	}, []string{"tier"})
+
+	ProviderLookupDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "geo",
+		Subsystem: "provider",
+		Name:      "lookup_duration_seconds",
+		Help:      "Provider lookup latency",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider"})
+
+	LookupsCoalesced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "geo",
+		Subsystem: "provider",
+		Name:      "lookups_coalesced_total",
+		Help:      "Number of lookups that waited for the same lookup already in flight",
+	}, []string{"provider"})
+
+	ProviderLookupErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "geo",
+		Subsystem: "provider",
+		Name:      "lookup_errors_total",
+		Help:      "Number of failed provider lookups",
+	}, []string{"provider"})
+
+	ProviderBudgetUsed = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "geo",
+		Subsystem: "provider",
+		Name:      "budget_used",
+		Help:      "Number of calls made to a paid provider in the current day or month",
+	}, []string{"provider", "period"})
+
+	DatabaseBuildEpoch = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "geo",
+		Subsystem: "database",
+		Name:      "build_epoch_seconds",
+		Help:      "Build time of the loaded database",
+	}, []string{"provider", "database"})
+
+	DatabaseLastRefresh = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "geo",
+		Subsystem: "database",
+		Name:      "last_refresh_timestamp_seconds",
+		Help:      "Time the database was last successfully loaded",
+	}, []string{"provider", "database"})
+
+	DownloadBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "geo",
+		Subsystem: "download",
+		Name:      "bytes_total",
+		Help:      "Number of bytes downloaded by file",
+	}, []string{"file"})
+
+	DownloadFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "geo",
+		Subsystem: "download",
+		Name:      "failures_total",
+		Help:      "Number of failed downloads by file",
+	}, []string{"file"})
+)
+
+// RecordDatabaseLoad records a database being successfully loaded
+func RecordDatabaseLoad(provider string, database string, buildEpoch uint) {
+	DatabaseBuildEpoch.WithLabelValues(provider, database).Set(float64(buildEpoch))
+	DatabaseLastRefresh.WithLabelValues(provider, database).Set(float64(time.Now().Unix()))
+	recordDatabaseVersion(provider, database, buildEpoch)
+}
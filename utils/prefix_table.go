@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"math/bits"
+	"net/netip"
+)
+
+// PrefixTable maps network prefixes to values and finds the longest prefix
+// containing an address. IPv4 and IPv6 prefixes are kept apart, with IPv4
+// mapped IPv6 addresses looked up as IPv4. It isn't safe for concurrent use
+type PrefixTable[V any] struct {
+	v4 prefixFamily[V]
+	v6 prefixFamily[V]
+}
+
+// prefixFamily holds the prefixes of one address family. lengths has a bit
+// set for every prefix length in use so lookups skip the empty ones
+type prefixFamily[V any] struct {
+	prefixes map[netip.Prefix]V
+	counts   [129]int
+	lengths  [3]uint64
+}
+
+func NewPrefixTable[V any]() *PrefixTable[V] {
+	return &PrefixTable[V]{
+		v4: prefixFamily[V]{prefixes: map[netip.Prefix]V{}},
+		v6: prefixFamily[V]{prefixes: map[netip.Prefix]V{}},
+	}
+}
+
+func (pt *PrefixTable[V]) family(addr netip.Addr) *prefixFamily[V] {
+	if addr.Is4() {
+		return &pt.v4
+	}
+
+	return &pt.v6
+}
+
+// normalizePrefix masks prefix and turns IPv4 mapped IPv6 prefixes into IPv4
+func normalizePrefix(prefix netip.Prefix) netip.Prefix {
+	addr := prefix.Addr()
+	if addr.Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(addr.Unmap(), prefix.Bits()-96)
+	}
+
+	return prefix.Masked()
+}
+
+// Insert sets the value of prefix, replacing any previous one
+func (pt *PrefixTable[V]) Insert(prefix netip.Prefix, value V) {
+	prefix = normalizePrefix(prefix)
+	if !prefix.IsValid() {
+		return
+	}
+
+	family := pt.family(prefix.Addr())
+	if _, ok := family.prefixes[prefix]; !ok {
+		family.counts[prefix.Bits()]++
+		family.lengths[prefix.Bits()/64] |= 1 << (prefix.Bits() % 64)
+	}
+	family.prefixes[prefix] = value
+}
+
+// Delete removes prefix and tells if it was in the table
+func (pt *PrefixTable[V]) Delete(prefix netip.Prefix) bool {
+	prefix = normalizePrefix(prefix)
+	family := pt.family(prefix.Addr())
+	if _, ok := family.prefixes[prefix]; !ok {
+		return false
+	}
+
+	delete(family.prefixes, prefix)
+	family.counts[prefix.Bits()]--
+	if family.counts[prefix.Bits()] == 0 {
+		family.lengths[prefix.Bits()/64] &^= 1 << (prefix.Bits() % 64)
+	}
+
+	return true
+}
+
+// Get returns the value of exactly prefix
+func (pt *PrefixTable[V]) Get(prefix netip.Prefix) (V, bool) {
+	prefix = normalizePrefix(prefix)
+	value, ok := pt.family(prefix.Addr()).prefixes[prefix]
+
+	return value, ok
+}
+
+// Lookup returns the longest prefix containing addr and its value
+func (pt *PrefixTable[V]) Lookup(addr netip.Addr) (netip.Prefix, V, bool) {
+	addr = addr.Unmap().WithZone("")
+	family := pt.family(addr)
+
+	for length := addr.BitLen(); length >= 0; {
+		// jump to the next length in use at or below length
+		word := length / 64
+		mask := family.lengths[word] & (^uint64(0) >> (63 - length%64))
+		if mask == 0 {
+			length = word*64 - 1
+			continue
+		}
+		length = word*64 + 63 - bits.LeadingZeros64(mask)
+
+		prefix, _ := addr.Prefix(length)
+		if value, ok := family.prefixes[prefix]; ok {
+			return prefix, value, true
+		}
+		length--
+	}
+
+	var zero V
+	return netip.Prefix{}, zero, false
+}
+
+// Contains tells if addr is in any prefix of the table
+func (pt *PrefixTable[V]) Contains(addr netip.Addr) bool {
+	_, _, ok := pt.Lookup(addr)
+	return ok
+}
+
+// Range calls fn for every prefix in the table until it returns false
+func (pt *PrefixTable[V]) Range(fn func(prefix netip.Prefix, value V) bool) {
+	for _, family := range []*prefixFamily[V]{&pt.v4, &pt.v6} {
+		for prefix, value := range family.prefixes {
+			if !fn(prefix, value) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of prefixes in the table
+func (pt *PrefixTable[V]) Len() int {
+	return len(pt.v4.prefixes) + len(pt.v6.prefixes)
+}
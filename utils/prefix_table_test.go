@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type prefixTableTestSuite struct {
+	suite.Suite
+	table *PrefixTable[string]
+}
+
+func (suite *prefixTableTestSuite) SetupTest() {
+	suite.table = NewPrefixTable[string]()
+	suite.table.Insert(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	suite.table.Insert(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	suite.table.Insert(netip.MustParsePrefix("10.1.2.3/32"), "c")
+	suite.table.Insert(netip.MustParsePrefix("2001:db8::/32"), "d")
+	suite.table.Insert(netip.MustParsePrefix("::/0"), "e")
+}
+
+func (suite *prefixTableTestSuite) lookup(address string) (string, string) {
+	prefix, value, ok := suite.table.Lookup(netip.MustParseAddr(address))
+	if !ok {
+		return "", ""
+	}
+
+	return prefix.String(), value
+}
+
+func (suite *prefixTableTestSuite) TestLongestMatch() {
+	prefix, value := suite.lookup("10.1.2.3")
+	suite.Equal("10.1.2.3/32", prefix)
+	suite.Equal("c", value)
+
+	prefix, value = suite.lookup("10.1.9.9")
+	suite.Equal("10.1.0.0/16", prefix)
+	suite.Equal("b", value)
+
+	prefix, value = suite.lookup("10.200.0.1")
+	suite.Equal("10.0.0.0/8", prefix)
+	suite.Equal("a", value)
+
+	prefix, _ = suite.lookup("2001:db8:1::1")
+	suite.Equal("2001:db8::/32", prefix)
+
+	// IPv4 addresses never match IPv6 prefixes
+	prefix, _ = suite.lookup("192.168.0.1")
+	suite.Empty(prefix)
+}
+
+func (suite *prefixTableTestSuite) TestMappedAddresses() {
+	prefix, _ := suite.lookup("::ffff:10.1.9.9")
+	suite.Equal("10.1.0.0/16", prefix)
+
+	suite.table.Insert(netip.MustParsePrefix("::ffff:192.168.0.0/112"), "f")
+	prefix, value := suite.lookup("192.168.1.1")
+	suite.Equal("192.168.0.0/16", prefix)
+	suite.Equal("f", value)
+}
+
+func (suite *prefixTableTestSuite) TestInsertMasks() {
+	suite.table.Insert(netip.MustParsePrefix("172.16.5.4/12"), "g")
+
+	value, ok := suite.table.Get(netip.MustParsePrefix("172.16.0.0/12"))
+	suite.True(ok)
+	suite.Equal("g", value)
+}
+
+func (suite *prefixTableTestSuite) TestDelete() {
+	suite.True(suite.table.Delete(netip.MustParsePrefix("10.1.0.0/16")))
+	suite.False(suite.table.Delete(netip.MustParsePrefix("10.1.0.0/16")))
+	suite.Equal(4, suite.table.Len())
+
+	prefix, _ := suite.lookup("10.1.9.9")
+	suite.Equal("10.0.0.0/8", prefix)
+
+	suite.True(suite.table.Delete(netip.MustParsePrefix("10.0.0.0/8")))
+	suite.False(suite.table.Contains(netip.MustParseAddr("10.1.9.9")))
+	suite.True(suite.table.Contains(netip.MustParseAddr("10.1.2.3")))
+}
+
+func TestPrefixTableTestSuite(t *testing.T) {
+	suite.Run(t, new(prefixTableTestSuite))
+}
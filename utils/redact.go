@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"net/url"
+)
+
+// Redacted replaces secrets in logs and responses
+const Redacted = "REDACTED"
+
+// RedactURL removes the user information and the query values of value
+// when it is a URL, as they often carry passwords and tokens
+func RedactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return value
+	}
+
+	if u.User != nil {
+		u.User = url.User(Redacted)
+	}
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query[key] = []string{Redacted}
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String()
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"net/netip"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// ReservedRange is a special-purpose or bogon range that isn't routed on the
+// public internet, so no provider has anything to say about it
+type ReservedRange struct {
+	Network string `json:"network" mapstructure:"network"`
+	Name    string `json:"name" mapstructure:"name"`
+	RFC     string `json:"rfc,omitempty" mapstructure:"rfc"`
+}
+
+// reservedRanges are the ranges of the IANA IPv4 and IPv6 special-purpose
+// address registries that aren't globally reachable, and the multicast
+// ranges. Globally reachable entries such as NAT64 and Teredo are left out
+var reservedRanges = []ReservedRange{
+	{"0.0.0.0/8", "This network", "RFC 791"},
+	{"10.0.0.0/8", "Private-Use", "RFC 1918"},
+	{"100.64.0.0/10", "Shared Address Space", "RFC 6598"},
+	{"127.0.0.0/8", "Loopback", "RFC 1122"},
+	{"169.254.0.0/16", "Link Local", "RFC 3927"},
+	{"172.16.0.0/12", "Private-Use", "RFC 1918"},
+	{"192.0.0.0/24", "IETF Protocol Assignments", "RFC 6890"},
+	{"192.0.2.0/24", "Documentation (TEST-NET-1)", "RFC 5737"},
+	{"192.88.99.0/24", "Deprecated 6to4 Relay Anycast", "RFC 7526"},
+	{"192.168.0.0/16", "Private-Use", "RFC 1918"},
+	{"198.18.0.0/15", "Benchmarking", "RFC 2544"},
+	{"198.51.100.0/24", "Documentation (TEST-NET-2)", "RFC 5737"},
+	{"203.0.113.0/24", "Documentation (TEST-NET-3)", "RFC 5737"},
+	{"224.0.0.0/4", "Multicast", "RFC 5771"},
+	{"240.0.0.0/4", "Reserved", "RFC 1112"},
+	{"255.255.255.255/32", "Limited Broadcast", "RFC 919"},
+	{"::/128", "Unspecified Address", "RFC 4291"},
+	{"::1/128", "Loopback Address", "RFC 4291"},
+	{"64:ff9b:1::/48", "IPv4-IPv6 Translation", "RFC 8215"},
+	{"100::/64", "Discard-Only Address Block", "RFC 6666"},
+	{"2001:2::/48", "Benchmarking", "RFC 5180"},
+	{"2001:10::/28", "Deprecated ORCHID", "RFC 4843"},
+	{"2001:db8::/32", "Documentation", "RFC 3849"},
+	{"3fff::/20", "Documentation", "RFC 9637"},
+	{"5f00::/16", "Segment Routing (SRv6) SIDs", "RFC 9602"},
+	{"fc00::/7", "Unique-Local", "RFC 4193"},
+	{"fe80::/10", "Link-Local Unicast", "RFC 4291"},
+	{"fec0::/10", "Deprecated Site-Local", "RFC 3879"},
+	{"ff00::/8", "Multicast", "RFC 4291"},
+}
+
+var reservedTable = struct {
+	sync.RWMutex
+	table *PrefixTable[*ReservedRange]
+}{}
+
+// ConfigureReservedRanges builds the registry from the built-in ranges and
+// reserved.extra, which can add bogons or override the name of a range
+func ConfigureReservedRanges() error {
+	var extra []ReservedRange
+	if err := viper.UnmarshalKey("reserved.extra", &extra); err != nil {
+		return err
+	}
+
+	table := NewPrefixTable[*ReservedRange]()
+	for _, ranges := range [][]ReservedRange{reservedRanges, extra} {
+		for i := range ranges {
+			prefix, err := netip.ParsePrefix(ranges[i].Network)
+			if err != nil {
+				return err
+			}
+
+			table.Insert(prefix, &ranges[i])
+		}
+	}
+
+	reservedTable.Lock()
+	defer reservedTable.Unlock()
+	reservedTable.table = table
+
+	log.Debug().Int("ranges", table.Len()).Msg("configured reserved address ranges")
+
+	return nil
+}
+
+// LookupReservedRange returns the reserved range address is in, or nil for
+// public addresses
+func LookupReservedRange(addr netip.Addr) *ReservedRange {
+	reservedTable.RLock()
+	table := reservedTable.table
+	reservedTable.RUnlock()
+
+	if table == nil {
+		if err := ConfigureReservedRanges(); err != nil {
+			log.Error().Err(err).Msg("failed to configure reserved address ranges")
+			return nil
+		}
+		return LookupReservedRange(addr)
+	}
+
+	_, reserved, ok := table.Lookup(addr)
+	if !ok {
+		return nil
+	}
+
+	return reserved
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/suite"
+)
+
+type reservedTestSuite struct {
+	suite.Suite
+}
+
+func (suite *reservedTestSuite) SetupTest() {
+	viper.Set("reserved.extra", nil)
+	suite.Require().NoError(ConfigureReservedRanges())
+}
+
+func (suite *reservedTestSuite) rangeName(address string) string {
+	reserved := LookupReservedRange(netip.MustParseAddr(address))
+	if reserved == nil {
+		return ""
+	}
+
+	return reserved.Name
+}
+
+func (suite *reservedTestSuite) TestRegistry() {
+	suite.Equal("Private-Use", suite.rangeName("10.0.0.1"))
+	suite.Equal("Loopback", suite.rangeName("127.0.0.1"))
+	suite.Equal("Shared Address Space", suite.rangeName("100.64.0.1"))
+	suite.Equal("Documentation (TEST-NET-3)", suite.rangeName("203.0.113.1"))
+	suite.Equal("Multicast", suite.rangeName("239.1.1.1"))
+	suite.Equal("Link-Local Unicast", suite.rangeName("fe80::1"))
+	suite.Equal("Unique-Local", suite.rangeName("fd00::1"))
+	suite.Equal("Unspecified Address", suite.rangeName("::"))
+
+	suite.Empty(suite.rangeName("8.8.8.8"))
+	suite.Empty(suite.rangeName("2606:4700::1111"))
+	// globally reachable special-purpose ranges are looked up
+	suite.Empty(suite.rangeName("64:ff9b::808:808"))
+}
+
+func (suite *reservedTestSuite) TestExtra() {
+	viper.Set("reserved.extra", []map[string]string{
+		{"network": "45.0.0.0/8", "name": "Bogon"},
+		{"network": "10.1.0.0/16", "name": "Office", "rfc": "RFC 1918"},
+	})
+	defer viper.Set("reserved.extra", nil)
+	suite.Require().NoError(ConfigureReservedRanges())
+
+	suite.Equal("Bogon", suite.rangeName("45.1.2.3"))
+	suite.Equal("Office", suite.rangeName("10.1.2.3"))
+	suite.Equal("Private-Use", suite.rangeName("10.2.2.3"))
+
+	viper.Set("reserved.extra", []map[string]string{{"network": "nope", "name": "Broken"}})
+	suite.Error(ConfigureReservedRanges())
+	suite.Equal("Bogon", suite.rangeName("45.1.2.3"))
+}
+
+func TestReservedTestSuite(t *testing.T) {
+	suite.Run(t, new(reservedTestSuite))
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/cloud66-oss/geo"
+
+// Tracer returns the tracer used for all geo spans. It goes through the global
+// tracer provider so spans are dropped until tracing is configured
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// ConfigureTracing sets up the global tracer provider to export spans over
+// OTLP as configured under tracing. The returned function flushes and stops
+// the exporter
+func ConfigureTracing(ctx context.Context) (func(context.Context) error, error) {
+	// always accept incoming trace context, even when we don't export
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !viper.GetBool("tracing.enabled") {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var client otlptrace.Client
+	switch viper.GetString("tracing.protocol") {
+	case "grpc":
+		options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(viper.GetString("tracing.endpoint"))}
+		if viper.GetBool("tracing.insecure") {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		client = otlptracegrpc.NewClient(options...)
+	case "http":
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(viper.GetString("tracing.endpoint"))}
+		if viper.GetBool("tracing.insecure") {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		client = otlptracehttp.NewClient(options...)
+	default:
+		return nil, fmt.Errorf("unknown tracing protocol %s. Use grpc or http", viper.GetString("tracing.protocol"))
+	}
+
+	exporter, err := otlptrace.New(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", viper.GetString("tracing.service_name")),
+		attribute.String("service.version", Version),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(viper.GetFloat64("tracing.sample_ratio")))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// EndSpan records err on span, if any, and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}